@@ -13,65 +13,74 @@ type ProcessBatchWorkflowInput struct {
 	PageSize          int // Number of children started by a single sliding window workflow run
 	SlidingWindowSize int // Maximum number of children to run in parallel.
 	Partitions        int // How many sliding windows to run in parallel.
+	// RecordRetry is the retry policy of failed records.
+	RecordRetry RecordRetryPolicy
+	// DeadLetterTimeout is how long each sliding window waits for a ReprocessDeadLetters signal when records are
+	// dead-lettered, zero completes right away.
+	DeadLetterTimeout time.Duration
 }
 
-// ProcessBatchWorkflow sample Partitions the data set into continuous ranges.
+// ProcessBatchWorkflowResult result of the ProcessBatchWorkflow.
+type ProcessBatchWorkflowResult struct {
+	// Processed is the number of records processed successfully.
+	Processed int
+	// DeadLetters are the records that failed all their attempts in all partitions.
+	DeadLetters []FailedRecord
+}
+
+// ProcessBatchWorkflow sample Partitions the data set into continuous ranges of the RecordSource.
 // A real application can choose any other way to divide the records into multiple collections.
-func ProcessBatchWorkflow(ctx workflow.Context, input ProcessBatchWorkflowInput) (processed int, err error) {
+func ProcessBatchWorkflow(ctx workflow.Context, input ProcessBatchWorkflowInput) (result ProcessBatchWorkflowResult, err error) {
 	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
 		StartToCloseTimeout: 5 * time.Second,
 	})
 
+	if input.SlidingWindowSize < input.Partitions {
+		return ProcessBatchWorkflowResult{}, temporal.NewApplicationError(
+			"SlidingWindowSize cannot be less than number of partitions", "invalidInput")
+	}
+
 	var recordLoader *RecordLoader // RecordLoader activity reference
-	var recordCount int
-	err = workflow.ExecuteActivity(ctx, recordLoader.GetRecordCount).Get(ctx, &recordCount)
+	var partitions []Partition
+	err = workflow.ExecuteActivity(ctx, recordLoader.GetPartitions, input.Partitions).Get(ctx, &partitions)
 	if err != nil {
-		return 0, err
+		return ProcessBatchWorkflowResult{}, err
 	}
-
-	if input.SlidingWindowSize < input.Partitions {
-		return 0, temporal.NewApplicationError(
-			"SlidingWindowSize cannot be less than number of partitions", "invalidInput")
+	if len(partitions) == 0 {
+		return ProcessBatchWorkflowResult{}, nil
 	}
-	partitions := divideIntoPartitions(recordCount, input.Partitions)
-	windowSizes := divideIntoPartitions(input.SlidingWindowSize, input.Partitions)
+	windowSizes := divideIntoPartitions(input.SlidingWindowSize, len(partitions))
 
 	workflow.GetLogger(ctx).Info("ProcessBatchWorkflow",
 		"input", input,
-		"recordCount", recordCount,
 		"partitions", partitions,
 		"windowSizes", windowSizes)
 
 	var results []workflow.ChildWorkflowFuture
-	offset := 0
-	for i := 0; i < input.Partitions; i++ {
+	for i, partition := range partitions {
 		// Makes child id more user-friendly
 		childId := fmt.Sprintf("%s/%d", workflow.GetInfo(ctx).WorkflowExecution.ID, i)
 		childCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{WorkflowID: childId})
-		// Define partition boundaries.
-		maximumPartitionOffset := offset + partitions[i]
-		if maximumPartitionOffset > recordCount {
-			maximumPartitionOffset = recordCount
-		}
 		input := SlidingWindowWorkflowInput{
 			PageSize:          input.PageSize,
 			SlidingWindowSize: windowSizes[i],
-			Offset:            offset,                 // inclusive
-			MaximumOffset:     maximumPartitionOffset, // exclusive
+			Token:             partition.StartToken,
+			EndToken:          partition.EndToken,
+			RecordRetry:       input.RecordRetry,
+			DeadLetterTimeout: input.DeadLetterTimeout,
 		}
 		child := workflow.ExecuteChildWorkflow(childCtx, SlidingWindowWorkflow, input)
 		results = append(results, child)
-		offset += partitions[i]
 	}
 	// Waits for all child workflows to complete
-	result := 0
 	for _, partitionResult := range results {
-		var r int
+		var r SlidingWindowWorkflowResult
 		err := partitionResult.Get(ctx, &r) // blocks until the child completion
 		if err != nil {
-			return 0, err
+			return ProcessBatchWorkflowResult{}, err
 		}
-		result += r
+		result.Processed += r.Processed
+		result.DeadLetters = append(result.DeadLetters, r.DeadLetters...)
 	}
 	return result, nil
 }
@@ -1,47 +1,106 @@
 package batch_sliding_window
 
-import "fmt"
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
 
 type (
 	// RecordLoader activities structure.
 	RecordLoader struct {
-		RecordCount int
+		Source RecordSource
+	}
+
+	// RecordSource pages through the records of a data set with opaque continuation tokens. The tokens are
+	// positions in the data set, which are checkpointed by the sliding windows across continue-as-new.
+	RecordSource interface {
+		// Partitions splits the records into at most n ranges, which are processed by parallel sliding windows.
+		Partitions(ctx context.Context, n int) ([]Partition, error)
+		// GetRecords returns up to input.PageSize records from input.Token until input.EndToken.
+		GetRecords(ctx context.Context, input GetRecordsInput) (GetRecordsOutput, error)
+	}
+
+	// Partition is a range of records of a RecordSource.
+	Partition struct {
+		// StartToken is the position of the first record.
+		StartToken string
+		// EndToken is the position after the last record.
+		EndToken string
 	}
 
 	GetRecordsInput struct {
-		PageSize  int
-		Offset    int
-		MaxOffset int
+		PageSize int
+		// Token is the position of the first record: the StartToken of a Partition or the NextToken of the
+		// previous page.
+		Token string
+		// EndToken is the EndToken of the Partition.
+		EndToken string
 	}
 
 	SingleRecord struct {
 		Id int
+		// Data is the JSON content of the record, if any.
+		Data json.RawMessage `json:",omitempty"`
 	}
 
 	GetRecordsOutput struct {
 		Records []SingleRecord
+		// NextToken is the position after the records.
+		NextToken string
+		// Done is set on the last page of a partition.
+		Done bool
+	}
+
+	// SyntheticRecordSource is a RecordSource of RecordCount fake records without data. Its tokens are offsets.
+	SyntheticRecordSource struct {
+		RecordCount int
 	}
 )
 
-// GetRecordCount activity returns the total record count.
+// GetPartitions activity splits the records into at most n ranges.
 // Used to partition processing across parallel sliding windows.
-// The sample implementation just returns a fake value passed during worker initialization.
-func (p *RecordLoader) GetRecordCount() (int, error) {
-	return p.RecordCount, nil
+func (p *RecordLoader) GetPartitions(ctx context.Context, n int) ([]Partition, error) {
+	return p.Source.Partitions(ctx, n)
+}
+
+// GetRecords activity returns a page of records loaded from the RecordSource.
+func (p *RecordLoader) GetRecords(ctx context.Context, input GetRecordsInput) (GetRecordsOutput, error) {
+	return p.Source.GetRecords(ctx, input)
+}
+
+// Partitions divides the offsets into continuous ranges.
+func (s *SyntheticRecordSource) Partitions(_ context.Context, n int) ([]Partition, error) {
+	var partitions []Partition
+	offset := 0
+	for _, size := range divideIntoPartitions(s.RecordCount, n) {
+		partitions = append(partitions, Partition{StartToken: strconv.Itoa(offset), EndToken: strconv.Itoa(offset + size)})
+		offset += size
+	}
+	return partitions, nil
 }
 
-// GetRecords activity returns records loaded from an external data source. The sample returns fake records.
-func (p *RecordLoader) GetRecords(input GetRecordsInput) (output GetRecordsOutput, err error) {
-	if input.MaxOffset > p.RecordCount {
-		panic(fmt.Sprintf("maxOffset(%d)>recordCount(%d", input.MaxOffset, p.RecordCount))
+// GetRecords returns fake records, whose ids are their offsets.
+func (s *SyntheticRecordSource) GetRecords(_ context.Context, input GetRecordsInput) (GetRecordsOutput, error) {
+	offset, err := strconv.Atoi(input.Token)
+	if err != nil {
+		return GetRecordsOutput{}, fmt.Errorf("invalid token %q: %w", input.Token, err)
+	}
+	maxOffset, err := strconv.Atoi(input.EndToken)
+	if err != nil {
+		return GetRecordsOutput{}, fmt.Errorf("invalid token %q: %w", input.EndToken, err)
+	}
+	if maxOffset > s.RecordCount {
+		return GetRecordsOutput{}, fmt.Errorf("maxOffset(%d)>recordCount(%d)", maxOffset, s.RecordCount)
 	}
 	var records []SingleRecord
-	limit := input.Offset + input.PageSize
-	if limit > input.MaxOffset {
-		limit = input.MaxOffset
+	limit := offset + input.PageSize
+	if limit > maxOffset {
+		limit = maxOffset
 	}
-	for i := input.Offset; i < limit; i++ {
+	for i := offset; i < limit; i++ {
 		records = append(records, SingleRecord{Id: i})
 	}
-	return GetRecordsOutput{Records: records}, nil
+	return GetRecordsOutput{Records: records, NextToken: strconv.Itoa(limit), Done: limit >= maxOffset}, nil
 }
@@ -9,13 +9,18 @@ import (
 // RecordProcessorWorkflow workflow that implements processing of a single record.
 func RecordProcessorWorkflow(ctx workflow.Context, r SingleRecord) error {
 	err := ProcessRecord(ctx, r)
-	// Notify parent about completion via signal
+	// Notify parent about completion via signal, failed records are retried by the parent.
 	parent := workflow.GetInfo(ctx).ParentWorkflowExecution
 	// This workflow is always expected to have a parent.
 	// But for unit testing it might be useful to skip the notification if there is none.
 	if parent != nil {
 		// Doesn't specify runId as parent calls continue-as-new.
-		signaled := workflow.SignalExternalWorkflow(ctx, parent.ID, "", "ReportCompletion", r.Id)
+		completion := RecordCompletion{Id: r.Id}
+		if err != nil {
+			completion.Error = err.Error()
+			completion.Data = r.Data
+		}
+		signaled := workflow.SignalExternalWorkflow(ctx, parent.ID, "", ReportCompletionSignal, completion)
 		// Ensure that signal is delivered.
 		// Completing workflow before this Future is ready might lead to the signal loss.
 		signalErr := signaled.Get(ctx, nil)
@@ -0,0 +1,195 @@
+package batch_sliding_window
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+type (
+	// NDJSONRecordSource is a RecordSource of a newline-delimited JSON file, each line is the Data of a record.
+	// The tokens and record ids are byte offsets of lines, so the file must only be appended to while it is processed.
+	NDJSONRecordSource struct {
+		Path string
+	}
+
+	// CSVRecordSource is a RecordSource of a CSV file with a header row. The Data of a record is a JSON object of
+	// the columns by header. The tokens and record ids are byte offsets of rows, so rows must not span lines.
+	CSVRecordSource struct {
+		Path string
+	}
+)
+
+// Partitions splits the file into byte ranges aligned to lines.
+func (s *NDJSONRecordSource) Partitions(_ context.Context, n int) ([]Partition, error) {
+	return filePartitions(s.Path, 0, n)
+}
+
+// GetRecords reads the lines from the offset of the token, empty lines are skipped.
+func (s *NDJSONRecordSource) GetRecords(_ context.Context, input GetRecordsInput) (GetRecordsOutput, error) {
+	start, end, err := parseOffsets(input)
+	if err != nil {
+		return GetRecordsOutput{}, err
+	}
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return GetRecordsOutput{}, err
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return GetRecordsOutput{}, err
+	}
+
+	var output GetRecordsOutput
+	reader := bufio.NewReader(f)
+	offset := start
+	for offset < end && len(output.Records) < input.PageSize {
+		line, err := reader.ReadBytes('\n')
+		if len(line) == 0 && err == io.EOF {
+			offset = end
+			break
+		}
+		if err != nil && err != io.EOF {
+			return GetRecordsOutput{}, err
+		}
+		recordOffset := offset
+		offset += int64(len(line))
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			return GetRecordsOutput{}, fmt.Errorf("invalid JSON at offset %d of %s", recordOffset, s.Path)
+		}
+		output.Records = append(output.Records, SingleRecord{Id: int(recordOffset), Data: line})
+	}
+	output.NextToken = strconv.FormatInt(offset, 10)
+	output.Done = offset >= end
+	return output, nil
+}
+
+// Partitions splits the rows after the header into byte ranges aligned to lines.
+func (s *CSVRecordSource) Partitions(_ context.Context, n int) ([]Partition, error) {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	headerEnd, err := lineStart(f, 1)
+	_ = f.Close()
+	if err != nil {
+		return nil, err
+	}
+	return filePartitions(s.Path, headerEnd, n)
+}
+
+// GetRecords reads the rows from the offset of the token.
+func (s *CSVRecordSource) GetRecords(_ context.Context, input GetRecordsInput) (GetRecordsOutput, error) {
+	start, end, err := parseOffsets(input)
+	if err != nil {
+		return GetRecordsOutput{}, err
+	}
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return GetRecordsOutput{}, err
+	}
+	defer func() { _ = f.Close() }()
+	header, err := csv.NewReader(f).Read()
+	if err != nil {
+		return GetRecordsOutput{}, fmt.Errorf("failed reading header of %s: %w", s.Path, err)
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return GetRecordsOutput{}, err
+	}
+
+	var output GetRecordsOutput
+	reader := csv.NewReader(io.LimitReader(f, end-start))
+	reader.FieldsPerRecord = len(header)
+	offset := start
+	for len(output.Records) < input.PageSize {
+		row, err := reader.Read()
+		if err == io.EOF {
+			offset = end
+			break
+		}
+		if err != nil {
+			return GetRecordsOutput{}, fmt.Errorf("failed reading %s: %w", s.Path, err)
+		}
+		recordOffset := offset
+		offset = start + reader.InputOffset()
+		columns := make(map[string]string, len(header))
+		for i, name := range header {
+			columns[name] = row[i]
+		}
+		data, err := json.Marshal(columns)
+		if err != nil {
+			return GetRecordsOutput{}, err
+		}
+		output.Records = append(output.Records, SingleRecord{Id: int(recordOffset), Data: data})
+	}
+	output.NextToken = strconv.FormatInt(offset, 10)
+	output.Done = offset >= end
+	return output, nil
+}
+
+// filePartitions splits the file from start into n byte ranges aligned to lines. The tokens are byte offsets.
+func filePartitions(path string, start int64, n int) ([]Partition, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+
+	partitions := make([]Partition, n)
+	offset := start
+	for i := range partitions {
+		end := size
+		if i < n-1 {
+			if end, err = lineStart(f, start+(size-start)*int64(i+1)/int64(n)); err != nil {
+				return nil, err
+			}
+		}
+		if end < offset {
+			end = offset
+		}
+		partitions[i] = Partition{StartToken: strconv.FormatInt(offset, 10), EndToken: strconv.FormatInt(end, 10)}
+		offset = end
+	}
+	return partitions, nil
+}
+
+// lineStart returns the offset of the first line that starts at or after offset.
+func lineStart(f *os.File, offset int64) (int64, error) {
+	if offset <= 0 {
+		return 0, nil
+	}
+	if _, err := f.Seek(offset-1, io.SeekStart); err != nil {
+		return 0, err
+	}
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+	return offset - 1 + int64(len(line)), nil
+}
+
+func parseOffsets(input GetRecordsInput) (start, end int64, err error) {
+	if start, err = strconv.ParseInt(input.Token, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid token %q: %w", input.Token, err)
+	}
+	if end, err = strconv.ParseInt(input.EndToken, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid token %q: %w", input.EndToken, err)
+	}
+	return start, end, nil
+}
@@ -0,0 +1,112 @@
+package batch_sliding_window
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// SQLRecordSource is a RecordSource of a table with an integer id column, read with keyset pagination. The Data of a
+// record is a JSON object of the columns of its row. The tokens are ids.
+//
+// Table and IDColumn are put into the queries as they are, so they must come from trusted configuration. The queries
+// use "?" placeholders, as supported by SQLite and MySQL drivers.
+type SQLRecordSource struct {
+	DB       *sql.DB
+	Table    string
+	IDColumn string
+}
+
+// Partitions splits the range of ids into n ranges.
+func (s *SQLRecordSource) Partitions(ctx context.Context, n int) ([]Partition, error) {
+	var minID, maxID sql.NullInt64
+	query := fmt.Sprintf("SELECT MIN(%s), MAX(%s) FROM %s", s.IDColumn, s.IDColumn, s.Table)
+	if err := s.DB.QueryRowContext(ctx, query).Scan(&minID, &maxID); err != nil {
+		return nil, err
+	}
+	if !minID.Valid {
+		// The table is empty.
+		return nil, nil
+	}
+
+	var partitions []Partition
+	start := minID.Int64
+	for _, size := range divideIntoPartitions(int(maxID.Int64-minID.Int64+1), n) {
+		end := start + int64(size)
+		partitions = append(partitions, Partition{StartToken: strconv.FormatInt(start, 10), EndToken: strconv.FormatInt(end, 10)})
+		start = end
+	}
+	return partitions, nil
+}
+
+// GetRecords returns the rows with ids from the token, in id order.
+func (s *SQLRecordSource) GetRecords(ctx context.Context, input GetRecordsInput) (GetRecordsOutput, error) {
+	start, end, err := parseOffsets(input)
+	if err != nil {
+		return GetRecordsOutput{}, err
+	}
+	query := fmt.Sprintf("SELECT * FROM %s WHERE %s >= ? AND %s < ? ORDER BY %s LIMIT ?",
+		s.Table, s.IDColumn, s.IDColumn, s.IDColumn)
+	rows, err := s.DB.QueryContext(ctx, query, start, end, input.PageSize)
+	if err != nil {
+		return GetRecordsOutput{}, err
+	}
+	defer func() { _ = rows.Close() }()
+	columns, err := rows.Columns()
+	if err != nil {
+		return GetRecordsOutput{}, err
+	}
+
+	var output GetRecordsOutput
+	next := start
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return GetRecordsOutput{}, err
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			row[column] = values[i]
+		}
+		id, err := toInt64(row[s.IDColumn])
+		if err != nil {
+			return GetRecordsOutput{}, fmt.Errorf("invalid %s: %w", s.IDColumn, err)
+		}
+		data, err := json.Marshal(row)
+		if err != nil {
+			return GetRecordsOutput{}, err
+		}
+		output.Records = append(output.Records, SingleRecord{Id: int(id), Data: data})
+		next = id + 1
+	}
+	if err := rows.Err(); err != nil {
+		return GetRecordsOutput{}, err
+	}
+	output.NextToken = strconv.FormatInt(next, 10)
+	output.Done = len(output.Records) < input.PageSize || next >= end
+	return output, nil
+}
+
+func toInt64(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	default:
+		return 0, fmt.Errorf("unsupported id %v of type %T", value, value)
+	}
+}
@@ -0,0 +1,135 @@
+package batch_sliding_window
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+// readAll reads all records of the source page by page, across the given number of partitions.
+func readAll(t *testing.T, source RecordSource, partitions int, pageSize int) []SingleRecord {
+	ctx := context.Background()
+	ranges, err := source.Partitions(ctx, partitions)
+	require.NoError(t, err)
+	require.LessOrEqual(t, len(ranges), partitions)
+
+	var records []SingleRecord
+	for _, partition := range ranges {
+		token := partition.StartToken
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, 100, "too many pages")
+			output, err := source.GetRecords(ctx, GetRecordsInput{PageSize: pageSize, Token: token, EndToken: partition.EndToken})
+			require.NoError(t, err)
+			require.LessOrEqual(t, len(output.Records), pageSize)
+			records = append(records, output.Records...)
+			if output.Done {
+				break
+			}
+			token = output.NextToken
+		}
+	}
+	return records
+}
+
+// recordData returns the "name" of the JSON data of the records.
+func recordData(t *testing.T, records []SingleRecord) []string {
+	var names []string
+	ids := make(map[int]bool)
+	for _, record := range records {
+		require.False(t, ids[record.Id], "duplicate id %d", record.Id)
+		ids[record.Id] = true
+		var data struct{ Name string }
+		require.NoError(t, json.Unmarshal(record.Data, &data))
+		names = append(names, data.Name)
+	}
+	return names
+}
+
+var recordNames = []string{"a", "b", "c", "d", "e", "f", "g"}
+
+func writeFile(t *testing.T, name string, lines []string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0600))
+	return path
+}
+
+func Test_SyntheticRecordSource(t *testing.T) {
+	records := readAll(t, &SyntheticRecordSource{RecordCount: 10}, 3, 2)
+	require.Len(t, records, 10)
+	for i, record := range records {
+		require.Equal(t, i, record.Id)
+	}
+}
+
+func Test_NDJSONRecordSource(t *testing.T) {
+	var lines []string
+	for i, name := range recordNames {
+		lines = append(lines, fmt.Sprintf(`{"name": %q, "n": %d}`, name, i))
+		if i == 3 {
+			lines = append(lines, "")
+		}
+	}
+	source := &NDJSONRecordSource{Path: writeFile(t, "records.ndjson", lines)}
+
+	for _, partitions := range []int{1, 3, 10} {
+		for _, pageSize := range []int{1, 2, 100} {
+			require.Equal(t, recordNames, recordData(t, readAll(t, source, partitions, pageSize)), "partitions %d, page size %d", partitions, pageSize)
+		}
+	}
+
+	invalid := &NDJSONRecordSource{Path: writeFile(t, "invalid.ndjson", []string{`{"name": "a"}`, `{"name":`})}
+	_, err := invalid.GetRecords(context.Background(), GetRecordsInput{PageSize: 10, Token: "0", EndToken: "100"})
+	require.ErrorContains(t, err, "invalid JSON at offset 14")
+}
+
+func Test_CSVRecordSource(t *testing.T) {
+	lines := []string{"name,n"}
+	for i, name := range recordNames {
+		lines = append(lines, fmt.Sprintf(`"%s",%d`, name, i))
+	}
+	source := &CSVRecordSource{Path: writeFile(t, "records.csv", lines)}
+
+	for _, partitions := range []int{1, 3, 10} {
+		for _, pageSize := range []int{1, 2, 100} {
+			require.Equal(t, recordNames, recordData(t, readAll(t, source, partitions, pageSize)), "partitions %d, page size %d", partitions, pageSize)
+		}
+	}
+	records := readAll(t, source, 1, 100)
+	require.JSONEq(t, `{"name": "b", "n": "1"}`, string(records[1].Data))
+}
+
+func Test_SQLRecordSource(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "records.db"))
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	// The ids are 10, 12, 14... so that partitions and pages don't line up with the rows
+	_, err = db.Exec("CREATE TABLE records (id INTEGER PRIMARY KEY, name TEXT)")
+	require.NoError(t, err)
+	for i, name := range recordNames {
+		_, err = db.Exec("INSERT INTO records (id, name) VALUES (?, ?)", 10+2*i, name)
+		require.NoError(t, err)
+	}
+	source := &SQLRecordSource{DB: db, Table: "records", IDColumn: "id"}
+
+	for _, partitions := range []int{1, 3, 10} {
+		for _, pageSize := range []int{1, 2, 100} {
+			records := readAll(t, source, partitions, pageSize)
+			require.Equal(t, recordNames, recordData(t, records), "partitions %d, page size %d", partitions, pageSize)
+		}
+	}
+	records := readAll(t, source, 1, 100)
+	require.Equal(t, 10, records[0].Id)
+	require.JSONEq(t, `{"id": 10, "name": "a"}`, string(records[0].Data))
+
+	_, err = db.Exec("DELETE FROM records")
+	require.NoError(t, err)
+	require.Empty(t, readAll(t, source, 3, 100))
+}
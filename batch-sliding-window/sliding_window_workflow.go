@@ -1,6 +1,7 @@
 package batch_sliding_window
 
 import (
+	"encoding/json"
 	"fmt"
 	enumspb "go.temporal.io/api/enums/v1"
 	"go.temporal.io/sdk/workflow"
@@ -8,16 +9,99 @@ import (
 	"time"
 )
 
+const (
+	// ReportCompletionSignal is sent by a RecordProcessorWorkflow to its parent with a RecordCompletion.
+	ReportCompletionSignal = "ReportCompletion"
+	// ReprocessDeadLettersSignal makes a SlidingWindowWorkflow retry its dead-letter records, for example after a fix
+	// has been deployed. It has no arguments.
+	ReprocessDeadLettersSignal = "ReprocessDeadLetters"
+	// PauseUpdate stops a SlidingWindowWorkflow from starting children, the running children complete. It has no
+	// arguments and returns the SlidingWindowSettings.
+	PauseUpdate = "pause"
+	// ResumeUpdate resumes starting children after PauseUpdate. It has no arguments and returns the
+	// SlidingWindowSettings.
+	ResumeUpdate = "resume"
+	// ResizeUpdate changes the window and page sizes of a SlidingWindowWorkflow with a ResizeInput. It returns the
+	// SlidingWindowSettings.
+	ResizeUpdate = "resize"
+)
+
 type (
 	// SlidingWindowWorkflowInput contains SlidingWindowWorkflow arguments
 	SlidingWindowWorkflowInput struct {
 		PageSize          int
 		SlidingWindowSize int
-		Offset            int // inclusive
-		MaximumOffset     int // exclusive
+		Token             string // position of the next page of the RecordSource
+		EndToken          string // end of the partition of the RecordSource
 		Progress          int
 		// The set of ids
 		CurrentRecords map[int]bool // recordId -> ignored boolean
+		// RecordRetry is the retry policy of failed records.
+		RecordRetry RecordRetryPolicy
+		// DeadLetterTimeout is how long the last run waits for a ReprocessDeadLetters signal when records are
+		// dead-lettered, zero completes right away.
+		DeadLetterTimeout time.Duration
+		// FailedRecords are the records that failed and wait for or are in a retry, by recordId.
+		FailedRecords map[int]FailedRecord
+		// DeadLetters are the records that failed all their attempts, by recordId.
+		DeadLetters map[int]FailedRecord
+		// Paused stops starting children until the resume update.
+		Paused bool
+	}
+
+	// ResizeInput is the ResizeUpdate argument, zero values are left unchanged.
+	ResizeInput struct {
+		// SlidingWindowSize applies right away. When it shrinks, the running children complete and new ones start once
+		// the window has room.
+		SlidingWindowSize int
+		// PageSize applies from the next continue-as-new run.
+		PageSize int
+	}
+
+	// SlidingWindowSettings are the effective settings of a SlidingWindowWorkflow.
+	SlidingWindowSettings struct {
+		PageSize          int
+		SlidingWindowSize int
+		Paused            bool
+	}
+
+	// RecordRetryPolicy is the retry policy of failed records. A record is retried after InitialInterval, which
+	// doubles after every failed attempt up to MaximumInterval.
+	RecordRetryPolicy struct {
+		// MaximumAttempts is the number of attempts before a record is dead-lettered, defaults to 3.
+		MaximumAttempts int
+		// InitialInterval defaults to 10 seconds.
+		InitialInterval time.Duration
+		// MaximumInterval defaults to 100 times InitialInterval.
+		MaximumInterval time.Duration
+	}
+
+	// RecordCompletion is the ReportCompletion signal argument.
+	RecordCompletion struct {
+		Id int
+		// Error is the processing error, empty when the record was processed successfully.
+		Error string
+		// Data is the Data of failed records, which is needed to retry them.
+		Data json.RawMessage `json:",omitempty"`
+	}
+
+	// FailedRecord is a record whose processing failed.
+	FailedRecord struct {
+		Id       int
+		Attempts int
+		// Error is the error of the last attempt.
+		Error string
+		// RetryAt is when the next attempt starts, it is zero for dead letters.
+		RetryAt time.Time
+		Data    json.RawMessage `json:",omitempty"`
+	}
+
+	// SlidingWindowWorkflowResult is the SlidingWindowWorkflow result.
+	SlidingWindowWorkflowResult struct {
+		// Processed is the number of records processed successfully.
+		Processed int
+		// DeadLetters are the records that failed all their attempts, sorted by id.
+		DeadLetters []FailedRecord
 	}
 
 	// SlidingWindow structure that implements the workflow logic
@@ -26,12 +110,22 @@ type (
 		// currentRecords represents a set of records that are currently being processed by child workflows.
 		// key is recordId. values are ignored.
 		currentRecords map[int]bool
+		// failedRecords are the failed records that wait for or are in a retry. Records in a retry are also in
+		// currentRecords.
+		failedRecords map[int]FailedRecord
+		// deadLetters are the records that failed all their attempts.
+		deadLetters map[int]FailedRecord
 		// childrenStartedByThisRun is used to wait for children to start before calling continue as new.
 		childrenStartedByThisRun []workflow.ChildWorkflowFuture
-		// Offset into the next record to process.
-		offset int
+		// Token of the next page to process.
+		token string
 		// Count of completed records.
 		progress int
+		// changes counts the events that can allow starting children: completion signals, dead-letter reprocessing
+		// and updates. It wakes up waits for retries.
+		changes int
+		// paused stops starting children.
+		paused bool
 		// completionSignalPumpCancellationHandler is used to request pump completion
 		completionSignalPumpCancellationHandler workflow.CancelFunc
 		// completionSignalPumpCompletion is used to wait for the pump completion.
@@ -43,62 +137,127 @@ type (
 		// currentRecords represents a set of record ids that are currently being processed by child workflows.
 		CurrentRecords           []int
 		ChildrenStartedByThisRun int
-		Offset                   int
+		Token                    string
 		Progress                 int
+		// FailedRecords are the failed records that wait for or are in a retry, sorted by id.
+		FailedRecords []FailedRecord
+		// DeadLetters are the records that failed all their attempts, sorted by id.
+		DeadLetters []FailedRecord
+		SlidingWindowSettings
 	}
 )
 
 // SlidingWindowWorkflow workflow processes a range of records using a requested number of child workflows.
 // As soon as a child workflow completes a new one is started.
-func SlidingWindowWorkflow(ctx workflow.Context, input SlidingWindowWorkflowInput) (recordCount int, err error) {
+// Failed records are retried according to input.RecordRetry, records that fail all their attempts are dead-lettered.
+func SlidingWindowWorkflow(ctx workflow.Context, input SlidingWindowWorkflowInput) (result SlidingWindowWorkflowResult, err error) {
 	workflow.GetLogger(ctx).Info("SlidingWindowWorkflow",
 		"input", input.SlidingWindowSize,
 		"PageSize", input.PageSize,
-		"Offset", input.Offset,
-		"MaximumOffset", input.MaximumOffset,
-		"Progress", input.Progress)
+		"Token", input.Token,
+		"EndToken", input.EndToken,
+		"Progress", input.Progress,
+		"FailedRecords", len(input.FailedRecords),
+		"DeadLetters", len(input.DeadLetters))
 
 	impl := &SlidingWindow{
 		input:          input,
 		currentRecords: input.CurrentRecords,
-		offset:         input.Offset,
+		failedRecords:  input.FailedRecords,
+		deadLetters:    input.DeadLetters,
+		token:          input.Token,
 		progress:       input.Progress,
+		paused:         input.Paused,
 	}
 	if impl.currentRecords == nil {
 		impl.currentRecords = make(map[int]bool)
 	}
+	if impl.failedRecords == nil {
+		impl.failedRecords = make(map[int]FailedRecord)
+	}
+	if impl.deadLetters == nil {
+		impl.deadLetters = make(map[int]FailedRecord)
+	}
 	err = workflow.SetQueryHandler(ctx, "state", func() (SlidingWindowState, error) {
 		return impl.State()
 	})
 	if err != nil {
-		return 0, err
+		return SlidingWindowWorkflowResult{}, err
+	}
+	err = impl.setUpdateHandlers(ctx)
+	if err != nil {
+		return SlidingWindowWorkflowResult{}, err
 	}
 	return impl.Execute(ctx)
 }
 
+// setUpdateHandlers sets the handlers of the updates that pause, resume and resize the sliding window.
+func (s *SlidingWindow) setUpdateHandlers(ctx workflow.Context) error {
+	err := workflow.SetUpdateHandler(ctx, PauseUpdate, func(ctx workflow.Context) (SlidingWindowSettings, error) {
+		s.paused = true
+		workflow.GetLogger(ctx).Info("Paused")
+		return s.settings(), nil
+	})
+	if err != nil {
+		return err
+	}
+	err = workflow.SetUpdateHandler(ctx, ResumeUpdate, func(ctx workflow.Context) (SlidingWindowSettings, error) {
+		s.paused = false
+		s.changes++
+		workflow.GetLogger(ctx).Info("Resumed")
+		return s.settings(), nil
+	})
+	if err != nil {
+		return err
+	}
+	return workflow.SetUpdateHandlerWithOptions(ctx, ResizeUpdate,
+		func(ctx workflow.Context, input ResizeInput) (SlidingWindowSettings, error) {
+			if input.SlidingWindowSize > 0 {
+				s.input.SlidingWindowSize = input.SlidingWindowSize
+			}
+			if input.PageSize > 0 {
+				s.input.PageSize = input.PageSize
+			}
+			s.changes++
+			workflow.GetLogger(ctx).Info("Resized", "SlidingWindowSize", s.input.SlidingWindowSize, "PageSize", s.input.PageSize)
+			return s.settings(), nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: func(ctx workflow.Context, input ResizeInput) error {
+				if input.SlidingWindowSize < 0 || input.PageSize < 0 {
+					return fmt.Errorf("sizes must be positive: %+v", input)
+				}
+				if input.SlidingWindowSize == 0 && input.PageSize == 0 {
+					return fmt.Errorf("no size to change")
+				}
+				return nil
+			},
+		})
+}
+
+func (s *SlidingWindow) settings() SlidingWindowSettings {
+	return SlidingWindowSettings{
+		PageSize:          s.input.PageSize,
+		SlidingWindowSize: s.input.SlidingWindowSize,
+		Paused:            s.paused,
+	}
+}
+
 // State returns the current state of the batch.
 // Used by the "state" workflow query.
 func (s *SlidingWindow) State() (SlidingWindowState, error) {
-	currentRecordIds := make([]int, len(s.currentRecords))
-	i := 0
-	// Range over map is a nondeterministic operation.
-	// It is OK to have a non-deterministic operation in a query function.
-	// Sorting of results makes the result deterministic anyway.
-	//workflowcheck:ignore
-	for k := range s.currentRecords {
-		currentRecordIds[i] = k
-		i++
-	}
-	sort.Ints(currentRecordIds)
 	return SlidingWindowState{
-		CurrentRecords:           currentRecordIds,
+		CurrentRecords:           sortedIds(s.currentRecords),
 		ChildrenStartedByThisRun: len(s.childrenStartedByThisRun),
-		Offset:                   s.offset,
+		Token:                    s.token,
 		Progress:                 s.progress,
+		FailedRecords:            sortedRecords(s.failedRecords),
+		DeadLetters:              sortedRecords(s.deadLetters),
+		SlidingWindowSettings:    s.settings(),
 	}, nil
 }
 
-func (s *SlidingWindow) Execute(ctx workflow.Context) (recordCount int, err error) {
+func (s *SlidingWindow) Execute(ctx workflow.Context) (result SlidingWindowWorkflowResult, err error) {
 	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
 		StartToCloseTimeout: 5 * time.Second,
 	})
@@ -106,49 +265,109 @@ func (s *SlidingWindow) Execute(ctx workflow.Context) (recordCount int, err erro
 	// Starts processing child workflow completion signals asynchronously
 	s.completionSignalPump(ctx)
 
+	// Every run processes a page, the run of the last page completes.
 	var getOutput GetRecordsOutput
-	if s.offset < s.input.MaximumOffset {
-		getInput := &GetRecordsInput{
-			PageSize:  s.input.PageSize,
-			Offset:    s.offset,
-			MaxOffset: s.input.MaximumOffset,
-		}
-		var loader *RecordLoader
-		err = workflow.ExecuteActivity(ctx, loader.GetRecords, getInput).Get(ctx, &getOutput)
-		if err != nil {
-			return 0, err
-		}
+	getInput := GetRecordsInput{
+		PageSize: s.input.PageSize,
+		Token:    s.token,
+		EndToken: s.input.EndToken,
+	}
+	var loader *RecordLoader
+	err = workflow.ExecuteActivity(ctx, loader.GetRecords, getInput).Get(ctx, &getOutput)
+	if err != nil {
+		return SlidingWindowWorkflowResult{}, err
 	}
-	workflowId := workflow.GetInfo(ctx).WorkflowExecution.ID
 	// Process records
 	for _, record := range getOutput.Records {
+		// Retries of failed records are started first when they are due.
+		s.startDueRetries(ctx)
 		// Blocks until the total number of children (including started by the previous runs)
-		// gets below the SlidingWindowSize.
+		// gets below the SlidingWindowSize and the window is not paused.
 		err := workflow.Await(ctx, func() bool {
-			return len(s.currentRecords) < s.input.SlidingWindowSize
+			return !s.paused && len(s.currentRecords) < s.input.SlidingWindowSize
 		})
 		if err != nil {
-			return 0, err
+			return SlidingWindowWorkflowResult{}, err
+		}
+
+		s.startChild(ctx, record, 0)
+	}
+	s.token = getOutput.NextToken
+	return s.continueAsNewOrComplete(ctx, getOutput.Done)
+}
+
+// startChild starts the child workflow processing a record. attempts is the number of failed attempts so far.
+func (s *SlidingWindow) startChild(ctx workflow.Context, record SingleRecord, attempts int) {
+	// Human readable child id.
+	workflowId := fmt.Sprintf("%s/%d", workflow.GetInfo(ctx).WorkflowExecution.ID, record.Id)
+	if attempts > 0 {
+		workflowId = fmt.Sprintf("%s/retry-%d", workflowId, attempts)
+	}
+	options := workflow.ChildWorkflowOptions{
+		// Use ABANDON as child workflows have to survive the parent calling continue-as-new
+		ParentClosePolicy: enumspb.PARENT_CLOSE_POLICY_ABANDON,
+		WorkflowID:        workflowId,
+	}
+	childCtx := workflow.WithChildOptions(ctx, options)
+	child := workflow.ExecuteChildWorkflow(childCtx, RecordProcessorWorkflow, record)
+
+	s.childrenStartedByThisRun = append(s.childrenStartedByThisRun, child)
+	s.currentRecords[record.Id] = true // value is ignored
+}
+
+// startDueRetries starts the retries of failed records whose backoff elapsed while the window has room.
+func (s *SlidingWindow) startDueRetries(ctx workflow.Context) {
+	if s.paused {
+		return
+	}
+	now := workflow.Now(ctx)
+	for _, failed := range sortedRecords(s.failedRecords) {
+		if len(s.currentRecords) >= s.input.SlidingWindowSize {
+			return
+		}
+		if s.currentRecords[failed.Id] || failed.RetryAt.After(now) {
+			continue
 		}
+		s.startChild(ctx, SingleRecord{Id: failed.Id, Data: failed.Data}, failed.Attempts)
+	}
+}
 
-		options := workflow.ChildWorkflowOptions{
-			// Use ABANDON as child workflows have to survive the parent calling continue-as-new
-			ParentClosePolicy: enumspb.PARENT_CLOSE_POLICY_ABANDON,
-			// Human readable child id.
-			WorkflowID: fmt.Sprintf("%s/%d", workflowId, record.Id),
+// nextRetry returns the time of the earliest retry that waits, or false when no retry waits.
+func (s *SlidingWindow) nextRetry() (time.Time, bool) {
+	var next time.Time
+	for _, failed := range sortedRecords(s.failedRecords) {
+		if !s.currentRecords[failed.Id] && (next.IsZero() || failed.RetryAt.Before(next)) {
+			next = failed.RetryAt
 		}
-		childCtx := workflow.WithChildOptions(ctx, options)
-		child := workflow.ExecuteChildWorkflow(childCtx, RecordProcessorWorkflow, record)
+	}
+	return next, !next.IsZero()
+}
 
-		s.childrenStartedByThisRun = append(s.childrenStartedByThisRun, child)
-		s.currentRecords[record.Id] = true // value is ignored
+// awaitRecords waits for all children to complete, while starting the retries of failed records.
+func (s *SlidingWindow) awaitRecords(ctx workflow.Context) error {
+	for len(s.currentRecords) > 0 || len(s.failedRecords) > 0 {
+		s.startDueRetries(ctx)
+		changes := s.changes
+		changed := func() bool { return s.changes != changes }
+		next, ok := s.nextRetry()
+		if !ok || s.paused || !next.After(workflow.Now(ctx)) {
+			// No retry waits, or the due retries wait for room in the window or for the resume update.
+			if err := workflow.Await(ctx, changed); err != nil {
+				return err
+			}
+			continue
+		}
+		// Wakes up on completions, which free the window, and when the next retry is due.
+		if _, err := workflow.AwaitWithTimeout(ctx, next.Sub(workflow.Now(ctx)), changed); err != nil {
+			return err
+		}
 	}
-	return s.continueAsNewOrComplete(ctx)
+	return nil
 }
 
-func (s *SlidingWindow) continueAsNewOrComplete(ctx workflow.Context) (int, error) {
+func (s *SlidingWindow) continueAsNewOrComplete(ctx workflow.Context, lastPage bool) (SlidingWindowWorkflowResult, error) {
 	// Continues-as-new after starting PageSize children
-	if s.offset < s.input.MaximumOffset {
+	if !lastPage {
 		// Waits for all children to start. Without this wait, workflow completion through
 		// continue-as-new might lead to a situation when they never start.
 		for _, child := range s.childrenStartedByThisRun {
@@ -156,7 +375,7 @@ func (s *SlidingWindow) continueAsNewOrComplete(ctx workflow.Context) (int, erro
 			// Is not expected as children's automatically generated
 			// IDs are not expected to collide.
 			if err != nil {
-				return 0, err
+				return SlidingWindowWorkflowResult{}, err
 			}
 		}
 		// Must drain the signal channel without blocking before calling continue-as-new.
@@ -167,22 +386,43 @@ func (s *SlidingWindow) continueAsNewOrComplete(ctx workflow.Context) (int, erro
 		newInput := SlidingWindowWorkflowInput{
 			PageSize:          s.input.PageSize,
 			SlidingWindowSize: s.input.SlidingWindowSize,
-			Offset:            s.input.Offset + len(s.childrenStartedByThisRun),
-			MaximumOffset:     s.input.MaximumOffset,
+			Token:             s.token,
+			EndToken:          s.input.EndToken,
 			Progress:          s.progress,
 			CurrentRecords:    s.currentRecords,
+			RecordRetry:       s.input.RecordRetry,
+			DeadLetterTimeout: s.input.DeadLetterTimeout,
+			FailedRecords:     s.failedRecords,
+			DeadLetters:       s.deadLetters,
+			Paused:            s.paused,
 		}
-		return 0, workflow.NewContinueAsNewError(ctx, SlidingWindowWorkflow, newInput)
+		return SlidingWindowWorkflowResult{}, workflow.NewContinueAsNewError(ctx, SlidingWindowWorkflow, newInput)
 	}
 	// The last run in the continue-as-new chain.
-	// Awaits for all children to complete
-	err := workflow.Await(ctx, func() bool {
-		return len(s.currentRecords) == 0
-	})
-	if err != nil {
-		return 0, err
+	// Awaits for all children to complete, including the retries of failed records.
+	for {
+		err := s.awaitRecords(ctx)
+		if err != nil {
+			return SlidingWindowWorkflowResult{}, err
+		}
+		if len(s.deadLetters) == 0 || s.input.DeadLetterTimeout <= 0 {
+			break
+		}
+		// Gives operators time to deploy a fix and reprocess the dead letters.
+		reprocessed, err := workflow.AwaitWithTimeout(ctx, s.input.DeadLetterTimeout, func() bool {
+			return len(s.deadLetters) == 0
+		})
+		if err != nil {
+			return SlidingWindowWorkflowResult{}, err
+		}
+		if !reprocessed {
+			break
+		}
 	}
-	return s.progress, nil
+	return SlidingWindowWorkflowResult{
+		Processed:   s.progress,
+		DeadLetters: sortedRecords(s.deadLetters),
+	}, nil
 }
 
 func (s *SlidingWindow) drainCompletionSignalChannelAsync(ctx workflow.Context) {
@@ -191,19 +431,23 @@ func (s *SlidingWindow) drainCompletionSignalChannelAsync(ctx workflow.Context)
 	// Wait for the pump to complete to avoid signal loss.
 	_ = s.completionSignalPumpCompletion.Get(ctx, nil)
 
-	reportCompletionChannel := workflow.GetSignalChannel(ctx, "ReportCompletion")
+	reportCompletionChannel := workflow.GetSignalChannel(ctx, ReportCompletionSignal)
 	// Drains signals async
 	for {
-		var recordId int
-		ok := reportCompletionChannel.ReceiveAsync(&recordId)
+		var completion RecordCompletion
+		ok := reportCompletionChannel.ReceiveAsync(&completion)
 		if !ok {
 			break
 		}
-		s.recordCompletion(ctx, recordId)
+		s.recordCompletion(ctx, completion)
+	}
+	reprocessChannel := workflow.GetSignalChannel(ctx, ReprocessDeadLettersSignal)
+	for reprocessChannel.ReceiveAsync(nil) {
+		s.reprocessDeadLetters(ctx)
 	}
 }
 
-// completionSignalPump asynchronously processes ReportCompletion signals.
+// completionSignalPump asynchronously processes ReportCompletion and ReprocessDeadLetters signals.
 // There is no need to clean up the pump goroutine in case a workflow completes due to an error.
 // All goroutines are released back automatically upon workflow completion.
 func (s *SlidingWindow) completionSignalPump(ctx workflow.Context) {
@@ -215,14 +459,19 @@ func (s *SlidingWindow) completionSignalPump(ctx workflow.Context) {
 	completed, completedSettable := workflow.NewFuture(ctx)
 	s.completionSignalPumpCompletion = completed
 
-	reportCompletionChannel := workflow.GetSignalChannel(ctx, "ReportCompletion")
+	reportCompletionChannel := workflow.GetSignalChannel(ctx, ReportCompletionSignal)
+	reprocessChannel := workflow.GetSignalChannel(ctx, ReprocessDeadLettersSignal)
 
 	workflow.Go(ctx, func(ctx workflow.Context) {
 		selector := workflow.NewSelector(ctx)
 		selector.AddReceive(reportCompletionChannel, func(c workflow.ReceiveChannel, more bool) {
-			var recordId int
-			_ = reportCompletionChannel.Receive(ctx, &recordId)
-			s.recordCompletion(ctx, recordId)
+			var completion RecordCompletion
+			_ = reportCompletionChannel.Receive(ctx, &completion)
+			s.recordCompletion(ctx, completion)
+		})
+		selector.AddReceive(reprocessChannel, func(c workflow.ReceiveChannel, more bool) {
+			_ = reprocessChannel.Receive(ctx, nil)
+			s.reprocessDeadLetters(ctx)
 		})
 		selector.AddReceive(ctx.Done(), func(c workflow.ReceiveChannel, more bool) {
 			completedSettable.Set(nil, nil)
@@ -233,10 +482,96 @@ func (s *SlidingWindow) completionSignalPump(ctx workflow.Context) {
 	})
 }
 
-func (s *SlidingWindow) recordCompletion(ctx workflow.Context, recordId int) {
+func (s *SlidingWindow) recordCompletion(ctx workflow.Context, completion RecordCompletion) {
 	// duplicate signal check
-	if _, ok := s.currentRecords[recordId]; ok {
-		delete(s.currentRecords, recordId)
+	if _, ok := s.currentRecords[completion.Id]; !ok {
+		return
+	}
+	delete(s.currentRecords, completion.Id)
+	s.changes++
+	if completion.Error == "" {
+		delete(s.failedRecords, completion.Id)
 		s.progress += 1
+		return
+	}
+
+	failed := s.failedRecords[completion.Id]
+	failed.Id = completion.Id
+	failed.Attempts++
+	failed.Error = completion.Error
+	failed.Data = completion.Data
+	if failed.Attempts >= s.input.RecordRetry.maximumAttempts() {
+		workflow.GetLogger(ctx).Warn("Record dead-lettered", "RecordId", failed.Id, "Attempts", failed.Attempts, "Error", failed.Error)
+		failed.RetryAt = time.Time{}
+		delete(s.failedRecords, failed.Id)
+		s.deadLetters[failed.Id] = failed
+		return
+	}
+	failed.RetryAt = workflow.Now(ctx).Add(s.input.RecordRetry.backoff(failed.Attempts))
+	s.failedRecords[failed.Id] = failed
+}
+
+// reprocessDeadLetters retries the dead letters right away with all their attempts.
+func (s *SlidingWindow) reprocessDeadLetters(ctx workflow.Context) {
+	workflow.GetLogger(ctx).Info("Reprocessing dead letters", "Count", len(s.deadLetters))
+	now := workflow.Now(ctx)
+	for _, failed := range sortedRecords(s.deadLetters) {
+		failed.Attempts = 0
+		failed.RetryAt = now
+		s.failedRecords[failed.Id] = failed
+		delete(s.deadLetters, failed.Id)
+	}
+	s.changes++
+}
+
+func (p RecordRetryPolicy) maximumAttempts() int {
+	if p.MaximumAttempts <= 0 {
+		return 3
+	}
+	return p.MaximumAttempts
+}
+
+// backoff returns the interval before the next attempt after attempts failed attempts.
+func (p RecordRetryPolicy) backoff(attempts int) time.Duration {
+	interval := p.InitialInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	maximum := p.MaximumInterval
+	if maximum <= 0 {
+		maximum = 100 * interval
 	}
+	for i := 1; i < attempts && interval < maximum; i++ {
+		interval *= 2
+	}
+	if interval > maximum {
+		interval = maximum
+	}
+	return interval
+}
+
+// sortedIds returns the keys of a record set in a deterministic order.
+func sortedIds(records map[int]bool) []int {
+	ids := make([]int, 0, len(records))
+	// Range over map is a nondeterministic operation.
+	// Sorting of results makes the result deterministic.
+	//workflowcheck:ignore
+	for id := range records {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// sortedRecords returns the failed records sorted by id, so they are processed in a deterministic order.
+func sortedRecords(records map[int]FailedRecord) []FailedRecord {
+	result := make([]FailedRecord, 0, len(records))
+	//workflowcheck:ignore
+	for _, failed := range records {
+		result = append(result, failed)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Id < result[j].Id
+	})
+	return result
 }
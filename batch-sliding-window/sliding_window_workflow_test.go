@@ -0,0 +1,191 @@
+package batch_sliding_window
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+// newTestEnvironment returns an environment whose RecordProcessorWorkflow children take a minute and fail for the
+// records in failures until their count of failures is used up.
+func newTestEnvironment(failures map[int]int) *testsuite.TestWorkflowEnvironment {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	env.RegisterActivity(&RecordLoader{Source: &SyntheticRecordSource{RecordCount: 5}})
+	env.OnWorkflow(RecordProcessorWorkflow, mock.Anything, mock.Anything).Return(
+		func(ctx workflow.Context, r SingleRecord) error {
+			if err := workflow.Sleep(ctx, time.Minute); err != nil {
+				return err
+			}
+			completion := RecordCompletion{Id: r.Id}
+			if failures[r.Id] > 0 {
+				failures[r.Id]--
+				completion.Error = "record is invalid"
+			}
+			parent := workflow.GetInfo(ctx).ParentWorkflowExecution
+			err := workflow.SignalExternalWorkflow(ctx, parent.ID, "", ReportCompletionSignal, completion).Get(ctx, nil)
+			if err != nil {
+				return err
+			}
+			if completion.Error != "" {
+				return errors.New(completion.Error)
+			}
+			return nil
+		})
+	return env
+}
+
+func Test_SlidingWindow_RetryAndDeadLetter(t *testing.T) {
+	// Record 1 succeeds on its second attempt, record 3 fails all its attempts.
+	env := newTestEnvironment(map[int]int{1: 1, 3: 100})
+	env.ExecuteWorkflow(SlidingWindowWorkflow, SlidingWindowWorkflowInput{
+		PageSize:          5,
+		SlidingWindowSize: 2,
+		Token:             "0",
+		EndToken:          "5",
+		RecordRetry:       RecordRetryPolicy{MaximumAttempts: 3, InitialInterval: time.Minute},
+	})
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	var result SlidingWindowWorkflowResult
+	require.NoError(t, env.GetWorkflowResult(&result))
+	require.Equal(t, 4, result.Processed)
+	require.Len(t, result.DeadLetters, 1)
+	require.Equal(t, 3, result.DeadLetters[0].Id)
+	require.Equal(t, 3, result.DeadLetters[0].Attempts)
+	require.Equal(t, "record is invalid", result.DeadLetters[0].Error)
+
+	var state SlidingWindowState
+	value, err := env.QueryWorkflow("state")
+	require.NoError(t, err)
+	require.NoError(t, value.Get(&state))
+	require.Empty(t, state.FailedRecords)
+	require.Equal(t, result.DeadLetters, state.DeadLetters)
+}
+
+func Test_SlidingWindow_ReprocessDeadLetters(t *testing.T) {
+	// Record 3 fails all its first attempts, then succeeds after the fix.
+	env := newTestEnvironment(map[int]int{3: 2})
+	env.RegisterDelayedCallback(func() {
+		value, err := env.QueryWorkflow("state")
+		require.NoError(t, err)
+		var state SlidingWindowState
+		require.NoError(t, value.Get(&state))
+		require.Len(t, state.DeadLetters, 1)
+
+		env.SignalWorkflow(ReprocessDeadLettersSignal, nil)
+	}, time.Hour)
+	env.ExecuteWorkflow(SlidingWindowWorkflow, SlidingWindowWorkflowInput{
+		PageSize:          5,
+		SlidingWindowSize: 2,
+		Token:             "0",
+		EndToken:          "5",
+		RecordRetry:       RecordRetryPolicy{MaximumAttempts: 2, InitialInterval: time.Minute},
+		DeadLetterTimeout: 24 * time.Hour,
+	})
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	var result SlidingWindowWorkflowResult
+	require.NoError(t, env.GetWorkflowResult(&result))
+	require.Equal(t, 5, result.Processed)
+	require.Empty(t, result.DeadLetters)
+}
+
+func Test_SlidingWindow_PauseResumeResize(t *testing.T) {
+	env := newTestEnvironment(nil)
+	// update sends an update, whose result is checked once the workflow handles it.
+	completedUpdates := 0
+	update := func(name string, check func(SlidingWindowSettings), args ...interface{}) {
+		env.UpdateWorkflow(name, "", &testsuite.TestUpdateCallback{
+			OnAccept: func() {},
+			OnReject: func(err error) { require.Fail(t, "update rejected", err) },
+			OnComplete: func(result interface{}, err error) {
+				require.NoError(t, err)
+				check(result.(SlidingWindowSettings))
+				completedUpdates++
+			},
+		}, args...)
+	}
+	state := func() SlidingWindowState {
+		value, err := env.QueryWorkflow("state")
+		require.NoError(t, err)
+		var state SlidingWindowState
+		require.NoError(t, value.Get(&state))
+		return state
+	}
+
+	env.RegisterDelayedCallback(func() {
+		update(PauseUpdate, func(settings SlidingWindowSettings) {
+			require.True(t, settings.Paused)
+		})
+	}, 30*time.Second)
+	env.RegisterDelayedCallback(func() {
+		// The first two children completed and no new ones started.
+		s := state()
+		require.True(t, s.Paused)
+		require.Equal(t, 2, s.Progress)
+		require.Empty(t, s.CurrentRecords)
+
+		update(ResizeUpdate, func(settings SlidingWindowSettings) {
+			require.Equal(t, SlidingWindowSettings{PageSize: 5, SlidingWindowSize: 3, Paused: true}, settings)
+		}, ResizeInput{SlidingWindowSize: 3})
+		update(ResumeUpdate, func(settings SlidingWindowSettings) {
+			require.False(t, settings.Paused)
+		})
+	}, 10*time.Minute)
+	env.RegisterDelayedCallback(func() {
+		require.Len(t, state().CurrentRecords, 3)
+	}, 10*time.Minute+30*time.Second)
+
+	env.ExecuteWorkflow(SlidingWindowWorkflow, SlidingWindowWorkflowInput{
+		PageSize:          5,
+		SlidingWindowSize: 2,
+		Token:             "0",
+		EndToken:          "5",
+	})
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var result SlidingWindowWorkflowResult
+	require.NoError(t, env.GetWorkflowResult(&result))
+	require.Equal(t, 5, result.Processed)
+	require.Equal(t, 3, completedUpdates)
+}
+
+func Test_SlidingWindow_ResizeValidation(t *testing.T) {
+	env := newTestEnvironment(nil)
+	rejectedUpdates := 0
+	env.RegisterDelayedCallback(func() {
+		for _, input := range []ResizeInput{{}, {SlidingWindowSize: -1}} {
+			env.UpdateWorkflow(ResizeUpdate, "", &testsuite.TestUpdateCallback{
+				OnAccept:   func() { require.Fail(t, "update accepted") },
+				OnReject:   func(err error) { rejectedUpdates++ },
+				OnComplete: func(interface{}, error) {},
+			}, input)
+		}
+	}, 30*time.Second)
+	env.ExecuteWorkflow(SlidingWindowWorkflow, SlidingWindowWorkflowInput{
+		PageSize:          5,
+		SlidingWindowSize: 2,
+		Token:             "0",
+		EndToken:          "5",
+	})
+	require.NoError(t, env.GetWorkflowError())
+	require.Equal(t, 2, rejectedUpdates)
+}
+
+func Test_RecordRetryPolicy_Backoff(t *testing.T) {
+	policy := RecordRetryPolicy{InitialInterval: time.Second, MaximumInterval: 5 * time.Second}
+	require.Equal(t, time.Second, policy.backoff(1))
+	require.Equal(t, 2*time.Second, policy.backoff(2))
+	require.Equal(t, 4*time.Second, policy.backoff(3))
+	require.Equal(t, 5*time.Second, policy.backoff(4))
+	require.Equal(t, 3, RecordRetryPolicy{}.maximumAttempts())
+	require.Equal(t, 10*time.Second, RecordRetryPolicy{}.backoff(1))
+}
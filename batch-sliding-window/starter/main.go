@@ -32,10 +32,14 @@ func main() {
 
 	// Wait for Workflow Execution completion.
 	// This is rarely needed in real use cases as batch workflows are usually long-running.
-	var result int
+	var result batch_sliding_window.ProcessBatchWorkflowResult
 	err = we.Get(ctx, &result)
 	if err != nil {
 		panic(err)
 	}
-	log.Println("Completed workflow", "WorkflowID", we.GetID(), "RunID", we.GetRunID(), "Result", result)
+	log.Println("Completed workflow", "WorkflowID", we.GetID(), "RunID", we.GetRunID(),
+		"Processed", result.Processed, "DeadLetters", len(result.DeadLetters))
+	for _, deadLetter := range result.DeadLetters {
+		log.Println("Dead letter", "RecordId", deadLetter.Id, "Attempts", deadLetter.Attempts, "Error", deadLetter.Error)
+	}
 }
@@ -1,21 +1,54 @@
 package main
 
 import (
+	"database/sql"
+	"flag"
 	batch_sliding_window "github.com/temporalio/samples-go/batch-sliding-window"
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 	"log"
 
+	_ "github.com/mattn/go-sqlite3"
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/worker"
 )
 
 func main() {
+	var sourceFlag, fileFlag, sqlDriverFlag, sqlDSNFlag, sqlTableFlag, sqlIDColumnFlag string
+	flag.StringVar(&sourceFlag, "source", "synthetic", "Record source: synthetic, ndjson, csv or sql")
+	flag.StringVar(&fileFlag, "file", "", "File of the ndjson and csv sources")
+	flag.StringVar(&sqlDriverFlag, "sql-driver", "sqlite3", "database/sql driver of the sql source, it must be imported by the worker")
+	flag.StringVar(&sqlDSNFlag, "sql-dsn", "", "Data source name of the sql source")
+	flag.StringVar(&sqlTableFlag, "sql-table", "records", "Table of the sql source")
+	flag.StringVar(&sqlIDColumnFlag, "sql-id-column", "id", "Integer id column of the sql source")
+	flag.Parse()
+
+	var source batch_sliding_window.RecordSource
+	switch sourceFlag {
+	case "synthetic":
+		source = &batch_sliding_window.SyntheticRecordSource{RecordCount: 90}
+	case "ndjson":
+		source = &batch_sliding_window.NDJSONRecordSource{Path: fileFlag}
+	case "csv":
+		source = &batch_sliding_window.CSVRecordSource{Path: fileFlag}
+	case "sql":
+		db, err := sql.Open(sqlDriverFlag, sqlDSNFlag)
+		if err != nil {
+			log.Fatalln("Unable to open database", err)
+		}
+		defer func() { _ = db.Close() }()
+		source = &batch_sliding_window.SQLRecordSource{DB: db, Table: sqlTableFlag, IDColumn: sqlIDColumnFlag}
+	default:
+		log.Fatalln("Unknown record source", sourceFlag)
+	}
+
 	// The client and worker are heavyweight objects that should be created once per process.
-	c, err := client.Dial(client.Options{
+	c, shutdown, err := bootstrap.Dial(client.Options{
 		HostPort: client.DefaultHostPort,
 	})
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
 	w := worker.New(c, "batch-sliding-window", worker.Options{})
@@ -24,7 +57,7 @@ func main() {
 	w.RegisterWorkflow(batch_sliding_window.SlidingWindowWorkflow)
 	w.RegisterWorkflow(batch_sliding_window.RecordProcessorWorkflow)
 
-	w.RegisterActivity(&batch_sliding_window.RecordLoader{RecordCount: 90})
+	w.RegisterActivity(&batch_sliding_window.RecordLoader{Source: source})
 
 	err = w.Run(worker.InterruptCh())
 	if err != nil {
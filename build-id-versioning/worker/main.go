@@ -3,6 +3,7 @@ package main
 import (
 	"github.com/pborman/uuid"
 	build_id_versioning "github.com/temporalio/samples-go/build-id-versioning"
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 	"sync"
 
 	"log"
@@ -13,12 +14,13 @@ import (
 )
 
 func main() {
-	c, err := client.Dial(client.Options{
+	c, shutdown, err := bootstrap.Dial(client.Options{
 		HostPort: client.DefaultHostPort,
 	})
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
 	taskQueue := "build-id-versioning-" + uuid.New()
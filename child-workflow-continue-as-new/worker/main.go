@@ -7,17 +7,19 @@ import (
 	"go.temporal.io/sdk/worker"
 
 	cw "github.com/temporalio/samples-go/child-workflow-continue-as-new"
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 )
 
 // @@@SNIPSTART samples-go-cw-cas-worker-starter
 func main() {
 	// The client and worker are heavyweight objects that should be created once per process.
-	c, err := client.Dial(client.Options{
+	c, shutdown, err := bootstrap.Dial(client.Options{
 		HostPort: client.DefaultHostPort,
 	})
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
 	w := worker.New(c, "child-workflow-continue-as-new", worker.Options{})
@@ -7,17 +7,19 @@ import (
 	"go.temporal.io/sdk/worker"
 
 	child_workflow "github.com/temporalio/samples-go/child-workflow"
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 )
 
 // @@@SNIPSTART samples-go-child-workflow-example-worker-starter
 func main() {
 	// The client is a heavyweight object that should be created only once per process.
-	c, err := client.Dial(client.Options{
+	c, shutdown, err := bootstrap.Dial(client.Options{
 		HostPort: client.DefaultHostPort,
 	})
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
 	w := worker.New(c, "child-workflow", worker.Options{})
@@ -7,16 +7,18 @@ import (
 	"go.temporal.io/sdk/worker"
 
 	choice "github.com/temporalio/samples-go/choice-exclusive"
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 )
 
 func main() {
 	// The client and worker are heavyweight objects that should be created once per process.
-	c, err := client.Dial(client.Options{
+	c, shutdown, err := bootstrap.Dial(client.Options{
 		HostPort: client.DefaultHostPort,
 	})
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
 	w := worker.New(c, "choice", worker.Options{})
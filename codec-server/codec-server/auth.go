@@ -0,0 +1,238 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// PermissionEncode allows /encode requests.
+	PermissionEncode = "encode"
+	// PermissionDecode allows /decode requests.
+	PermissionDecode = "decode"
+)
+
+// AuthConfig is the authentication configuration file, for example:
+//
+//	{
+//	  "issuers": [{
+//	    "url": "https://sso.example.com/",
+//	    "audience": "codec-server",
+//	    "claimMappings": [
+//	      {"claim": "groups", "value": "payments", "namespaces": ["payments"], "permissions": ["decode"]}
+//	    ]
+//	  }],
+//	  "apiKeysFile": "api-keys.json",
+//	  "clientCertificates": [
+//	    {"commonName": "payments-worker", "namespaces": ["payments"], "permissions": ["encode", "decode"]}
+//	  ]
+//	}
+type AuthConfig struct {
+	// Issuers are the accepted OIDC providers.
+	Issuers []IssuerConfig `json:"issuers,omitempty"`
+	// APIKeysFile is the path of an APIKeysFile, relative to the AuthConfig file.
+	APIKeysFile string `json:"apiKeysFile,omitempty"`
+	// ClientCertificates grant permissions to TLS client certificates, which requires -tls-client-ca.
+	ClientCertificates []ClientCertificateConfig `json:"clientCertificates,omitempty"`
+}
+
+// IssuerConfig configures an OIDC provider.
+type IssuerConfig struct {
+	URL string `json:"url"`
+	// Audience is the default audience of tokens, namespaces can override it.
+	Audience string `json:"audience,omitempty"`
+	// PermissionsClaim is the claim holding Temporal permissions such as "default:read", defaults to "permissions".
+	PermissionsClaim string `json:"permissionsClaim,omitempty"`
+	// ClaimMappings grant permissions to tokens with specific claim values.
+	ClaimMappings []ClaimMapping `json:"claimMappings,omitempty"`
+}
+
+// ClaimMapping grants permissions to tokens whose Claim is Value or, for list claims, contains Value.
+type ClaimMapping struct {
+	Claim string `json:"claim"`
+	Value string `json:"value"`
+	Grant
+}
+
+// APIKeysFile holds the SHA-256 hashes of the API keys, API keys are sent as bearer tokens.
+type APIKeysFile struct {
+	Keys []APIKey `json:"keys"`
+}
+
+// APIKey is an API key of an APIKeysFile.
+type APIKey struct {
+	// Name is used as subject.
+	Name string `json:"name"`
+	// SHA256 is the hex encoded SHA-256 hash of the key.
+	SHA256 string `json:"sha256"`
+	Grant
+}
+
+// ClientCertificateConfig grants permissions to TLS client certificates with the CommonName.
+type ClientCertificateConfig struct {
+	CommonName string `json:"commonName"`
+	Grant
+}
+
+// Grant allows Permissions on Namespaces, "*" matches all namespaces.
+type Grant struct {
+	Namespaces  []string `json:"namespaces"`
+	Permissions []string `json:"permissions"`
+}
+
+// Identity is the authenticated caller of a codec request.
+type Identity struct {
+	Subject string
+	// Method is "oidc", "api-key" or "mtls".
+	Method string
+	Grants []Grant
+	// claims are the token claims of OIDC identities.
+	claims map[string]interface{}
+}
+
+// authenticator authenticates codec requests by TLS client certificate, API key or OIDC token, in that order.
+type authenticator struct {
+	providers          []*Provider
+	apiKeys            map[string]APIKey
+	clientCertificates map[string]ClientCertificateConfig
+}
+
+var errNoCredentials = errors.New("no credentials")
+
+func loadAuthConfig(path string) (*AuthConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var authConfig AuthConfig
+	if err := json.Unmarshal(data, &authConfig); err != nil {
+		return nil, fmt.Errorf("failed parsing auth config %s: %w", path, err)
+	}
+	if authConfig.APIKeysFile != "" && !filepath.IsAbs(authConfig.APIKeysFile) {
+		authConfig.APIKeysFile = filepath.Join(filepath.Dir(path), authConfig.APIKeysFile)
+	}
+	return &authConfig, nil
+}
+
+// newAuthenticator creates the authenticator of the config, it discovers the OIDC providers.
+func newAuthenticator(authConfig *AuthConfig) (*authenticator, error) {
+	a := &authenticator{
+		apiKeys:            make(map[string]APIKey),
+		clientCertificates: make(map[string]ClientCertificateConfig),
+	}
+	for _, issuer := range authConfig.Issuers {
+		p, err := newProvider(issuer.URL, issuer.PermissionsClaim)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OIDC provider %s: %w", issuer.URL, err)
+		}
+		p.Audience = issuer.Audience
+		p.ClaimMappings = issuer.ClaimMappings
+		a.providers = append(a.providers, p)
+	}
+	if authConfig.APIKeysFile != "" {
+		data, err := os.ReadFile(authConfig.APIKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		var keys APIKeysFile
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return nil, fmt.Errorf("failed parsing API keys %s: %w", authConfig.APIKeysFile, err)
+		}
+		for _, key := range keys.Keys {
+			a.apiKeys[strings.ToLower(key.SHA256)] = key
+		}
+	}
+	for _, cert := range authConfig.ClientCertificates {
+		a.clientCertificates[cert.CommonName] = cert
+	}
+	return a, nil
+}
+
+// authenticate returns the identity of the request. Tokens of OIDC providers must be issued for audience when it is
+// set.
+func (a *authenticator) authenticate(r *http.Request, audience string) (*Identity, error) {
+	// Only verified certificates identify clients, the TLS config requests but doesn't require them.
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		commonName := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		if cert, ok := a.clientCertificates[commonName]; ok {
+			return &Identity{Subject: commonName, Method: "mtls", Grants: []Grant{cert.Grant}}, nil
+		}
+	}
+
+	token := r.Header.Get("Authorization")
+	if token == "" {
+		return nil, errNoCredentials
+	}
+
+	hash := sha256.Sum256([]byte(strings.TrimPrefix(token, "Bearer ")))
+	if key, ok := a.apiKeys[hex.EncodeToString(hash[:])]; ok {
+		return &Identity{Subject: key.Name, Method: "api-key", Grants: []Grant{key.Grant}}, nil
+	}
+
+	p, err := a.provider(token)
+	if err != nil {
+		return nil, err
+	}
+	return p.Authenticate(token, audience)
+}
+
+// provider returns the provider that issued the token.
+func (a *authenticator) provider(token string) (*Provider, error) {
+	claims, err := parseTokenClaims(token)
+	if err != nil {
+		return nil, err
+	}
+	issuer, _ := claims["iss"].(string)
+	for _, p := range a.providers {
+		if strings.TrimSuffix(p.Issuer, "/") == strings.TrimSuffix(issuer, "/") {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown issuer %q", issuer)
+}
+
+// allows reports whether the identity has the permission on the namespace.
+func (i *Identity) allows(namespace, permission string) bool {
+	for _, grant := range i.Grants {
+		if contains(grant.Permissions, permission) && (contains(grant.Namespaces, namespace) || contains(grant.Namespaces, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasClaim reports whether the identity has a token with the claim set. API key and client certificate identities
+// have no claims.
+func (i *Identity) hasClaim(claim string) bool {
+	return isSet(i.claims[claim])
+}
+
+func (m ClaimMapping) matches(claims map[string]interface{}) bool {
+	switch value := claims[m.Claim].(type) {
+	case string:
+		return value == m.Value
+	case []interface{}:
+		for _, v := range value {
+			if s, ok := v.(string); ok && s == m.Value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
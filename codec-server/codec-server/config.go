@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	codecserver "github.com/temporalio/samples-go/codec-server"
+
+	"go.temporal.io/sdk/converter"
+)
+
+// Config is the codec server configuration file, for example:
+//
+//	{
+//	  "keys": {"key-1": "<base64 encoded 32 byte key>"},
+//	  "namespaces": {
+//	    "default": {"codecs": [{"name": "snappy"}]},
+//	    "payments": {
+//	      "codecs": [{"name": "encryption", "keyId": "key-1"}, {"name": "snappy"}],
+//	      "audience": "payments-codec"
+//	    }
+//	  }
+//	}
+type Config struct {
+	// Keys are the encryption keys by key ID. Keys must be fetched from secure storage in production (such as a KMS).
+	Keys map[string][]byte `json:"keys,omitempty"`
+	// Namespaces maps the served namespaces to their configuration.
+	Namespaces map[string]NamespaceConfig `json:"namespaces"`
+}
+
+// NamespaceConfig configures a namespace.
+type NamespaceConfig struct {
+	// Codecs is the codec chain, in the order passed to converter.NewCodecDataConverter: on encode the codecs are
+	// applied last to first.
+	Codecs []CodecConfig `json:"codecs"`
+	// Audience is the OIDC audience of the tokens for this namespace. Defaults to the Audience of the issuer of the
+	// token, which is the -audience flag for the -provider issuer.
+	Audience string `json:"audience,omitempty"`
+}
+
+// CodecConfig configures a codec of a chain.
+type CodecConfig struct {
+	// Name is one of "snappy", "zlib" or "encryption".
+	Name string `json:"name"`
+	// KeyID is the key the "encryption" codec encrypts with. Payloads of all keys are decrypted.
+	KeyID string `json:"keyId,omitempty"`
+}
+
+// defaultConfig is used without a -config flag, it only handles the snappy codec for the default namespace.
+var defaultConfig = &Config{
+	Namespaces: map[string]NamespaceConfig{
+		"default": {Codecs: []CodecConfig{{Name: "snappy"}}},
+	},
+}
+
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed parsing config %s: %w", path, err)
+	}
+	if len(config.Namespaces) == 0 {
+		return nil, fmt.Errorf("config %s has no namespaces", path)
+	}
+	return &config, nil
+}
+
+// codecChains creates the codec chains of all namespaces.
+func (c *Config) codecChains() (map[string][]converter.PayloadCodec, error) {
+	chains := make(map[string][]converter.PayloadCodec, len(c.Namespaces))
+	for namespace, namespaceConfig := range c.Namespaces {
+		if len(namespaceConfig.Codecs) == 0 {
+			return nil, fmt.Errorf("namespace %s has no codecs", namespace)
+		}
+		for _, codecConfig := range namespaceConfig.Codecs {
+			codec, err := c.newCodec(codecConfig)
+			if err != nil {
+				return nil, fmt.Errorf("namespace %s: %w", namespace, err)
+			}
+			chains[namespace] = append(chains[namespace], codec)
+		}
+	}
+	return chains, nil
+}
+
+func (c *Config) newCodec(codecConfig CodecConfig) (converter.PayloadCodec, error) {
+	switch codecConfig.Name {
+	case "snappy":
+		return codecserver.NewPayloadCodec(), nil
+	case "zlib":
+		return converter.NewZlibCodec(converter.ZlibCodecOptions{AlwaysEncode: true}), nil
+	case "encryption":
+		return codecserver.NewEncryptionCodec(codecConfig.KeyID, c.Keys)
+	default:
+		return nil, fmt.Errorf("unknown codec %q", codecConfig.Name)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var testKey = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+
+const defaultOnlyConfig = `{"namespaces": {"default": {"codecs": [{"name": "snappy"}]}}}`
+
+var paymentsConfig = `{
+  "keys": {"key-1": "` + testKey + `"},
+  "namespaces": {
+    "default": {"codecs": [{"name": "snappy"}]},
+    "payments": {"codecs": [{"name": "encryption", "keyId": "key-1"}, {"name": "zlib"}], "audience": "payments-codec"}
+  }
+}`
+
+// writeConfig replaces the file atomically, so that watchFile never sees it half written.
+func writeConfig(t *testing.T, path, config string) {
+	require.NoError(t, os.WriteFile(path+".tmp", []byte(config), 0600))
+	require.NoError(t, os.Rename(path+".tmp", path))
+}
+
+func Test_LoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, paymentsConfig)
+
+	config, err := loadConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, bytes.Repeat([]byte{1}, 32), config.Keys["key-1"])
+	require.Equal(t, "payments-codec", config.Namespaces["payments"].Audience)
+	chains, err := config.codecChains()
+	require.NoError(t, err)
+	require.Len(t, chains["default"], 1)
+	require.Len(t, chains["payments"], 2)
+}
+
+func Test_LoadConfig_Errors(t *testing.T) {
+	dir := t.TempDir()
+	_, err := loadConfig(filepath.Join(dir, "missing.json"))
+	require.Error(t, err)
+
+	for name, test := range map[string]struct {
+		config string
+		err    string
+	}{
+		"invalid JSON":   {`{"namespaces":`, "failed parsing config"},
+		"no namespaces":  {`{"namespaces": {}}`, "has no namespaces"},
+		"no codecs":      {`{"namespaces": {"default": {}}}`, "namespace default has no codecs"},
+		"unknown codec":  {`{"namespaces": {"default": {"codecs": [{"name": "gzip"}]}}}`, `unknown codec "gzip"`},
+		"unknown key":    {`{"namespaces": {"default": {"codecs": [{"name": "encryption", "keyId": "key-1"}]}}}`, `unknown encryption key "key-1"`},
+		"short key":      {`{"keys": {"key-1": "AAAA"}, "namespaces": {"default": {"codecs": [{"name": "encryption", "keyId": "key-1"}]}}}`, "must be 16, 24 or 32 bytes long"},
+		"key not base64": {`{"keys": {"key-1": "not base64!"}, "namespaces": {"default": {"codecs": [{"name": "snappy"}]}}}`, "failed parsing config"},
+	} {
+		path := filepath.Join(dir, strings.ReplaceAll(name, " ", "-")+".json")
+		writeConfig(t, path, test.config)
+		config, err := loadConfig(path)
+		if err == nil {
+			_, err = config.codecChains()
+		}
+		require.ErrorContains(t, err, test.err, name)
+	}
+}
+
+func Test_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, path, defaultOnlyConfig)
+	m := &codecMiddleware{metrics: newCodecMetrics(prometheus.NewRegistry())}
+	load := func() (http.Handler, error) {
+		config, err := loadConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		return newPayloadCodecNamespacesHTTPHandler(config, m)
+	}
+	reloadable := &reloadableHandler{}
+	require.NoError(t, reloadable.reload(load))
+	server := httptest.NewServer(reloadable)
+	defer server.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	reloaded := make(chan error, 10)
+	go watchFile(path, 10*time.Millisecond, done, func() { reloaded <- reloadable.reload(load) })
+
+	payload, err := converter.GetDefaultDataConverter().ToPayload("secret")
+	require.NoError(t, err)
+	payloads, err := protojson.Marshal(&commonpb.Payloads{Payloads: []*commonpb.Payload{payload}})
+	require.NoError(t, err)
+	status, _ := post(t, server.URL+"/payments/encode", string(payloads))
+	require.Equal(t, http.StatusNotFound, status)
+
+	// A change of the file adds the payments namespace
+	writeConfig(t, path, paymentsConfig)
+	require.NoError(t, <-reloaded)
+	status, encoded := post(t, server.URL+"/payments/encode", string(payloads))
+	require.Equal(t, http.StatusOK, status)
+	require.NotContains(t, encoded, base64.StdEncoding.EncodeToString(payload.Data))
+
+	// A bad file keeps the previous handler
+	writeConfig(t, path, `{"namespaces":`)
+	require.ErrorContains(t, <-reloaded, "failed parsing config")
+	status, decoded := post(t, server.URL+"/payments/decode", encoded)
+	require.Equal(t, http.StatusOK, status)
+	var result commonpb.Payloads
+	require.NoError(t, protojson.Unmarshal([]byte(decoded), &result))
+	var value string
+	require.NoError(t, converter.GetDefaultDataConverter().FromPayload(result.Payloads[0], &value))
+	require.Equal(t, "secret", value)
+}
+
+// post posts body to url and returns the status code and the response body.
+func post(t *testing.T, url, body string) (int, string) {
+	res, err := http.Post(url, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer func() { _ = res.Body.Close() }()
+	var b bytes.Buffer
+	_, err = b.ReadFrom(res.Body)
+	require.NoError(t, err)
+	return res.StatusCode, b.String()
+}
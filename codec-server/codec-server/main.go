@@ -1,18 +1,24 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"syscall"
+	"time"
 
-	codecserver "github.com/temporalio/samples-go/codec-server"
-
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.temporal.io/sdk/converter"
 	"go.temporal.io/server/common/log"
 	"go.temporal.io/server/common/log/tag"
+	"golang.org/x/time/rate"
 )
 
 var logger log.Logger
@@ -32,33 +38,24 @@ func newCORSHTTPHandler(web string, next http.Handler) http.Handler {
 	})
 }
 
-// newPayloadEncoderOauthHTTPHandler wraps a HTTP handler with oauth support
-func newPayloadEncoderOauthHTTPHandler(provider *Provider, namespace string, next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if provider.Authorize(namespace, r) {
-			next.ServeHTTP(w, r)
-			return
-		}
-
-		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
-	})
-}
-
 // HTTP handler for codecs.
 // This remote codec server example supports URLs like: /{namespace}/encode and /{namespace}/decode
 // For example, for the default namespace you would hit /default/encode and /default/decode
 // It will also accept URLs: /encode and /decode with the X-Namespace set to indicate the namespace.
-func newPayloadCodecNamespacesHTTPHandler(encoders map[string][]converter.PayloadCodec, provider *Provider) http.Handler {
+// Every handler is wrapped by middleware, which enforces oauth, rate limits, audit logging and metrics as configured.
+func newPayloadCodecNamespacesHTTPHandler(config *Config, middleware *codecMiddleware) (http.Handler, error) {
+	encoders, err := config.codecChains()
+	if err != nil {
+		return nil, err
+	}
+
 	mux := http.NewServeMux()
 
 	codecHandlers := make(map[string]http.Handler, len(encoders))
 	for namespace, codecChain := range encoders {
 		fmt.Printf("Handling namespace: %s\n", namespace)
 
-		handler := converter.NewPayloadCodecHTTPHandler(codecChain...)
-		if provider != nil {
-			handler = newPayloadEncoderOauthHTTPHandler(provider, namespace, handler)
-		}
+		handler := middleware.wrap(namespace, config.Namespaces[namespace].Audience, converter.NewPayloadCodecHTTPHandler(codecChain...))
 		mux.Handle("/"+namespace+"/", handler)
 
 		codecHandlers[namespace] = handler
@@ -75,15 +72,25 @@ func newPayloadCodecNamespacesHTTPHandler(encoders map[string][]converter.Payloa
 		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 	}))
 
-	return mux
+	return mux, nil
 }
 
 var portFlag int
 var providerFlag string
 var audienceFlag string
 var webFlag string
+var configFlag string
+var watchFlag time.Duration
+var auditLogFlag string
+var rateLimitFlag float64
+var rateBurstFlag int
+var redactClaimFlag string
+var authFlag string
+var tlsCertFlag string
+var tlsKeyFlag string
+var tlsClientCAFlag string
 
-var provider *Provider
+var middleware = &codecMiddleware{}
 
 func init() {
 	logger = log.NewCLILogger()
@@ -92,32 +99,109 @@ func init() {
 	flag.StringVar(&providerFlag, "provider", "", "OIDC Provider URL. Optional: Enforces oauth authentication")
 	flag.StringVar(&audienceFlag, "audience", "", "OIDC Audience. Optional")
 	flag.StringVar(&webFlag, "web", "", "Temporal Web URL. Optional: enables CORS which is required for access from Temporal Web")
+	flag.StringVar(&configFlag, "config", "", "Config file mapping namespaces to codecs. Optional: reloaded on SIGHUP or when it changes, defaults to snappy for the default namespace")
+	flag.DurationVar(&watchFlag, "watch", 5*time.Second, "Interval to check the config file for changes, 0 disables")
+	flag.StringVar(&auditLogFlag, "audit-log", "-", "File to append the JSON audit log of codec requests to, - for stdout. Optional: empty disables audit logging")
+	flag.Float64Var(&rateLimitFlag, "rate-limit", 0, "Codec requests per second allowed per OIDC subject. Optional: 0 disables rate limiting")
+	flag.IntVar(&rateBurstFlag, "rate-burst", 10, "Burst of codec requests allowed per OIDC subject")
+	flag.StringVar(&redactClaimFlag, "redact-claim", "", "OIDC claim required to see decoded payloads. Optional: requires authentication, other users get redacted payloads")
+	flag.StringVar(&authFlag, "auth", "", "Auth config file with OIDC issuers, API keys and client certificates. Optional: Enforces authentication")
+	flag.StringVar(&tlsCertFlag, "tls-cert", "", "TLS certificate file. Optional: enables TLS")
+	flag.StringVar(&tlsKeyFlag, "tls-key", "", "TLS key file")
+	flag.StringVar(&tlsClientCAFlag, "tls-client-ca", "", "CA file to verify TLS client certificates. Optional: enables client certificate identities")
+}
+
+// loadHandler creates the codec handler from the config file, or the default config without a config file.
+func loadHandler() (http.Handler, error) {
+	config := defaultConfig
+	if configFlag != "" {
+		var err error
+		if config, err = loadConfig(configFlag); err != nil {
+			return nil, err
+		}
+	}
+	return newPayloadCodecNamespacesHTTPHandler(config, middleware)
 }
 
 func main() {
 	flag.Parse()
 
-	// Set codecs per namespace here.
-	// Only handle codecs for the default namespace in this example.
-	codecs := map[string][]converter.PayloadCodec{
-		"default": {codecserver.NewPayloadCodec()},
+	authConfig := &AuthConfig{}
+	if authFlag != "" {
+		var err error
+		if authConfig, err = loadAuthConfig(authFlag); err != nil {
+			logger.Fatal("failed to load auth config", tag.Error(err))
+		}
 	}
-
+	// -provider and -audience add a single issuer.
 	if providerFlag != "" {
-		p, err := newProvider(providerFlag)
+		authConfig.Issuers = append(authConfig.Issuers, IssuerConfig{URL: providerFlag, Audience: audienceFlag})
+	}
+	if len(authConfig.ClientCertificates) > 0 && tlsClientCAFlag == "" {
+		logger.Fatal("client certificates require -tls-client-ca")
+	}
+	if authFlag != "" || providerFlag != "" {
+		auth, err := newAuthenticator(authConfig)
+		if err != nil {
+			logger.Fatal("failed to create authenticator", tag.Error(err))
+		}
+		for _, p := range auth.providers {
+			fmt.Printf("oauth enabled for: %s\n", p.Issuer)
+			if p.Audience != "" {
+				fmt.Printf("oauth audience: %s\n", p.Audience)
+			}
+		}
+		fmt.Printf("API keys: %d, client certificates: %d\n", len(auth.apiKeys), len(auth.clientCertificates))
+		middleware.auth = auth
+	}
+
+	if redactClaimFlag != "" {
+		if middleware.auth == nil {
+			logger.Fatal("-redact-claim requires authentication")
+		}
+		middleware.redactClaim = redactClaimFlag
+		fmt.Printf("decode output redacted without claim: %s\n", redactClaimFlag)
+	}
+	if rateLimitFlag > 0 {
+		middleware.limiter = newSubjectLimiter(rate.Limit(rateLimitFlag), rateBurstFlag)
+	}
+	switch auditLogFlag {
+	case "":
+	case "-":
+		middleware.audit = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	default:
+		f, err := os.OpenFile(auditLogFlag, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
 		if err != nil {
-			logger.Fatal("failed to create OIDC provider", tag.Error(err))
+			logger.Fatal("failed to open audit log", tag.Error(err))
 		}
-		provider = p
-		fmt.Printf("oauth enabled for: %s\n", provider.Issuer)
-		if audienceFlag != "" {
-			provider.Audience = audienceFlag
-			fmt.Printf("oauth audience: %s\n", provider.Audience)
+		defer func() { _ = f.Close() }()
+		middleware.audit = slog.New(slog.NewJSONHandler(f, nil))
+	}
+	registry := prometheus.NewRegistry()
+	middleware.metrics = newCodecMetrics(registry)
+
+	// Set codecs per namespace in the config file.
+	codecHandler, err := loadHandler()
+	if err != nil {
+		logger.Fatal("failed to load config", tag.Error(err))
+	}
+	reloadable := &reloadableHandler{}
+	reloadable.Store(codecHandler)
+	reload := func() {
+		if err := reloadable.reload(loadHandler); err != nil {
+			// Keep serving with the previous config.
+			logger.Error("failed to reload config", tag.Error(err))
+			return
 		}
+		fmt.Println("config reloaded")
 	}
 
-	handler := newPayloadCodecNamespacesHTTPHandler(codecs, provider)
+	// Metrics are served at /metrics, all other paths are handled by the codec handler.
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	mux.Handle("/", reloadable)
 
+	var handler http.Handler = mux
 	if webFlag != "" {
 		fmt.Printf("CORS enabled for Origin: %s\n", webFlag)
 		handler = newCORSHTTPHandler(webFlag, handler)
@@ -129,17 +213,55 @@ func main() {
 	}
 
 	errCh := make(chan error, 1)
-	go func() { errCh <- srv.ListenAndServe() }()
+	if tlsCertFlag != "" {
+		// Client certificates are optional, clients may authenticate with tokens instead.
+		srv.TLSConfig = &tls.Config{ClientAuth: tls.VerifyClientCertIfGiven}
+		if tlsClientCAFlag != "" {
+			pem, err := os.ReadFile(tlsClientCAFlag)
+			if err != nil {
+				logger.Fatal("failed to read client CA", tag.Error(err))
+			}
+			srv.TLSConfig.ClientCAs = x509.NewCertPool()
+			if !srv.TLSConfig.ClientCAs.AppendCertsFromPEM(pem) {
+				logger.Fatal("no certificates in client CA " + tlsClientCAFlag)
+			}
+		}
+		go func() { errCh <- srv.ListenAndServeTLS(tlsCertFlag, tlsKeyFlag) }()
+	} else {
+		go func() { errCh <- srv.ListenAndServe() }()
+	}
+
+	reloadCh := make(chan struct{}, 1)
+	if configFlag != "" && watchFlag > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		go watchFile(configFlag, watchFlag, done, func() {
+			select {
+			case reloadCh <- struct{}{}:
+			default:
+			}
+		})
+	}
 
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, os.Interrupt)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGHUP)
 
-	select {
-	case <-sigCh:
-		_ = srv.Close()
-	case err := <-errCh:
-		if err != http.ErrServerClosed {
+	for {
+		select {
+		case sig := <-sigCh:
+			if sig == syscall.SIGHUP {
+				reload()
+				continue
+			}
+			_ = srv.Close()
+			return
+		case <-reloadCh:
+			reload()
+		case err := <-errCh:
+			if err != http.ErrServerClosed {
 				logger.Fatal("error from HTTP server", tag.Error(err))
+			}
+			return
 		}
 	}
 }
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	codecserver "github.com/temporalio/samples-go/codec-server"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/server/common/authorization"
+	"golang.org/x/time/rate"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// fakeClaimMapper verifies tokens by looking up the Authorization header in a map.
+type fakeClaimMapper map[string]*authorization.Claims
+
+func (m fakeClaimMapper) GetClaims(authInfo *authorization.AuthInfo) (*authorization.Claims, error) {
+	if claims, ok := m[authInfo.AuthToken]; ok {
+		return claims, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+// newToken returns an unsigned JWT with the given claims, which is accepted by fakeClaimMapper.
+func newToken(claims map[string]interface{}) string {
+	payload, _ := json.Marshal(claims)
+	return "Bearer e30." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+type testServer struct {
+	*httptest.Server
+	audit    *bytes.Buffer
+	metrics  *codecMetrics
+	payloads string
+}
+
+const testIssuer = "https://sso.example.com/"
+
+var (
+	readerToken = newToken(map[string]interface{}{"iss": testIssuer, "sub": "reader"})
+	adminToken  = newToken(map[string]interface{}{"iss": testIssuer, "sub": "admin", "payloads:view": true})
+)
+
+func newTestAuthenticator() *authenticator {
+	return &authenticator{
+		providers: []*Provider{{Issuer: testIssuer, mapper: fakeClaimMapper{
+			readerToken: {Subject: "reader", Namespaces: map[string]authorization.Role{"default": authorization.RoleReader}},
+			adminToken:  {Subject: "admin", Namespaces: map[string]authorization.Role{"default": authorization.RoleAdmin}},
+		}}},
+	}
+}
+
+func newTestServer(t *testing.T, configure func(m *codecMiddleware)) *testServer {
+	s := &testServer{audit: &bytes.Buffer{}}
+	m := &codecMiddleware{
+		auth:    newTestAuthenticator(),
+		audit:   slog.New(slog.NewJSONHandler(s.audit, nil)),
+		metrics: newCodecMetrics(prometheus.NewRegistry()),
+	}
+	if configure != nil {
+		configure(m)
+	}
+	s.metrics = m.metrics
+	handler, err := newPayloadCodecNamespacesHTTPHandler(defaultConfig, m)
+	require.NoError(t, err)
+	s.Server = httptest.NewServer(handler)
+	t.Cleanup(s.Close)
+
+	payload, err := converter.GetDefaultDataConverter().ToPayload("secret")
+	require.NoError(t, err)
+	encoded, err := codecserver.NewPayloadCodec().Encode([]*commonpb.Payload{payload})
+	require.NoError(t, err)
+	b, err := protojson.Marshal(&commonpb.Payloads{Payloads: encoded})
+	require.NoError(t, err)
+	s.payloads = string(b)
+	return s
+}
+
+// decode posts the encoded payloads to /default/decode and returns the status code and the decoded string.
+func (s *testServer) decode(t *testing.T, token string) (int, string) {
+	req, err := http.NewRequest(http.MethodPost, s.URL+"/default/decode", strings.NewReader(s.payloads))
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode != http.StatusOK {
+		return res.StatusCode, ""
+	}
+
+	var body bytes.Buffer
+	_, err = body.ReadFrom(res.Body)
+	require.NoError(t, err)
+	var payloads commonpb.Payloads
+	require.NoError(t, protojson.Unmarshal(body.Bytes(), &payloads))
+	require.Len(t, payloads.Payloads, 1)
+	var result string
+	require.NoError(t, converter.GetDefaultDataConverter().FromPayload(payloads.Payloads[0], &result))
+	return res.StatusCode, result
+}
+
+func (s *testServer) auditRecords(t *testing.T) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(s.audit.String()), "\n") {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func Test_AuditAndMetrics(t *testing.T) {
+	s := newTestServer(t, nil)
+
+	status, result := s.decode(t, readerToken)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "secret", result)
+	status, _ = s.decode(t, "")
+	require.Equal(t, http.StatusUnauthorized, status)
+
+	records := s.auditRecords(t)
+	require.Len(t, records, 2)
+	require.Equal(t, "reader", records[0]["subject"])
+	require.Equal(t, "oidc", records[0]["method"])
+	require.Equal(t, "default", records[0]["namespace"])
+	require.Equal(t, "decode", records[0]["operation"])
+	require.Equal(t, float64(1), records[0]["payloads"])
+	require.Equal(t, []interface{}{"binary/snappy"}, records[0]["encodings"])
+	require.Equal(t, float64(http.StatusOK), records[0]["status"])
+	require.Equal(t, anonymousSubject, records[1]["subject"])
+	require.Equal(t, float64(http.StatusUnauthorized), records[1]["status"])
+
+	require.Equal(t, float64(1), testutil.ToFloat64(s.metrics.requests.WithLabelValues("default", "decode", "200")))
+	require.Equal(t, float64(1), testutil.ToFloat64(s.metrics.requests.WithLabelValues("default", "decode", "401")))
+	require.Equal(t, float64(1), testutil.ToFloat64(s.metrics.payloads.WithLabelValues("default", "decode")))
+	require.Equal(t, 1, testutil.CollectAndCount(s.metrics.latency))
+}
+
+func Test_RateLimit(t *testing.T) {
+	s := newTestServer(t, func(m *codecMiddleware) {
+		m.limiter = newSubjectLimiter(rate.Every(time.Hour), 1)
+	})
+
+	status, _ := s.decode(t, readerToken)
+	require.Equal(t, http.StatusOK, status)
+	status, _ = s.decode(t, readerToken)
+	require.Equal(t, http.StatusTooManyRequests, status)
+	// Subjects are limited separately.
+	status, _ = s.decode(t, adminToken)
+	require.Equal(t, http.StatusOK, status)
+
+	require.Equal(t, float64(1), testutil.ToFloat64(s.metrics.requests.WithLabelValues("default", "decode", "429")))
+}
+
+func Test_Redaction(t *testing.T) {
+	s := newTestServer(t, func(m *codecMiddleware) {
+		m.redactClaim = "payloads:view"
+	})
+
+	status, result := s.decode(t, readerToken)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "[redacted]", result)
+	status, result = s.decode(t, adminToken)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "secret", result)
+
+	records := s.auditRecords(t)
+	require.Equal(t, true, records[0]["redacted"])
+	require.Equal(t, false, records[1]["redacted"])
+}
+
+func Test_Authenticate(t *testing.T) {
+	dir := t.TempDir()
+	apiKeysFile := filepath.Join(dir, "api-keys.json")
+	hash := sha256.Sum256([]byte("secret-key"))
+	require.NoError(t, os.WriteFile(apiKeysFile, []byte(`{"keys": [
+		{"name": "ci", "sha256": "`+hex.EncodeToString(hash[:])+`", "namespaces": ["default"], "permissions": ["decode"]}
+	]}`), 0600))
+	authConfigFile := filepath.Join(dir, "auth.json")
+	require.NoError(t, os.WriteFile(authConfigFile, []byte(`{
+		"apiKeysFile": "api-keys.json",
+		"clientCertificates": [{"commonName": "worker", "namespaces": ["*"], "permissions": ["encode", "decode"]}]
+	}`), 0600))
+
+	authConfig, err := loadAuthConfig(authConfigFile)
+	require.NoError(t, err)
+	auth, err := newAuthenticator(authConfig)
+	require.NoError(t, err)
+	auth.providers = newTestAuthenticator().providers
+	auth.providers[0].ClaimMappings = []ClaimMapping{
+		{Claim: "groups", Value: "payments", Grant: Grant{Namespaces: []string{"payments"}, Permissions: []string{PermissionDecode}}},
+	}
+
+	newRequest := func(token string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/default/decode", nil)
+		if token != "" {
+			r.Header.Set("Authorization", token)
+		}
+		return r
+	}
+
+	// API key
+	identity, err := auth.authenticate(newRequest("Bearer secret-key"), "")
+	require.NoError(t, err)
+	require.Equal(t, "ci", identity.Subject)
+	require.Equal(t, "api-key", identity.Method)
+	require.True(t, identity.allows("default", PermissionDecode))
+	require.False(t, identity.allows("default", PermissionEncode))
+	require.False(t, identity.allows("payments", PermissionDecode))
+	_, err = auth.authenticate(newRequest("Bearer wrong-key"), "")
+	require.Error(t, err)
+
+	// Client certificate
+	r := newRequest("")
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "worker"}}}}}
+	identity, err = auth.authenticate(r, "")
+	require.NoError(t, err)
+	require.Equal(t, "mtls", identity.Method)
+	require.True(t, identity.allows("payments", PermissionEncode))
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "other"}}}}}
+	_, err = auth.authenticate(r, "")
+	require.ErrorIs(t, err, errNoCredentials)
+
+	// OIDC token with claim mapping
+	groupToken := newToken(map[string]interface{}{"iss": testIssuer, "sub": "member", "groups": []string{"payments"}})
+	auth.providers[0].mapper.(fakeClaimMapper)[groupToken] = &authorization.Claims{Subject: "member"}
+	identity, err = auth.authenticate(newRequest(groupToken), "")
+	require.NoError(t, err)
+	require.Equal(t, "oidc", identity.Method)
+	require.True(t, identity.allows("payments", PermissionDecode))
+	require.False(t, identity.allows("default", PermissionDecode))
+
+	// Unknown issuer
+	_, err = auth.authenticate(newRequest(newToken(map[string]interface{}{"iss": "https://other.example.com/"})), "")
+	require.ErrorContains(t, err, "unknown issuer")
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/server/common/log/tag"
+	"golang.org/x/time/rate"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// anonymousSubject is the subject of requests when authentication is disabled.
+const anonymousSubject = "anonymous"
+
+// codecMiddleware authorizes, rate limits, audits and measures the requests of the codec handlers. It is shared by
+// all namespaces and survives config reloads.
+type codecMiddleware struct {
+	// auth enforces authentication when set.
+	auth *authenticator
+	// limiter rate limits the requests per subject when set.
+	limiter *subjectLimiter
+	// audit logs every request when set.
+	audit *slog.Logger
+	// metrics records request metrics when set.
+	metrics *codecMetrics
+	// redactClaim redacts the output of decode requests whose token doesn't have this claim when set.
+	redactClaim string
+}
+
+// auditRecord is logged by codecMiddleware for every request.
+type auditRecord struct {
+	subject   string
+	method    string
+	namespace string
+	operation string
+	payloads  int
+	encodings []string
+	status    int
+	redacted  bool
+	duration  time.Duration
+}
+
+// wrap wraps the codec handler of a namespace.
+func (m *codecMiddleware) wrap(namespace, audience string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record := auditRecord{
+			subject:   anonymousSubject,
+			namespace: namespace,
+			operation: path.Base(r.URL.Path),
+		}
+		start := time.Now()
+		defer func() {
+			record.duration = time.Since(start)
+			m.record(r, record)
+		}()
+
+		identity := &Identity{Subject: anonymousSubject}
+		if m.auth != nil {
+			var err error
+			if identity, err = m.auth.authenticate(r, audience); err != nil {
+				logger.Warn("authentication failed", tag.Error(err))
+				record.status = http.StatusUnauthorized
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			record.subject = identity.Subject
+			record.method = identity.Method
+			if !identity.allows(namespace, record.operation) {
+				record.status = http.StatusUnauthorized
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		if m.limiter != nil && !m.limiter.allow(record.subject) {
+			record.status = http.StatusTooManyRequests
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			record.status = http.StatusBadRequest
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		// Invalid payloads are rejected by the codec handler.
+		var payloads commonpb.Payloads
+		if protojson.Unmarshal(body, &payloads) == nil {
+			record.payloads = len(payloads.Payloads)
+			record.encodings = encodings(payloads.Payloads)
+		}
+
+		if record.operation == "decode" && m.redactClaim != "" && !identity.hasClaim(m.redactClaim) {
+			record.redacted = true
+			record.status = writeRedacted(w, len(payloads.Payloads))
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		record.status = recorder.status
+	})
+}
+
+func (m *codecMiddleware) record(r *http.Request, record auditRecord) {
+	if m.audit != nil {
+		m.audit.LogAttrs(r.Context(), slog.LevelInfo, "codec request",
+			slog.String("subject", record.subject),
+			slog.String("method", record.method),
+			slog.String("namespace", record.namespace),
+			slog.String("operation", record.operation),
+			slog.Int("payloads", record.payloads),
+			slog.Any("encodings", record.encodings),
+			slog.Int("status", record.status),
+			slog.Bool("redacted", record.redacted),
+			slog.String("remote", r.RemoteAddr),
+			slog.Duration("duration", record.duration),
+		)
+	}
+	if m.metrics != nil {
+		m.metrics.observe(record)
+	}
+}
+
+// encodings returns the distinct encodings of the payloads.
+func encodings(payloads []*commonpb.Payload) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, p := range payloads {
+		encoding := string(p.GetMetadata()[converter.MetadataEncoding])
+		if !seen[encoding] {
+			seen[encoding] = true
+			result = append(result, encoding)
+		}
+	}
+	return result
+}
+
+// writeRedacted responds with count payloads that display as "[redacted]", the payloads are not decoded at all.
+func writeRedacted(w http.ResponseWriter, count int) int {
+	redacted, err := converter.GetDefaultDataConverter().ToPayload("[redacted]")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return http.StatusInternalServerError
+	}
+	payloads := &commonpb.Payloads{Payloads: make([]*commonpb.Payload, count)}
+	for i := range payloads.Payloads {
+		payloads.Payloads[i] = redacted
+	}
+	b, err := protojson.Marshal(payloads)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+	return http.StatusOK
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// subjectLimiter limits the request rate of each subject.
+type subjectLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+func newSubjectLimiter(limit rate.Limit, burst int) *subjectLimiter {
+	return &subjectLimiter{limit: limit, burst: burst, limiters: make(map[string]*rate.Limiter)}
+}
+
+func (l *subjectLimiter) allow(subject string) bool {
+	l.mu.Lock()
+	limiter, ok := l.limiters[subject]
+	if !ok {
+		limiter = rate.NewLimiter(l.limit, l.burst)
+		l.limiters[subject] = limiter
+	}
+	l.mu.Unlock()
+	return limiter.Allow()
+}
+
+// codecMetrics are the Prometheus metrics of the codec requests.
+type codecMetrics struct {
+	requests *prometheus.CounterVec
+	payloads *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+}
+
+func newCodecMetrics(registerer prometheus.Registerer) *codecMetrics {
+	m := &codecMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "codec_server_requests_total",
+			Help: "Number of codec requests.",
+		}, []string{"namespace", "operation", "code"}),
+		payloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "codec_server_payloads_total",
+			Help: "Number of payloads in codec requests.",
+		}, []string{"namespace", "operation"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "codec_server_request_duration_seconds",
+			Help:    "Latency of codec requests.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"namespace", "operation"}),
+	}
+	registerer.MustRegister(m.requests, m.payloads, m.latency)
+	return m
+}
+
+func (m *codecMetrics) observe(record auditRecord) {
+	m.requests.WithLabelValues(record.namespace, record.operation, strconv.Itoa(record.status)).Inc()
+	m.payloads.WithLabelValues(record.namespace, record.operation).Add(float64(record.payloads))
+	m.latency.WithLabelValues(record.namespace, record.operation).Observe(record.duration.Seconds())
+}
@@ -1,58 +1,101 @@
 package main
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
 
 	"go.temporal.io/server/common/authorization"
 	"go.temporal.io/server/common/config"
-	"go.temporal.io/server/common/log/tag"
 )
 
 type Provider struct {
 	Issuer   string `json:"issuer"`
 	JWKSURI  string `json:"jwks_uri,omitempty"`
 	Audience string
-	mapper   authorization.ClaimMapper
+	// ClaimMappings grant permissions to tokens with specific claim values, in addition to the Temporal permissions
+	// of the token.
+	ClaimMappings []ClaimMapping
+	mapper        authorization.ClaimMapper
 }
 
-func (p *Provider) Authorize(namespace string, r *http.Request) bool {
-	token := r.Header.Get("Authorization")
-	if token == "" {
-		logger.Warn("Authorization header not set")
-		return false
+// Authenticate verifies an OIDC token. The token must be issued for audience, or for the Audience of the provider when
+// audience is empty. The namespace roles of the token grant decode to readers and encode to writers.
+func (p *Provider) Authenticate(token, audience string) (*Identity, error) {
+	if audience == "" {
+		audience = p.Audience
 	}
 
 	authInfo := authorization.AuthInfo{
 		AuthToken: token,
-		Audience:  p.Audience,
+		Audience:  audience,
 	}
 
 	claims, err := p.mapper.GetClaims(&authInfo)
 	if err != nil {
-		logger.Warn("unable to parse claims", tag.Error(err))
-		return false
+		return nil, fmt.Errorf("unable to parse claims: %w", err)
+	}
+	// The token is verified, so its claims can be read without verifying it again.
+	tokenClaims, err := parseTokenClaims(token)
+	if err != nil {
+		return nil, err
 	}
 
-	// If they have no role in this namespace they will get RoleUndefined
-	role := claims.Namespaces[namespace]
-
-	switch {
-	case strings.HasSuffix(r.URL.Path, "/decode"):
-		if role >= authorization.RoleReader {
-			return true
+	identity := &Identity{Subject: claims.Subject, Method: "oidc", claims: tokenClaims}
+	for namespace, role := range claims.Namespaces {
+		switch {
+		case role >= authorization.RoleWriter:
+			identity.Grants = append(identity.Grants, Grant{Namespaces: []string{namespace}, Permissions: []string{PermissionEncode, PermissionDecode}})
+		case role >= authorization.RoleReader:
+			identity.Grants = append(identity.Grants, Grant{Namespaces: []string{namespace}, Permissions: []string{PermissionDecode}})
 		}
-	case strings.HasSuffix(r.URL.Path, "/encode"):
-		if role >= authorization.RoleWriter {
-			return true
+	}
+	for _, mapping := range p.ClaimMappings {
+		if mapping.matches(tokenClaims) {
+			identity.Grants = append(identity.Grants, mapping.Grant)
 		}
 	}
+	return identity, nil
+}
+
+// parseTokenClaims returns the claims of a JWT without verifying it.
+func parseTokenClaims(token string) (map[string]interface{}, error) {
+	parts := strings.Split(strings.TrimPrefix(token, "Bearer "), ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("malformed token: %w", err)
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("malformed token: %w", err)
+	}
+	return claims, nil
+}
 
-	return false
+// isSet reports whether a claim value is set to a value other than false, null, zero or empty.
+func isSet(value interface{}) bool {
+	switch value := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return value
+	case string:
+		return value != ""
+	case float64:
+		return value != 0
+	case []interface{}:
+		return len(value) > 0
+	default:
+		return true
+	}
 }
 
-func newProvider(providerURL string) (*Provider, error) {
+func newProvider(providerURL, permissionsClaim string) (*Provider, error) {
 	var provider Provider
 
 	res, err := http.Get(strings.TrimSuffix(providerURL, "/") + "/.well-known/openid-configuration")
@@ -66,17 +109,20 @@ func newProvider(providerURL string) (*Provider, error) {
 		return nil, err
 	}
 
-	provider.mapper = newClaimMapper(provider.JWKSURI)
+	provider.mapper = newClaimMapper(provider.JWKSURI, permissionsClaim)
 
 	return &provider, nil
 }
 
-func newClaimMapper(providerKeysURL string) authorization.ClaimMapper {
+// newClaimMapper creates the Temporal claim mapper, it reads permissions from permissionsClaim when set, otherwise from
+// the "permissions" claim.
+func newClaimMapper(providerKeysURL, permissionsClaim string) authorization.ClaimMapper {
 	authConfig := config.Authorization{
 		JWTKeyProvider: config.JWTKeyProvider{
 			KeySourceURIs: []string{providerKeysURL},
 		},
-		ClaimMapper: "default",
+		PermissionsClaimName: permissionsClaim,
+		ClaimMapper:          "default",
 	}
 
 	provider := authorization.NewDefaultTokenKeyProvider(
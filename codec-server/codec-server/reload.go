@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// reloadableHandler serves HTTP requests with the handler that was stored last. Swapping the handler doesn't affect
+// in-flight requests, they complete with the handler they started with.
+type reloadableHandler struct {
+	current atomic.Pointer[http.Handler]
+}
+
+func (h *reloadableHandler) Store(handler http.Handler) {
+	h.current.Store(&handler)
+}
+
+// reload stores the handler created by load, and keeps serving with the previous handler if load fails.
+func (h *reloadableHandler) reload(load func() (http.Handler, error)) error {
+	handler, err := load()
+	if err != nil {
+		return err
+	}
+	h.Store(handler)
+	return nil
+}
+
+func (h *reloadableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*h.current.Load()).ServeHTTP(w, r)
+}
+
+// watchFile calls onChange whenever the modification time or size of the file at path changes, it polls every
+// interval until done is closed.
+func watchFile(path string, interval time.Duration, done <-chan struct{}, onChange func()) {
+	stat := func() (time.Time, int64) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, -1
+		}
+		return info.ModTime(), info.Size()
+	}
+
+	modTime, size := stat()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			newModTime, newSize := stat()
+			if newSize < 0 || (newModTime.Equal(modTime) && newSize == size) {
+				continue
+			}
+			modTime, size = newModTime, newSize
+			onChange()
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package codecserver
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+func Test_EncryptionCodec_RoundTrip(t *testing.T) {
+	keys := map[string][]byte{
+		"key-1": bytes.Repeat([]byte{1}, 32),
+		"key-2": bytes.Repeat([]byte{2}, 16),
+	}
+	codec, err := NewEncryptionCodec("key-1", keys)
+	require.NoError(t, err)
+
+	payload, err := converter.GetDefaultDataConverter().ToPayload("Testing")
+	require.NoError(t, err)
+	encoded, err := codec.Encode([]*commonpb.Payload{payload})
+	require.NoError(t, err)
+	require.Equal(t, MetadataEncodingEncrypted, string(encoded[0].Metadata[converter.MetadataEncoding]))
+	require.Equal(t, "key-1", string(encoded[0].Metadata[MetadataEncryptionKeyID]))
+	require.NotContains(t, string(encoded[0].Data), "Testing")
+
+	// After rotating to key-2, payloads of key-1 are still decoded
+	rotated, err := NewEncryptionCodec("key-2", keys)
+	require.NoError(t, err)
+	decoded, err := rotated.Decode(encoded)
+	require.NoError(t, err)
+	var result string
+	require.NoError(t, converter.GetDefaultDataConverter().FromPayload(decoded[0], &result))
+	require.Equal(t, "Testing", result)
+
+	// Payloads of other encodings are left as is
+	decoded, err = codec.Decode([]*commonpb.Payload{payload})
+	require.NoError(t, err)
+	require.Equal(t, payload, decoded[0])
+
+	// Tampered payloads fail to decode
+	encoded[0].Data[len(encoded[0].Data)-1] ^= 1
+	_, err = codec.Decode(encoded)
+	require.Error(t, err)
+}
+
+func Test_NewEncryptionCodec_Errors(t *testing.T) {
+	_, err := NewEncryptionCodec("key-2", map[string][]byte{"key-1": make([]byte, 32)})
+	require.EqualError(t, err, `unknown encryption key "key-2"`)
+
+	_, err = NewEncryptionCodec("key-1", map[string][]byte{"key-1": make([]byte, 10)})
+	require.EqualError(t, err, `key "key-1" must be 16, 24 or 32 bytes long, got 10`)
+}
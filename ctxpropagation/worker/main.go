@@ -10,6 +10,7 @@ import (
 	"go.temporal.io/sdk/workflow"
 
 	"github.com/temporalio/samples-go/ctxpropagation"
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 )
 
 func main() {
@@ -24,7 +25,7 @@ func main() {
 	}
 
 	// The client and worker are heavyweight objects that should be created once per process.
-	c, err := client.Dial(client.Options{
+	c, shutdown, err := bootstrap.Dial(client.Options{
 		HostPort:           client.DefaultHostPort,
 		ContextPropagators: []workflow.ContextPropagator{ctxpropagation.NewContextPropagator()},
 		Interceptors:       []interceptor.ClientInterceptor{tracingInterceptor},
@@ -32,6 +33,7 @@ func main() {
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
 	w := worker.New(c, "ctx-propagation", worker.Options{
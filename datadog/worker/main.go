@@ -1,19 +1,18 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"io"
 	"log"
 	"log/slog"
 	"os"
-	"time"
 
-	prom "github.com/prometheus/client_golang/prometheus"
 	"github.com/temporalio/samples-go/datadog"
-	"github.com/uber-go/tally/v4"
-	"github.com/uber-go/tally/v4/prometheus"
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/contrib/datadog/tracing"
-	sdktally "go.temporal.io/sdk/contrib/tally"
 	"go.temporal.io/sdk/interceptor"
 	tlog "go.temporal.io/sdk/log"
 	"go.temporal.io/sdk/worker"
@@ -21,6 +20,22 @@ import (
 )
 
 func main() {
+	// The Datadog agent scrapes the Prometheus endpoint, see the README
+	metricsOptions := bootstrap.Options{
+		Exporter:          bootstrap.ExporterPrometheus,
+		PrometheusAddress: "localhost:9090",
+		Prefix:            "temporal_datadog",
+	}
+	metricsOptions.AddFlags(flag.CommandLine)
+	flag.Parse()
+
+	if err := run(metricsOptions); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// run returns its errors instead of exiting, so that the deferred calls flush the traces, the logs and the metrics
+func run(metricsOptions bootstrap.Options) error {
 	// Start the tracer and defer the Stop method.
 	tracer.Start(tracer.WithAgentAddr("localhost:8126"))
 	defer tracer.Stop()
@@ -28,12 +43,11 @@ func main() {
 	// Setup logging
 	f, err := os.OpenFile("worker.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
 	if err != nil {
-		log.Fatalf("error opening file: %v", err)
+		return fmt.Errorf("error opening file: %w", err)
 	}
 	defer func() {
-		err = f.Close()
-		if err != nil {
-			log.Fatalf("error closing file: %v", err)
+		if err := f.Close(); err != nil {
+			log.Printf("error closing file: %v", err)
 		}
 	}()
 	wrt := io.MultiWriter(os.Stdout, f)
@@ -43,17 +57,14 @@ func main() {
 			Level: slog.LevelInfo,
 		})))
 
-	c, err := client.Dial(client.Options{
+	c, shutdown, err := bootstrap.DialWithOptions(context.Background(), client.Options{
 		Logger:       logger,
 		Interceptors: []interceptor.ClientInterceptor{tracing.NewTracingInterceptor(tracing.TracerOptions{})},
-		MetricsHandler: sdktally.NewMetricsHandler(newPrometheusScope(prometheus.Configuration{
-			ListenAddress: "localhost:9090",
-			TimerType:     "histogram",
-		})),
-	})
+	}, metricsOptions)
 	if err != nil {
-		log.Fatalln("Unable to create client", err)
+		return fmt.Errorf("unable to create client: %w", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
 	w := worker.New(c, "datadog", worker.Options{})
@@ -62,33 +73,8 @@ func main() {
 	w.RegisterWorkflow(datadog.ChildWorkflow)
 	w.RegisterActivity(datadog.Activity)
 
-	err = w.Run(worker.InterruptCh())
-	if err != nil {
-		log.Fatalln("Unable to start worker", err)
-	}
-}
-
-func newPrometheusScope(c prometheus.Configuration) tally.Scope {
-	reporter, err := c.NewReporter(
-		prometheus.ConfigurationOptions{
-			Registry: prom.NewRegistry(),
-			OnError: func(err error) {
-				log.Println("error in prometheus reporter", err)
-			},
-		},
-	)
-	if err != nil {
-		log.Fatalln("error creating prometheus reporter", err)
-	}
-	scopeOpts := tally.ScopeOptions{
-		CachedReporter:  reporter,
-		Separator:       prometheus.DefaultSeparator,
-		SanitizeOptions: &sdktally.PrometheusSanitizeOptions,
-		Prefix:          "temporal_datadog",
+	if err := w.Run(worker.InterruptCh()); err != nil {
+		return fmt.Errorf("unable to start worker: %w", err)
 	}
-	scope, _ := tally.NewRootScope(scopeOpts, time.Second)
-	scope = sdktally.NewPrometheusNamingScope(scope)
-
-	log.Println("prometheus metrics scope created")
-	return scope
+	return nil
 }
@@ -10,31 +10,31 @@ import (
 type SampleActivities struct {
 }
 
-func (a *SampleActivities) SampleActivity1(ctx context.Context, input []string) (string, error) {
+func (a *SampleActivities) SampleActivity1(ctx context.Context, input []interface{}) (string, error) {
 	name := activity.GetInfo(ctx).ActivityType.Name
 	fmt.Printf("Run %s with input %v \n", name, input)
 	return "Result_" + name, nil
 }
 
-func (a *SampleActivities) SampleActivity2(ctx context.Context, input []string) (string, error) {
+func (a *SampleActivities) SampleActivity2(ctx context.Context, input []interface{}) (string, error) {
 	name := activity.GetInfo(ctx).ActivityType.Name
 	fmt.Printf("Run %s with input %v \n", name, input)
 	return "Result_" + name, nil
 }
 
-func (a *SampleActivities) SampleActivity3(ctx context.Context, input []string) (string, error) {
+func (a *SampleActivities) SampleActivity3(ctx context.Context, input []interface{}) (string, error) {
 	name := activity.GetInfo(ctx).ActivityType.Name
 	fmt.Printf("Run %s with input %v \n", name, input)
 	return "Result_" + name, nil
 }
 
-func (a *SampleActivities) SampleActivity4(ctx context.Context, input []string) (string, error) {
+func (a *SampleActivities) SampleActivity4(ctx context.Context, input []interface{}) (string, error) {
 	name := activity.GetInfo(ctx).ActivityType.Name
 	fmt.Printf("Run %s with input %v \n", name, input)
 	return "Result_" + name, nil
 }
 
-func (a *SampleActivities) SampleActivity5(ctx context.Context, input []string) (string, error) {
+func (a *SampleActivities) SampleActivity5(ctx context.Context, input []interface{}) (string, error) {
 	name := activity.GetInfo(ctx).ActivityType.Name
 	fmt.Printf("Run %s with input %v \n", name, input)
 	return "Result_" + name, nil
@@ -0,0 +1,104 @@
+package dsl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type pathSegment struct {
+	field   string
+	index   int
+	isIndex bool
+}
+
+// parseReference splits a reference like `result1.items[0].id` into the variable name and the path that selects a
+// value inside of it.
+func parseReference(ref string) (string, []pathSegment, error) {
+	end := strings.IndexAny(ref, ".[")
+	if end < 0 {
+		end = len(ref)
+	}
+	name := ref[:end]
+	if name == "" {
+		return "", nil, fmt.Errorf("missing variable name in reference %q", ref)
+	}
+	var segments []pathSegment
+	rest := ref[end:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return "", nil, fmt.Errorf("empty field in reference %q", ref)
+			}
+			segments = append(segments, pathSegment{field: rest[:end]})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return "", nil, fmt.Errorf("missing closing bracket in reference %q", ref)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return "", nil, fmt.Errorf("invalid index %q in reference %q", rest[1:end], ref)
+			}
+			segments = append(segments, pathSegment{index: index, isIndex: true})
+			rest = rest[end+1:]
+		default:
+			return "", nil, fmt.Errorf("unexpected %q in reference %q", rest, ref)
+		}
+	}
+	return name, segments, nil
+}
+
+// argumentName returns the name of the child workflow variable bound to a reference: the variable name of a plain
+// reference, or the last field of its path, so `result1.items[0].id` is bound as `id` and `order.items[0]` as `items`.
+func argumentName(ref string) (string, error) {
+	name, segments, err := parseReference(ref)
+	if err != nil {
+		return "", err
+	}
+	for i := len(segments) - 1; i >= 0; i-- {
+		if !segments[i].isIndex {
+			return segments[i].field, nil
+		}
+	}
+	return name, nil
+}
+
+// resolveReference returns the value selected by ref from the bindings. Unbound variables and missing fields resolve
+// to nil, selecting a field or an index from a value of the wrong type is an error.
+func resolveReference(ref string, bindings map[string]interface{}) (interface{}, error) {
+	name, segments, err := parseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	value := bindings[name]
+	for _, segment := range segments {
+		if value == nil {
+			return nil, nil
+		}
+		if segment.isIndex {
+			list, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("cannot index %T in reference %q", value, ref)
+			}
+			if segment.index >= len(list) {
+				return nil, fmt.Errorf("index %d out of range in reference %q", segment.index, ref)
+			}
+			value = list[segment.index]
+			continue
+		}
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot select field %q of %T in reference %q", segment.field, value, ref)
+		}
+		value = object[segment.field]
+	}
+	return value, nil
+}
@@ -0,0 +1,345 @@
+package dsl
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// evaluateCondition evaluates a small boolean expression against the bindings. The supported grammar is:
+//
+//	expr    := and ( "||" and )*
+//	and     := unary ( "&&" unary )*
+//	unary   := "!" unary | compare
+//	compare := operand ( ( "==" | "!=" | "<" | "<=" | ">" | ">=" ) operand )?
+//	operand := reference | 'literal' | "literal" | number | true | false | null | "(" expr ")"
+//
+// References such as `result1.items[0].id` are resolved from the bindings, an unbound reference evaluates to null.
+// A value used as a boolean is false when it is null, false, zero or an empty string, list or object. The right side of
+// `&&` and `||` is not evaluated when the left side decides the result, so `items != null && items[0].id == 'x'` is
+// false rather than an error when items is unbound.
+func evaluateCondition(expression string, bindings map[string]interface{}) (bool, error) {
+	v, err := parseCondition(expression, bindings, false)
+	if err != nil {
+		return false, err
+	}
+	return truthy(v), nil
+}
+
+// checkCondition reports syntax errors in the expression without evaluating it.
+func checkCondition(expression string) error {
+	_, err := parseCondition(expression, nil, true)
+	return err
+}
+
+func parseCondition(expression string, bindings map[string]interface{}, syntaxOnly bool) (interface{}, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &conditionParser{tokens: tokens, bindings: bindings, syntaxOnly: syntaxOnly}
+	v, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token %q in condition %q", p.tokens[p.pos].text, expression)
+	}
+	return v, nil
+}
+
+type (
+	tokenKind int
+
+	token struct {
+		kind tokenKind
+		text string
+	}
+
+	conditionParser struct {
+		tokens     []token
+		pos        int
+		bindings   map[string]interface{}
+		syntaxOnly bool
+	}
+)
+
+const (
+	tokenReference tokenKind = iota
+	tokenLiteral
+	tokenNumber
+	tokenOperator
+)
+
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")"}
+
+func tokenize(expression string) ([]token, error) {
+	var tokens []token
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated literal in condition %q", expression)
+			}
+			tokens = append(tokens, token{kind: tokenLiteral, text: string(runes[i+1 : end])})
+			i = end + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			end := i + 1
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || runes[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[i:end])})
+			i = end
+		case unicode.IsLetter(r) || r == '_':
+			end := i
+			for end < len(runes) && isReferenceRune(runes[end]) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenReference, text: string(runes[i:end])})
+			i = end
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(string(runes[i:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q in condition %q", r, expression)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op})
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+func isReferenceRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.[]", r)
+}
+
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	if f, ok := toNumber(v); ok {
+		return f != 0
+	}
+	return true
+}
+
+// toNumber converts the numeric types produced by the JSON and YAML decoders to float64.
+func toNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func equal(left, right interface{}) bool {
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	if lok && rok {
+		return l == r
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+func compare(op string, left, right interface{}) (bool, error) {
+	if l, ok := left.(string); ok {
+		if r, ok := right.(string); ok {
+			return compareOrdered(op, strings.Compare(l, r)), nil
+		}
+	}
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	if !lok || !rok {
+		return false, fmt.Errorf("cannot compare %v %s %v", left, op, right)
+	}
+	switch {
+	case l < r:
+		return compareOrdered(op, -1), nil
+	case l > r:
+		return compareOrdered(op, 1), nil
+	}
+	return compareOrdered(op, 0), nil
+}
+
+func compareOrdered(op string, c int) bool {
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+func (p *conditionParser) peekOperator(ops ...string) string {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != tokenOperator {
+		return ""
+	}
+	for _, op := range ops {
+		if p.tokens[p.pos].text == op {
+			return op
+		}
+	}
+	return ""
+}
+
+func (p *conditionParser) parseOr() (interface{}, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekOperator("||") != "" {
+		p.pos++
+		// The right side is only checked for syntax errors when the left side is true
+		right, err := p.parseSkipping(truthy(left), p.parseAnd)
+		if err != nil {
+			return nil, err
+		}
+		left = truthy(left) || truthy(right)
+	}
+	return left, nil
+}
+
+func (p *conditionParser) parseAnd() (interface{}, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekOperator("&&") != "" {
+		p.pos++
+		// The right side is only checked for syntax errors when the left side is false
+		right, err := p.parseSkipping(!truthy(left), p.parseUnary)
+		if err != nil {
+			return nil, err
+		}
+		left = truthy(left) && truthy(right)
+	}
+	return left, nil
+}
+
+// parseSkipping parses with parse, without evaluating the expression when skip is true.
+func (p *conditionParser) parseSkipping(skip bool, parse func() (interface{}, error)) (interface{}, error) {
+	if !skip || p.syntaxOnly {
+		return parse()
+	}
+	p.syntaxOnly = true
+	defer func() { p.syntaxOnly = false }()
+	return parse()
+}
+
+func (p *conditionParser) parseUnary() (interface{}, error) {
+	if p.peekOperator("!") != "" {
+		p.pos++
+		v, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return !truthy(v), nil
+	}
+	return p.parseCompare()
+}
+
+func (p *conditionParser) parseCompare() (interface{}, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	op := p.peekOperator("==", "!=", "<", "<=", ">", ">=")
+	if op == "" {
+		return left, nil
+	}
+	p.pos++
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if p.syntaxOnly {
+		return false, nil
+	}
+	switch op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	}
+	return compare(op, left, right)
+}
+
+func (p *conditionParser) parseOperand() (interface{}, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of condition")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	switch t.kind {
+	case tokenReference:
+		switch t.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		if p.syntaxOnly {
+			_, _, err := parseReference(t.text)
+			return nil, err
+		}
+		return resolveReference(t.text, p.bindings)
+	case tokenLiteral:
+		return t.text, nil
+	case tokenNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q in condition", t.text)
+		}
+		return f, nil
+	}
+	if t.text != "(" {
+		return nil, fmt.Errorf("unexpected token %q in condition", t.text)
+	}
+	v, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peekOperator(")") == "" {
+		return nil, fmt.Errorf("missing closing parenthesis in condition")
+	}
+	p.pos++
+	return v, nil
+}
@@ -0,0 +1,209 @@
+package dsl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+type (
+	// Checkpoint is carried over continue-as-new by SimpleDSLWorkflow. Path is the statement path, using the keys of
+	// ValidationError paths, at which the new run resumes. Loop bodies are indexed by iteration, for example
+	// `root.sequence.elements[1].forEach.body[3]`. PendingSignals holds the signals that were received but not yet
+	// consumed by a WaitForSignal statement.
+	Checkpoint struct {
+		Path           string
+		PendingSignals map[string][]interface{}
+	}
+
+	// State is returned by the StateQueryName query. Statements lists the paths of the statements that are currently
+	// running, there is more than one when branches run in parallel.
+	State struct {
+		Statements []string
+		Bindings   map[string]interface{}
+	}
+
+	// interpreter holds the state of a SimpleDSLWorkflow run that is shared by all statements.
+	interpreter struct {
+		resume     string
+		progressed bool
+		active     map[string]int
+		pending    map[string][]interface{}
+	}
+
+	contextKey string
+
+	// continueAsNewError unwinds the statements up to SimpleDSLWorkflow, which continues as new from path.
+	continueAsNewError struct {
+		path string
+	}
+)
+
+const (
+	// StateQueryName is the query type name that returns the State of a SimpleDSLWorkflow.
+	StateQueryName = "state"
+
+	interpreterKey   contextKey = "interpreter"
+	pathKey          contextKey = "path"
+	noCheckpointsKey contextKey = "noCheckpoints"
+)
+
+func (e *continueAsNewError) Error() string {
+	return fmt.Sprintf("continue as new at %s", e.path)
+}
+
+func newInterpreter(checkpoint *Checkpoint) *interpreter {
+	in := &interpreter{
+		active:  make(map[string]int),
+		pending: make(map[string][]interface{}),
+	}
+	if checkpoint != nil {
+		in.resume = checkpoint.Path
+		//workflowcheck:ignore Only iterates for building another map
+		for name, values := range checkpoint.PendingSignals {
+			in.pending[name] = values
+		}
+	}
+	return in
+}
+
+func getInterpreter(ctx workflow.Context) *interpreter {
+	return ctx.Value(interpreterKey).(*interpreter)
+}
+
+func statementPath(ctx workflow.Context) string {
+	path, _ := ctx.Value(pathKey).(string)
+	return path
+}
+
+func withPath(ctx workflow.Context, path string) workflow.Context {
+	return workflow.WithValue(ctx, pathKey, path)
+}
+
+// withoutCheckpoints is used for Parallel and Try, whose progress can't be resumed from a single statement path.
+func withoutCheckpoints(ctx workflow.Context) workflow.Context {
+	return workflow.WithValue(ctx, noCheckpointsKey, true)
+}
+
+func (in *interpreter) enter(path string) {
+	in.active[path]++
+	if in.resume == path {
+		// The checkpointed statement is reached, from here on the workflow runs normally.
+		in.resume = ""
+	}
+}
+
+func (in *interpreter) exit(path string) {
+	in.active[path]--
+	if in.active[path] == 0 {
+		delete(in.active, path)
+	}
+	in.progressed = true
+}
+
+// checkpoint is called before the statement at the path of ctx starts. While resuming it reports whether the statement
+// already completed in a previous run and must be skipped. Otherwise it returns a continueAsNewError when the server
+// suggests to continue as new and this run made progress.
+func (in *interpreter) checkpoint(ctx workflow.Context) (bool, error) {
+	path := statementPath(ctx)
+	if in.resume != "" {
+		return !in.resuming(path), nil
+	}
+	if noCheckpoints, _ := ctx.Value(noCheckpointsKey).(bool); noCheckpoints || !in.progressed {
+		return false, nil
+	}
+	if workflow.GetInfo(ctx).GetContinueAsNewSuggested() {
+		return false, &continueAsNewError{path: path}
+	}
+	return false, nil
+}
+
+// resuming reports whether the checkpointed statement is the statement at path or nested in it.
+func (in *interpreter) resuming(path string) bool {
+	return in.resume == path || strings.HasPrefix(in.resume, path+".")
+}
+
+// drainSignals moves the buffered signals of the given names to the pending signals of the checkpoint.
+func (in *interpreter) drainSignals(ctx workflow.Context, names []string) map[string][]interface{} {
+	pending := make(map[string][]interface{})
+	for _, name := range names {
+		values := in.pending[name]
+		ch := workflow.GetSignalChannel(ctx, name)
+		for {
+			var value interface{}
+			if !ch.ReceiveAsync(&value) {
+				break
+			}
+			values = append(values, value)
+		}
+		if len(values) > 0 {
+			pending[name] = values
+		}
+	}
+	return pending
+}
+
+func (in *interpreter) state(bindings map[string]interface{}) State {
+	// Only report the innermost statements, their parents are active as well.
+	var statements []string
+	//workflowcheck:ignore Only iterates for building a sorted list
+	for path := range in.active {
+		leaf := true
+		for other := range in.active {
+			if strings.HasPrefix(other, path+".") {
+				leaf = false
+				break
+			}
+		}
+		if leaf {
+			statements = append(statements, path)
+		}
+	}
+	sort.Strings(statements)
+	return State{Statements: statements, Bindings: bindings}
+}
+
+// signalNames returns the names of all signals the statement waits for, except those of child workflows.
+func signalNames(b *Statement) []string {
+	seen := make(map[string]bool)
+	var names []string
+	var walk func(b *Statement)
+	walk = func(b *Statement) {
+		if b == nil {
+			return
+		}
+		if b.WaitForSignal != nil && !seen[b.WaitForSignal.Name] {
+			seen[b.WaitForSignal.Name] = true
+			names = append(names, b.WaitForSignal.Name)
+		}
+		if b.Sequence != nil {
+			for _, element := range b.Sequence.Elements {
+				walk(element)
+			}
+		}
+		if b.Parallel != nil {
+			for _, branch := range b.Parallel.Branches {
+				walk(branch)
+			}
+		}
+		if b.If != nil {
+			walk(b.If.Then)
+			walk(b.If.Else)
+		}
+		if b.ForEach != nil {
+			walk(b.ForEach.Body)
+		}
+		if b.While != nil {
+			walk(b.While.Body)
+		}
+		if b.Try != nil {
+			walk(b.Try.Body)
+			walk(b.Try.Catch)
+			walk(b.Try.Finally)
+		}
+	}
+	walk(b)
+	return names
+}
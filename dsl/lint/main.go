@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/temporalio/samples-go/dsl"
+)
+
+func main() {
+	var activities string
+	flag.StringVar(&activities, "activities", "", "activities specify a comma separated list of registered activity names, defaults to the methods of dsl.SampleActivities.")
+	flag.Parse()
+
+	registeredActivities := activityNames(&dsl.SampleActivities{})
+	if activities != "" {
+		registeredActivities = strings.Split(activities, ",")
+	}
+
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"dsl/workflow1.yaml"}
+	}
+	failed := false
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			log.Fatalln("failed to load dsl config file", err)
+		}
+		var dslWorkflow dsl.Workflow
+		if err := yaml.Unmarshal(data, &dslWorkflow); err != nil {
+			log.Fatalln("failed to unmarshal dsl config", err)
+		}
+		var document yaml.Node
+		if err := yaml.Unmarshal(data, &document); err != nil {
+			log.Fatalln("failed to unmarshal dsl config", err)
+		}
+
+		for _, validationErr := range dsl.Validate(dslWorkflow, registeredActivities) {
+			line, column := position(&document, validationErr.Path)
+			fmt.Printf("%s:%d:%d: %s: %s\n", file, line, column, validationErr.Path, validationErr.Message)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// activityNames returns the activity names that worker.RegisterActivity uses for the methods of activities.
+func activityNames(activities interface{}) []string {
+	var names []string
+	t := reflect.TypeOf(activities)
+	for i := 0; i < t.NumMethod(); i++ {
+		names = append(names, t.Method(i).Name)
+	}
+	return names
+}
+
+// position returns the line and column of the YAML node located by a dsl.ValidationError path. When the path does not
+// exist in the document, for example because a key was omitted, the position of the closest existing parent is used.
+func position(document *yaml.Node, path string) (int, int) {
+	node := document
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for _, segment := range strings.Split(path, ".") {
+		key := segment
+		var indexes []int
+		if i := strings.IndexByte(segment, '['); i >= 0 {
+			key = segment[:i]
+			for _, index := range strings.Split(strings.TrimSuffix(segment[i+1:], "]"), "][") {
+				n, err := strconv.Atoi(index)
+				if err != nil {
+					return node.Line, node.Column
+				}
+				indexes = append(indexes, n)
+			}
+		}
+		next := mappingValue(node, key)
+		if next == nil {
+			return node.Line, node.Column
+		}
+		node = next
+		for _, index := range indexes {
+			if node.Kind != yaml.SequenceNode || index >= len(node.Content) {
+				return node.Line, node.Column
+			}
+			node = node.Content[index]
+		}
+	}
+	return node.Line, node.Column
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
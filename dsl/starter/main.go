@@ -26,6 +26,12 @@ func main() {
 	if err := yaml.Unmarshal(data, &dslWorkflow); err != nil {
 		log.Fatalln("failed to unmarshal dsl config", err)
 	}
+	if validationErrs := dsl.Validate(dslWorkflow, nil); len(validationErrs) > 0 {
+		for _, validationErr := range validationErrs {
+			log.Println(validationErr)
+		}
+		log.Fatalln("invalid dsl config, run dsl/lint/main.go for details", dslConfig)
+	}
 
 	// The client is a heavyweight object that should be created once per process.
 	c, err := client.Dial(client.Options{
@@ -0,0 +1,411 @@
+package dsl
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+type (
+	// ValidationError describes a problem found by Validate. Path locates the offending element using the YAML keys of
+	// the workflow definition, for example `root.sequence.elements[1].activity.arguments[0]`.
+	ValidationError struct {
+		Path    string
+		Message string
+	}
+
+	validator struct {
+		activities map[string]bool
+		errs       []ValidationError
+		// loopVariables are the variables read by the conditions of the enclosing while loops, which their bodies
+		// may bind again.
+		loopVariables map[string]bool
+	}
+
+	// scope tracks the variables of one SimpleDSLWorkflow execution, a child workflow gets its own scope.
+	scope struct {
+		results map[string]string
+		reads   map[string]bool
+	}
+)
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// Validate statically checks the workflow definition before it is started. It reports activities that are not in
+// registeredActivities, references to variables that are not bound at that point, results that are never read when an
+// Output is set, empty or ambiguous statements and results that are bound more than once. Without an Output, the
+// workflow returns all of its variables, so every result is read. The activity check is skipped when
+// registeredActivities is nil.
+func Validate(dslWorkflow Workflow, registeredActivities []string) []ValidationError {
+	v := &validator{}
+	if registeredActivities != nil {
+		v.activities = make(map[string]bool)
+		for _, name := range registeredActivities {
+			v.activities[name] = true
+		}
+	}
+	defined := make(map[string]bool)
+	for k := range dslWorkflow.Variables {
+		defined[k] = true
+	}
+	v.workflow(dslWorkflow.Root, dslWorkflow.Output, "", defined)
+	return v.errs
+}
+
+func (v *validator) errorf(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) workflow(root Statement, output string, path string, defined map[string]bool) {
+	s := &scope{results: make(map[string]string), reads: make(map[string]bool)}
+	defined = v.statement(s, &root, join(path, "root"), defined)
+	if output == "" {
+		// The workflow returns all of its variables, so every result is read
+		return
+	}
+	v.reference(s, output, join(path, "output"), defined)
+	var unread []string
+	for name := range s.results {
+		if !s.reads[name] {
+			unread = append(unread, name)
+		}
+	}
+	sort.Strings(unread)
+	for _, name := range unread {
+		v.errorf(s.results[name], "result %q is never read", name)
+	}
+}
+
+func (v *validator) statement(s *scope, b *Statement, path string, defined map[string]bool) map[string]bool {
+	if b == nil {
+		v.errorf(path, "statement is empty")
+		return defined
+	}
+	var kinds []string
+	for kind, set := range map[string]bool{
+		"activity":      b.Activity != nil,
+		"childWorkflow": b.ChildWorkflow != nil,
+		"sequence":      b.Sequence != nil,
+		"parallel":      b.Parallel != nil,
+		"if":            b.If != nil,
+		"forEach":       b.ForEach != nil,
+		"while":         b.While != nil,
+		"try":           b.Try != nil,
+		"waitForSignal": b.WaitForSignal != nil,
+		"sleep":         b.Sleep != nil,
+	} {
+		if set {
+			kinds = append(kinds, kind)
+		}
+	}
+	sort.Strings(kinds)
+	switch len(kinds) {
+	case 0:
+		v.errorf(path, "statement is empty")
+	case 1:
+	default:
+		v.errorf(path, "statement sets more than one of %v", kinds)
+	}
+
+	if b.Parallel != nil {
+		defined = v.parallel(s, b.Parallel, join(path, "parallel"), defined)
+	}
+	if b.Sequence != nil {
+		defined = v.sequence(s, b.Sequence, join(path, "sequence"), defined)
+	}
+	if b.Activity != nil {
+		defined = v.activity(s, b.Activity, join(path, "activity"), defined)
+	}
+	if b.ChildWorkflow != nil {
+		defined = v.childWorkflow(s, b.ChildWorkflow, join(path, "childWorkflow"), defined)
+	}
+	if b.If != nil {
+		defined = v.ifStatement(s, b.If, join(path, "if"), defined)
+	}
+	if b.ForEach != nil {
+		defined = v.forEach(s, b.ForEach, join(path, "forEach"), defined)
+	}
+	if b.While != nil {
+		defined = v.while(s, b.While, join(path, "while"), defined)
+	}
+	if b.Try != nil {
+		defined = v.try(s, b.Try, join(path, "try"), defined)
+	}
+	if b.WaitForSignal != nil {
+		defined = v.waitForSignal(s, b.WaitForSignal, join(path, "waitForSignal"), defined)
+	}
+	if b.Sleep != nil && b.Sleep.Duration <= 0 {
+		v.errorf(join(path, "sleep.duration"), "duration must be positive")
+	}
+	return defined
+}
+
+func (v *validator) activity(s *scope, a *ActivityInvocation, path string, defined map[string]bool) map[string]bool {
+	if a.Name == "" {
+		v.errorf(join(path, "name"), "activity name is empty")
+	} else if v.activities != nil && !v.activities[a.Name] {
+		v.errorf(join(path, "name"), "activity %q is not registered", a.Name)
+	}
+	for i, arg := range a.Arguments {
+		v.reference(s, arg, index(join(path, "arguments"), i), defined)
+	}
+	for _, timeout := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"scheduleToCloseTimeout", a.ScheduleToCloseTimeout},
+		{"scheduleToStartTimeout", a.ScheduleToStartTimeout},
+		{"startToCloseTimeout", a.StartToCloseTimeout},
+		{"heartbeatTimeout", a.HeartbeatTimeout},
+	} {
+		if timeout.value < 0 {
+			v.errorf(join(path, timeout.key), "%s is negative", timeout.key)
+		}
+	}
+	if r := a.RetryPolicy; r != nil {
+		retryPath := join(path, "retryPolicy")
+		if r.InitialInterval < 0 {
+			v.errorf(join(retryPath, "initialInterval"), "initialInterval is negative")
+		}
+		if r.BackoffCoefficient != 0 && r.BackoffCoefficient < 1 {
+			v.errorf(join(retryPath, "backoffCoefficient"), "backoffCoefficient must be at least 1")
+		}
+		if r.MaximumInterval != 0 && r.MaximumInterval < r.InitialInterval {
+			v.errorf(join(retryPath, "maximumInterval"), "maximumInterval is less than initialInterval")
+		}
+		if r.MaximumAttempts < 0 {
+			v.errorf(join(retryPath, "maximumAttempts"), "maximumAttempts is negative")
+		}
+	}
+	return v.bind(s, a.Result, join(path, "result"), defined)
+}
+
+func (v *validator) childWorkflow(s *scope, c *ChildWorkflowInvocation, path string, defined map[string]bool) map[string]bool {
+	childDefined := make(map[string]bool)
+	for i, arg := range c.Arguments {
+		argPath := index(join(path, "arguments"), i)
+		v.reference(s, arg, argPath, defined)
+		name, err := argumentName(arg)
+		if err != nil {
+			continue
+		}
+		if childDefined[name] {
+			v.errorf(argPath, "argument %q is already bound in the child workflow", name)
+		}
+		childDefined[name] = true
+	}
+	// The child workflow has its own variables
+	loopVariables := v.loopVariables
+	v.loopVariables = nil
+	v.workflow(c.Root, c.Output, path, childDefined)
+	v.loopVariables = loopVariables
+	return v.bind(s, c.Result, join(path, "result"), defined)
+}
+
+func (v *validator) sequence(s *scope, seq *Sequence, path string, defined map[string]bool) map[string]bool {
+	if len(seq.Elements) == 0 {
+		v.errorf(path, "sequence has no elements")
+	}
+	for i, element := range seq.Elements {
+		defined = v.statement(s, element, index(join(path, "elements"), i), defined)
+	}
+	return defined
+}
+
+func (v *validator) parallel(s *scope, p *Parallel, path string, defined map[string]bool) map[string]bool {
+	if len(p.Branches) == 0 {
+		v.errorf(path, "parallel has no branches")
+	}
+	// Branches can't read each other's results, all of them are bound once the parallel block completes.
+	after := copySet(defined)
+	for i, branch := range p.Branches {
+		for name := range v.statement(s, branch, index(join(path, "branches"), i), copySet(defined)) {
+			if !defined[name] && after[name] {
+				v.errorf(index(join(path, "branches"), i), "result %q is bound by more than one branch", name)
+			}
+			after[name] = true
+		}
+	}
+	return after
+}
+
+func (v *validator) ifStatement(s *scope, i *If, path string, defined map[string]bool) map[string]bool {
+	v.condition(s, i.Condition, join(path, "condition"), defined)
+	if i.Then == nil && i.Else == nil {
+		v.errorf(path, "if has neither then nor else")
+	}
+	// Only one of the branches runs, so they may bind the same results. A variable bound by either of them counts
+	// as bound afterwards.
+	after := copySet(defined)
+	if i.Then != nil {
+		for name := range v.statement(s, i.Then, join(path, "then"), copySet(defined)) {
+			after[name] = true
+		}
+	}
+	if i.Else != nil {
+		for name := range v.statement(s, i.Else, join(path, "else"), copySet(defined)) {
+			after[name] = true
+		}
+	}
+	return after
+}
+
+func (v *validator) forEach(s *scope, f *ForEach, path string, defined map[string]bool) map[string]bool {
+	if f.In == "" && len(f.Items) == 0 {
+		v.errorf(path, "forEach has neither in nor items")
+	}
+	if f.In != "" {
+		v.reference(s, f.In, join(path, "in"), defined)
+	}
+	for i, item := range f.Items {
+		v.reference(s, item, index(join(path, "items"), i), defined)
+	}
+	if f.MaxIterations < 0 {
+		v.errorf(join(path, "maxIterations"), "maxIterations is negative")
+	}
+	defined = copySet(defined)
+	if f.Item != "" {
+		defined[f.Item] = true
+	}
+	if f.Body == nil {
+		v.errorf(join(path, "body"), "forEach has no body")
+		return defined
+	}
+	return v.statement(s, f.Body, join(path, "body"), defined)
+}
+
+func (v *validator) while(s *scope, w *While, path string, defined map[string]bool) map[string]bool {
+	if w.MaxIterations < 0 {
+		v.errorf(join(path, "maxIterations"), "maxIterations is negative")
+	}
+	// The condition is evaluated before the first iteration, and the body may run zero times, so neither the condition
+	// nor the following statements may read the results of the body. The body may update the variables of the
+	// condition instead.
+	v.condition(s, w.Condition, join(path, "condition"), defined)
+	if w.Body == nil {
+		v.errorf(join(path, "body"), "while has no body")
+		return defined
+	}
+	loopVariables := v.loopVariables
+	v.loopVariables = copySet(loopVariables)
+	tokens, _ := tokenize(w.Condition)
+	for _, t := range tokens {
+		if name, _, err := parseReference(t.text); t.kind == tokenReference && err == nil && defined[name] {
+			v.loopVariables[name] = true
+		}
+	}
+	v.statement(s, w.Body, join(path, "body"), copySet(defined))
+	v.loopVariables = loopVariables
+	return defined
+}
+
+func (v *validator) try(s *scope, t *Try, path string, defined map[string]bool) map[string]bool {
+	if t.Body == nil {
+		v.errorf(join(path, "body"), "try has no body")
+	}
+	if t.Catch == nil && t.Finally == nil {
+		v.errorf(path, "try has neither catch nor finally")
+	}
+	if t.Error != "" && t.Catch == nil {
+		v.errorf(join(path, "error"), "error is set without a catch")
+	}
+	// Body may fail at any point, so Catch and Finally only see the variables bound before the try. A variable bound
+	// by any of the blocks counts as bound afterwards.
+	after := copySet(defined)
+	if t.Body != nil {
+		for name := range v.statement(s, t.Body, join(path, "body"), copySet(defined)) {
+			after[name] = true
+		}
+	}
+	if t.Catch != nil {
+		catchDefined := copySet(defined)
+		if t.Error != "" {
+			catchDefined[t.Error] = true
+		}
+		for name := range v.statement(s, t.Catch, join(path, "catch"), catchDefined) {
+			after[name] = true
+		}
+	}
+	if t.Finally != nil {
+		for name := range v.statement(s, t.Finally, join(path, "finally"), copySet(defined)) {
+			after[name] = true
+		}
+	}
+	return after
+}
+
+func (v *validator) waitForSignal(s *scope, w *WaitForSignal, path string, defined map[string]bool) map[string]bool {
+	if w.Name == "" {
+		v.errorf(join(path, "name"), "signal name is empty")
+	}
+	if w.Timeout < 0 {
+		v.errorf(join(path, "timeout"), "timeout is negative")
+	}
+	return v.bind(s, w.Result, join(path, "result"), defined)
+}
+
+func (v *validator) condition(s *scope, condition string, path string, defined map[string]bool) {
+	if condition == "" {
+		v.errorf(path, "condition is empty")
+		return
+	}
+	err := checkCondition(condition)
+	if err != nil {
+		v.errorf(path, "%v", err)
+		return
+	}
+	tokens, _ := tokenize(condition)
+	for _, t := range tokens {
+		if t.kind == tokenReference && t.text != "true" && t.text != "false" && t.text != "null" {
+			v.reference(s, t.text, path, defined)
+		}
+	}
+}
+
+func (v *validator) reference(s *scope, ref string, path string, defined map[string]bool) {
+	name, _, err := parseReference(ref)
+	if err != nil {
+		v.errorf(path, "%v", err)
+		return
+	}
+	s.reads[name] = true
+	if !defined[name] {
+		v.errorf(path, "variable %q is not bound", name)
+	}
+}
+
+func (v *validator) bind(s *scope, result string, path string, defined map[string]bool) map[string]bool {
+	if result == "" {
+		return defined
+	}
+	if defined[result] && !v.loopVariables[result] {
+		v.errorf(path, "result %q is already bound", result)
+	}
+	if _, ok := s.results[result]; !ok {
+		s.results[result] = path
+	}
+	defined = copySet(defined)
+	defined[result] = true
+	return defined
+}
+
+func copySet(set map[string]bool) map[string]bool {
+	c := make(map[string]bool, len(set))
+	for k := range set {
+		c[k] = true
+	}
+	return c
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func index(path string, i int) string {
+	return fmt.Sprintf("%s[%d]", path, i)
+}
@@ -0,0 +1,90 @@
+package dsl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var sampleActivities = []string{"SampleActivity1", "SampleActivity2", "SampleActivity3", "SampleActivity4", "SampleActivity5"}
+
+func Test_Validate_SampleWorkflows(t *testing.T) {
+	for _, path := range []string{"workflow1.yaml", "workflow2.yaml", "workflow3.yaml", "workflow4.yaml", "workflow5.yaml", "workflow6.yaml"} {
+		require.Empty(t, Validate(loadWorkflow(t, path), sampleActivities), path)
+	}
+}
+
+func Test_Validate_Errors(t *testing.T) {
+	dslWorkflow := Workflow{
+		Variables: map[string]interface{}{"arg1": "value1"},
+		Output:    "result3",
+		Root: Statement{Sequence: &Sequence{Elements: []*Statement{
+			{Activity: &ActivityInvocation{Name: "Unknown", Arguments: []string{"arg2"}, Result: "result1"}},
+			{Activity: &ActivityInvocation{Name: "SampleActivity1", Arguments: []string{"arg1"}, Result: "result1"}},
+			{Sequence: &Sequence{}},
+			{Parallel: &Parallel{Branches: []*Statement{
+				{Activity: &ActivityInvocation{Name: "SampleActivity2", Result: "result2"}},
+				{Activity: &ActivityInvocation{Name: "SampleActivity3", Arguments: []string{"result2"}, Result: "result3"}},
+			}}},
+			{If: &If{Condition: "result1 ==", Then: &Statement{}}},
+		}}},
+	}
+
+	require.Equal(t, []ValidationError{
+		{Path: "root.sequence.elements[0].activity.name", Message: `activity "Unknown" is not registered`},
+		{Path: "root.sequence.elements[0].activity.arguments[0]", Message: `variable "arg2" is not bound`},
+		{Path: "root.sequence.elements[1].activity.result", Message: `result "result1" is already bound`},
+		{Path: "root.sequence.elements[2].sequence", Message: "sequence has no elements"},
+		{Path: "root.sequence.elements[3].parallel.branches[1].activity.arguments[0]", Message: `variable "result2" is not bound`},
+		{Path: "root.sequence.elements[4].if.condition", Message: "unexpected end of condition"},
+		{Path: "root.sequence.elements[4].if.then", Message: "statement is empty"},
+		{Path: "root.sequence.elements[0].activity.result", Message: `result "result1" is never read`},
+	}, Validate(dslWorkflow, sampleActivities))
+}
+
+func Test_Validate_ChildWorkflowScope(t *testing.T) {
+	dslWorkflow := Workflow{
+		Variables: map[string]interface{}{"arg1": "value1", "arg2": "value2"},
+		Root: Statement{ChildWorkflow: &ChildWorkflowInvocation{
+			Arguments: []string{"arg1", "arg2.id", "arg2.items[0].id"},
+			Root: Statement{Activity: &ActivityInvocation{
+				Name:      "SampleActivity1",
+				Arguments: []string{"arg1", "arg2", "id"},
+			}},
+		}},
+	}
+
+	require.Equal(t, []ValidationError{
+		{Path: "root.childWorkflow.arguments[2]", Message: `argument "id" is already bound in the child workflow`},
+		{Path: "root.childWorkflow.root.activity.arguments[1]", Message: `variable "arg2" is not bound`},
+	}, Validate(dslWorkflow, nil))
+}
+
+func Test_Validate_While(t *testing.T) {
+	dslWorkflow := Workflow{
+		Output: "status",
+		Root: Statement{Sequence: &Sequence{Elements: []*Statement{
+			{While: &While{
+				Condition: "status != 'done'",
+				Body:      &Statement{Activity: &ActivityInvocation{Name: "SampleActivity1", Result: "status"}},
+			}},
+		}}},
+	}
+
+	// The condition is evaluated before the body binds status, which is not bound either if the body never runs
+	require.Equal(t, []ValidationError{
+		{Path: "root.sequence.elements[0].while.condition", Message: `variable "status" is not bound`},
+		{Path: "output", Message: `variable "status" is not bound`},
+	}, Validate(dslWorkflow, sampleActivities))
+
+	// The body may update the variables of the condition, but no other variable
+	dslWorkflow.Variables = map[string]interface{}{"status": "pending", "attempt": 0}
+	require.Empty(t, Validate(dslWorkflow, sampleActivities))
+	dslWorkflow.Root.Sequence.Elements[0].While.Body = &Statement{Sequence: &Sequence{Elements: []*Statement{
+		{Activity: &ActivityInvocation{Name: "SampleActivity1", Arguments: []string{"attempt"}, Result: "attempt"}},
+		{Activity: &ActivityInvocation{Name: "SampleActivity2", Arguments: []string{"attempt"}, Result: "status"}},
+	}}}
+	require.Equal(t, []ValidationError{
+		{Path: "root.sequence.elements[0].while.body.sequence.elements[0].activity.result", Message: `result "attempt" is already bound`},
+	}, Validate(dslWorkflow, sampleActivities))
+}
@@ -1,25 +1,41 @@
 package dsl
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
+	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 )
 
 type (
-	// Workflow is the type used to express the workflow definition. Variables are a map of valuables. Variables can be
-	// used as input to Activity.
+	// Workflow is the type used to express the workflow definition. Variables are a map of valuables. Variables can
+	// hold any JSON value and can be used as input to Activity. Output optionally references the value that is returned
+	// as the JSON encoded workflow result, all bindings are returned when it is empty. Checkpoint is set by
+	// SimpleDSLWorkflow when it continues as new and is not part of the YAML definition.
 	Workflow struct {
-		Variables map[string]string
-		Root      Statement
+		Variables  map[string]interface{}
+		Root       Statement
+		Output     string
+		Checkpoint *Checkpoint `yaml:"-"`
 	}
 
-	// Statement is the building block of dsl workflow. A Statement can be a simple ActivityInvocation or it
-	// could be a Sequence or Parallel.
+	// Statement is the building block of dsl workflow. A Statement can be a simple ActivityInvocation, a
+	// ChildWorkflowInvocation, a WaitForSignal or a Sleep or it could be a Sequence, Parallel, If, ForEach, While or
+	// Try.
 	Statement struct {
-		Activity *ActivityInvocation
-		Sequence *Sequence
-		Parallel *Parallel
+		Activity      *ActivityInvocation
+		ChildWorkflow *ChildWorkflowInvocation `yaml:"childWorkflow"`
+		Sequence      *Sequence
+		Parallel      *Parallel
+		If            *If
+		ForEach       *ForEach `yaml:"forEach"`
+		While         *While
+		Try           *Try
+		WaitForSignal *WaitForSignal `yaml:"waitForSignal"`
+		Sleep         *Sleep
 	}
 
 	// Sequence consist of a collection of Statements that runs in sequential.
@@ -32,23 +48,104 @@ type (
 		Branches []*Statement
 	}
 
-	// ActivityInvocation is used to express invoking an Activity. The Arguments defined expected arguments as input to
-	// the Activity, the result specify the name of variable that it will store the result as which can then be used as
-	// arguments to subsequent ActivityInvocation.
-	ActivityInvocation struct {
-		Name      string
+	// If runs Then when Condition evaluates to true and Else otherwise. Condition is a small expression over the
+	// bindings, for example `result1 == 'Result_SampleActivity1' && arg2 != ''`.
+	If struct {
+		Condition string
+		Then      *Statement
+		Else      *Statement
+	}
+
+	// ForEach runs Body once for every element of the list referenced by In, or for every value referenced by Items,
+	// binding the current value to the variable named by Item. MaxIterations caps the number of iterations,
+	// defaultMaxIterations is used when it is not set.
+	ForEach struct {
+		In            string
+		Items         []string
+		Item          string
+		Body          *Statement
+		MaxIterations int `yaml:"maxIterations"`
+	}
+
+	// While runs Body for as long as Condition evaluates to true. MaxIterations caps the number of iterations,
+	// defaultMaxIterations is used when it is not set.
+	While struct {
+		Condition     string
+		Body          *Statement
+		MaxIterations int `yaml:"maxIterations"`
+	}
+
+	// WaitForSignal blocks until the signal named by Name is received and stores its value in the variable named by
+	// Result. When Timeout is set and elapses first, Result is bound to null instead.
+	WaitForSignal struct {
+		Name    string
+		Result  string
+		Timeout time.Duration
+	}
+
+	// Sleep blocks for Duration using a durable timer.
+	Sleep struct {
+		Duration time.Duration
+	}
+
+	// Try runs Body and, when it fails, runs Catch with the failure bound to the variable named by Error as an object
+	// with `message` and `type` fields. A failure handled by Catch does not fail the workflow. Finally always runs last.
+	// Catch and Finally also run when the workflow is cancelled, so they can be used for compensating steps.
+	Try struct {
+		Body    *Statement
+		Catch   *Statement
+		Error   string
+		Finally *Statement
+	}
+
+	// ChildWorkflowInvocation is used to run Root as a SimpleDSLWorkflow child workflow. The Arguments reference the
+	// values that are copied into the child workflow's Variables. A plain variable keeps its name, a value inside of a
+	// variable is named by the last field of its path, so `result1.items[0].id` is bound as `id`. The child workflow's
+	// output, selected by Output, is stored in the variable named by Result.
+	ChildWorkflowInvocation struct {
 		Arguments []string
+		Root      Statement
+		Output    string
 		Result    string
 	}
 
+	// ActivityInvocation is used to express invoking an Activity. The Arguments defined expected arguments as input to
+	// the Activity, each argument references a variable or a value inside of it like `result1.items[0].id`. The result
+	// specify the name of variable that it will store the result as which can then be used as arguments to subsequent
+	// ActivityInvocation. The optional timeouts, task queue and retry policy override the workflow's defaults.
+	ActivityInvocation struct {
+		Name                   string
+		Arguments              []string
+		Result                 string
+		TaskQueue              string        `yaml:"taskQueue"`
+		ScheduleToCloseTimeout time.Duration `yaml:"scheduleToCloseTimeout"`
+		ScheduleToStartTimeout time.Duration `yaml:"scheduleToStartTimeout"`
+		StartToCloseTimeout    time.Duration `yaml:"startToCloseTimeout"`
+		HeartbeatTimeout       time.Duration `yaml:"heartbeatTimeout"`
+		RetryPolicy            *RetryPolicy  `yaml:"retryPolicy"`
+	}
+
+	// RetryPolicy is the retry policy of an ActivityInvocation, unset fields use the server defaults. Activity
+	// failures whose type is listed in NonRetryableErrorTypes are not retried.
+	RetryPolicy struct {
+		InitialInterval        time.Duration `yaml:"initialInterval"`
+		BackoffCoefficient     float64       `yaml:"backoffCoefficient"`
+		MaximumInterval        time.Duration `yaml:"maximumInterval"`
+		MaximumAttempts        int32         `yaml:"maximumAttempts"`
+		NonRetryableErrorTypes []string      `yaml:"nonRetryableErrorTypes"`
+	}
+
 	executable interface {
-		execute(ctx workflow.Context, bindings map[string]string) error
+		execute(ctx workflow.Context, bindings map[string]interface{}) error
 	}
 )
 
+// defaultMaxIterations is the iteration cap of ForEach and While statements that do not set MaxIterations.
+const defaultMaxIterations = 100
+
 // SimpleDSLWorkflow workflow definition
 func SimpleDSLWorkflow(ctx workflow.Context, dslWorkflow Workflow) ([]byte, error) {
-	bindings := make(map[string]string)
+	bindings := make(map[string]interface{})
 	//workflowcheck:ignore Only iterates for building another map
 	for k, v := range dslWorkflow.Variables {
 		bindings[k] = v
@@ -60,31 +157,104 @@ func SimpleDSLWorkflow(ctx workflow.Context, dslWorkflow Workflow) ([]byte, erro
 	ctx = workflow.WithActivityOptions(ctx, ao)
 	logger := workflow.GetLogger(ctx)
 
-	err := dslWorkflow.Root.execute(ctx, bindings)
+	in := newInterpreter(dslWorkflow.Checkpoint)
+	err := workflow.SetQueryHandler(ctx, StateQueryName, func() (State, error) {
+		return in.state(bindings), nil
+	})
+	if err != nil {
+		logger.Error("SetQueryHandler failed.", "Error", err)
+		return nil, err
+	}
+	ctx = workflow.WithValue(ctx, interpreterKey, in)
+
+	err = dslWorkflow.Root.execute(withPath(ctx, "root"), bindings)
+	var canErr *continueAsNewError
+	if errors.As(err, &canErr) {
+		logger.Info("DSL Workflow continues as new.", "Checkpoint", canErr.path)
+		dslWorkflow.Variables = bindings
+		dslWorkflow.Checkpoint = &Checkpoint{
+			Path:           canErr.path,
+			PendingSignals: in.drainSignals(ctx, signalNames(&dslWorkflow.Root)),
+		}
+		return nil, workflow.NewContinueAsNewError(ctx, SimpleDSLWorkflow, dslWorkflow)
+	}
 	if err != nil {
 		logger.Error("DSL Workflow failed.", "Error", err)
 		return nil, err
 	}
 
+	output, err := makeOutput(dslWorkflow.Output, bindings)
+	if err != nil {
+		logger.Error("DSL Workflow failed to encode output.", "Error", err)
+		return nil, err
+	}
+
 	logger.Info("DSL Workflow completed.")
-	return nil, err
+	return output, nil
 }
 
-func (b *Statement) execute(ctx workflow.Context, bindings map[string]string) error {
+func (b *Statement) execute(ctx workflow.Context, bindings map[string]interface{}) error {
+	path := statementPath(ctx)
+	in := getInterpreter(ctx)
+	in.enter(path)
+	defer in.exit(path)
+
 	if b.Parallel != nil {
-		err := b.Parallel.execute(ctx, bindings)
+		err := b.Parallel.execute(withPath(ctx, join(path, "parallel")), bindings)
 		if err != nil {
 			return err
 		}
 	}
 	if b.Sequence != nil {
-		err := b.Sequence.execute(ctx, bindings)
+		err := b.Sequence.execute(withPath(ctx, join(path, "sequence")), bindings)
 		if err != nil {
 			return err
 		}
 	}
 	if b.Activity != nil {
-		err := b.Activity.execute(ctx, bindings)
+		err := b.Activity.execute(withPath(ctx, join(path, "activity")), bindings)
+		if err != nil {
+			return err
+		}
+	}
+	if b.ChildWorkflow != nil {
+		err := b.ChildWorkflow.execute(withPath(ctx, join(path, "childWorkflow")), bindings)
+		if err != nil {
+			return err
+		}
+	}
+	if b.If != nil {
+		err := b.If.execute(withPath(ctx, join(path, "if")), bindings)
+		if err != nil {
+			return err
+		}
+	}
+	if b.ForEach != nil {
+		err := b.ForEach.execute(withPath(ctx, join(path, "forEach")), bindings)
+		if err != nil {
+			return err
+		}
+	}
+	if b.While != nil {
+		err := b.While.execute(withPath(ctx, join(path, "while")), bindings)
+		if err != nil {
+			return err
+		}
+	}
+	if b.Try != nil {
+		err := b.Try.execute(withPath(ctx, join(path, "try")), bindings)
+		if err != nil {
+			return err
+		}
+	}
+	if b.WaitForSignal != nil {
+		err := b.WaitForSignal.execute(withPath(ctx, join(path, "waitForSignal")), bindings)
+		if err != nil {
+			return err
+		}
+	}
+	if b.Sleep != nil {
+		err := b.Sleep.execute(withPath(ctx, join(path, "sleep")), bindings)
 		if err != nil {
 			return err
 		}
@@ -92,10 +262,14 @@ func (b *Statement) execute(ctx workflow.Context, bindings map[string]string) er
 	return nil
 }
 
-func (a ActivityInvocation) execute(ctx workflow.Context, bindings map[string]string) error {
-	inputParam := makeInput(a.Arguments, bindings)
-	var result string
-	err := workflow.ExecuteActivity(ctx, a.Name, inputParam).Get(ctx, &result)
+func (a ActivityInvocation) execute(ctx workflow.Context, bindings map[string]interface{}) error {
+	inputParam, err := makeInput(a.Arguments, bindings)
+	if err != nil {
+		return err
+	}
+	ctx = workflow.WithActivityOptions(ctx, a.activityOptions(workflow.GetActivityOptions(ctx)))
+	var result interface{}
+	err = workflow.ExecuteActivity(ctx, a.Name, inputParam).Get(ctx, &result)
 	if err != nil {
 		return err
 	}
@@ -105,17 +279,279 @@ func (a ActivityInvocation) execute(ctx workflow.Context, bindings map[string]st
 	return nil
 }
 
-func (s Sequence) execute(ctx workflow.Context, bindings map[string]string) error {
-	for _, a := range s.Elements {
-		err := a.execute(ctx, bindings)
+func (a ActivityInvocation) activityOptions(ao workflow.ActivityOptions) workflow.ActivityOptions {
+	if a.TaskQueue != "" {
+		ao.TaskQueue = a.TaskQueue
+	}
+	if a.ScheduleToCloseTimeout != 0 {
+		ao.ScheduleToCloseTimeout = a.ScheduleToCloseTimeout
+	}
+	if a.ScheduleToStartTimeout != 0 {
+		ao.ScheduleToStartTimeout = a.ScheduleToStartTimeout
+	}
+	if a.StartToCloseTimeout != 0 {
+		ao.StartToCloseTimeout = a.StartToCloseTimeout
+	}
+	if a.HeartbeatTimeout != 0 {
+		ao.HeartbeatTimeout = a.HeartbeatTimeout
+	}
+	if a.RetryPolicy != nil {
+		ao.RetryPolicy = &temporal.RetryPolicy{
+			InitialInterval:        a.RetryPolicy.InitialInterval,
+			BackoffCoefficient:     a.RetryPolicy.BackoffCoefficient,
+			MaximumInterval:        a.RetryPolicy.MaximumInterval,
+			MaximumAttempts:        a.RetryPolicy.MaximumAttempts,
+			NonRetryableErrorTypes: a.RetryPolicy.NonRetryableErrorTypes,
+		}
+	}
+	return ao
+}
+
+func (t Try) execute(ctx workflow.Context, bindings map[string]interface{}) error {
+	path := statementPath(ctx)
+	ctx = withoutCheckpoints(ctx)
+	var err error
+	if t.Body != nil {
+		err = t.Body.execute(withPath(ctx, join(path, "body")), bindings)
+	}
+	if err != nil && t.Catch != nil {
+		if t.Error != "" {
+			bindings[t.Error] = makeError(err)
+		}
+		err = t.Catch.execute(withPath(recoveryContext(ctx), join(path, "catch")), bindings)
+	}
+	if t.Finally != nil {
+		finallyErr := t.Finally.execute(withPath(recoveryContext(ctx), join(path, "finally")), bindings)
+		if err == nil {
+			err = finallyErr
+		}
+	}
+	return err
+}
+
+// recoveryContext returns a context that is not cancelled when the workflow was cancelled, so that Catch and Finally
+// can still run activities.
+func recoveryContext(ctx workflow.Context) workflow.Context {
+	if ctx.Err() == nil {
+		return ctx
+	}
+	ctx, _ = workflow.NewDisconnectedContext(ctx)
+	return ctx
+}
+
+// makeError converts a failure to the value that is bound to the Try.Error variable.
+func makeError(err error) map[string]interface{} {
+	message, errorType := err.Error(), ""
+	var applicationErr *temporal.ApplicationError
+	var canceledErr *temporal.CanceledError
+	var timeoutErr *temporal.TimeoutError
+	switch {
+	case errors.As(err, &applicationErr):
+		message, errorType = applicationErr.Message(), applicationErr.Type()
+	case errors.As(err, &canceledErr):
+		errorType = "Canceled"
+	case errors.As(err, &timeoutErr):
+		errorType = "Timeout"
+	}
+	return map[string]interface{}{
+		"message": message,
+		"type":    errorType,
+	}
+}
+
+func (w WaitForSignal) execute(ctx workflow.Context, bindings map[string]interface{}) error {
+	in := getInterpreter(ctx)
+	var value interface{}
+	if pending := in.pending[w.Name]; len(pending) > 0 {
+		// The signal was received by the run that continued as new.
+		value, in.pending[w.Name] = pending[0], pending[1:]
+	} else {
+		ch := workflow.GetSignalChannel(ctx, w.Name)
+		if w.Timeout > 0 {
+			ch.ReceiveWithTimeout(ctx, w.Timeout, &value)
+		} else {
+			ch.Receive(ctx, &value)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	if w.Result != "" {
+		bindings[w.Result] = value
+	}
+	return nil
+}
+
+func (s Sleep) execute(ctx workflow.Context, bindings map[string]interface{}) error {
+	return workflow.Sleep(ctx, s.Duration)
+}
+
+func (c ChildWorkflowInvocation) execute(ctx workflow.Context, bindings map[string]interface{}) error {
+	child := Workflow{
+		Variables: make(map[string]interface{}),
+		Root:      c.Root,
+		Output:    c.Output,
+	}
+	for _, arg := range c.Arguments {
+		name, err := argumentName(arg)
 		if err != nil {
 			return err
 		}
+		value, err := resolveReference(arg, bindings)
+		if err != nil {
+			return err
+		}
+		child.Variables[name] = value
+	}
+	var output []byte
+	err := workflow.ExecuteChildWorkflow(ctx, SimpleDSLWorkflow, child).Get(ctx, &output)
+	if err != nil {
+		return err
+	}
+	if c.Result != "" {
+		var result interface{}
+		if err := json.Unmarshal(output, &result); err != nil {
+			return err
+		}
+		bindings[c.Result] = result
 	}
 	return nil
 }
 
-func (p Parallel) execute(ctx workflow.Context, bindings map[string]string) error {
+func (i If) execute(ctx workflow.Context, bindings map[string]interface{}) error {
+	path := statementPath(ctx)
+	in := getInterpreter(ctx)
+	var ok bool
+	switch {
+	case in.resume != "":
+		// The condition was evaluated by the run that checkpointed inside one of the branches.
+		ok = in.resuming(join(path, "then"))
+	default:
+		var err error
+		ok, err = evaluateCondition(i.Condition, bindings)
+		if err != nil {
+			return err
+		}
+	}
+	if ok {
+		if i.Then != nil {
+			return i.Then.execute(withPath(ctx, join(path, "then")), bindings)
+		}
+		return nil
+	}
+	if i.Else != nil {
+		return i.Else.execute(withPath(ctx, join(path, "else")), bindings)
+	}
+	return nil
+}
+
+func (f ForEach) execute(ctx workflow.Context, bindings map[string]interface{}) error {
+	items, err := makeInput(f.Items, bindings)
+	if err != nil {
+		return err
+	}
+	if f.In != "" {
+		value, err := resolveReference(f.In, bindings)
+		if err != nil {
+			return err
+		}
+		list, ok := value.([]interface{})
+		if value != nil && !ok {
+			return fmt.Errorf("forEach over %q requires a list, got %T", f.In, value)
+		}
+		items = append(items, list...)
+	}
+	if len(items) > maxIterations(f.MaxIterations) {
+		return fmt.Errorf("forEach over %d items exceeds the limit of %d iterations", len(items), maxIterations(f.MaxIterations))
+	}
+	path := statementPath(ctx)
+	in := getInterpreter(ctx)
+	for i, item := range items {
+		bodyCtx := withPath(ctx, index(join(path, "body"), i))
+		skip, err := in.checkpoint(bodyCtx)
+		if err != nil {
+			return err
+		}
+		if skip {
+			continue
+		}
+		if f.Item != "" {
+			bindings[f.Item] = item
+		}
+		if f.Body == nil {
+			continue
+		}
+		err = f.Body.execute(bodyCtx, bindings)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w While) execute(ctx workflow.Context, bindings map[string]interface{}) error {
+	path := statementPath(ctx)
+	in := getInterpreter(ctx)
+	for i := 0; ; i++ {
+		bodyCtx := withPath(ctx, index(join(path, "body"), i))
+		skip, err := in.checkpoint(bodyCtx)
+		if err != nil {
+			return err
+		}
+		if skip {
+			continue
+		}
+		if in.resume == "" || in.resume == statementPath(bodyCtx) {
+			// A run that checkpointed inside of the body already evaluated the condition of this iteration.
+			ok, err := evaluateCondition(w.Condition, bindings)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return nil
+			}
+		}
+		if i == maxIterations(w.MaxIterations) {
+			return fmt.Errorf("while loop exceeded the limit of %d iterations", i)
+		}
+		if w.Body == nil {
+			continue
+		}
+		err = w.Body.execute(bodyCtx, bindings)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func maxIterations(limit int) int {
+	if limit <= 0 {
+		return defaultMaxIterations
+	}
+	return limit
+}
+
+func (s Sequence) execute(ctx workflow.Context, bindings map[string]interface{}) error {
+	path := statementPath(ctx)
+	in := getInterpreter(ctx)
+	for i, a := range s.Elements {
+		elementCtx := withPath(ctx, index(join(path, "elements"), i))
+		skip, err := in.checkpoint(elementCtx)
+		if err != nil {
+			return err
+		}
+		if skip {
+			continue
+		}
+		err = a.execute(elementCtx, bindings)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p Parallel) execute(ctx workflow.Context, bindings map[string]interface{}) error {
 	//
 	// You can use the context passed in to activity as a way to cancel the activity like standard GO way.
 	// Cancelling a parent context will cancel all the derived contexts as well.
@@ -123,11 +559,12 @@ func (p Parallel) execute(ctx workflow.Context, bindings map[string]string) erro
 
 	// In the parallel block, we want to execute all of them in parallel and wait for all of them.
 	// if one activity fails then we want to cancel all the rest of them as well.
-	childCtx, cancelHandler := workflow.WithCancel(ctx)
+	path := statementPath(ctx)
+	childCtx, cancelHandler := workflow.WithCancel(withoutCheckpoints(ctx))
 	selector := workflow.NewSelector(ctx)
 	var activityErr error
-	for _, s := range p.Branches {
-		f := executeAsync(s, childCtx, bindings)
+	for i, s := range p.Branches {
+		f := executeAsync(s, withPath(childCtx, index(join(path, "branches"), i)), bindings)
 		selector.AddFuture(f, func(f workflow.Future) {
 			err := f.Get(ctx, nil)
 			if err != nil {
@@ -148,7 +585,7 @@ func (p Parallel) execute(ctx workflow.Context, bindings map[string]string) erro
 	return nil
 }
 
-func executeAsync(exe executable, ctx workflow.Context, bindings map[string]string) workflow.Future {
+func executeAsync(exe executable, ctx workflow.Context, bindings map[string]interface{}) workflow.Future {
 	future, settable := workflow.NewFuture(ctx)
 	workflow.Go(ctx, func(ctx workflow.Context) {
 		err := exe.execute(ctx, bindings)
@@ -157,10 +594,25 @@ func executeAsync(exe executable, ctx workflow.Context, bindings map[string]stri
 	return future
 }
 
-func makeInput(argNames []string, argsMap map[string]string) []string {
-	var args []string
+func makeInput(argNames []string, argsMap map[string]interface{}) ([]interface{}, error) {
+	var args []interface{}
 	for _, arg := range argNames {
-		args = append(args, argsMap[arg])
+		value, err := resolveReference(arg, argsMap)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+	return args, nil
+}
+
+func makeOutput(output string, bindings map[string]interface{}) ([]byte, error) {
+	if output == "" {
+		return json.Marshal(bindings)
+	}
+	value, err := resolveReference(output, bindings)
+	if err != nil {
+		return nil, err
 	}
-	return args
+	return json.Marshal(value)
 }
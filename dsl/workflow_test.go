@@ -0,0 +1,478 @@
+package dsl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+	"gopkg.in/yaml.v3"
+)
+
+func loadWorkflow(t *testing.T, path string) Workflow {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var dslWorkflow Workflow
+	require.NoError(t, yaml.Unmarshal(data, &dslWorkflow))
+	return dslWorkflow
+}
+
+func Test_Workflow1(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	env.RegisterActivity(&SampleActivities{})
+
+	env.ExecuteWorkflow(SimpleDSLWorkflow, loadWorkflow(t, "workflow1.yaml"))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+}
+
+func Test_Workflow3(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(SimpleDSLWorkflow)
+	var a *SampleActivities
+	env.OnActivity(a.SampleActivity1, mock.Anything, []interface{}{"value1"}).Return("Result_SampleActivity1", nil).Once()
+	env.OnActivity(a.SampleActivity2, mock.Anything, []interface{}{"Result_SampleActivity1"}).Return("Result_SampleActivity2", nil).Once()
+	env.OnActivity(a.SampleActivity4, mock.Anything, []interface{}{"value2"}).Return("Result_SampleActivity4", nil).Once()
+	env.OnActivity(a.SampleActivity4, mock.Anything, []interface{}{"value3"}).Return("Result_SampleActivity4", nil).Once()
+	env.OnActivity(a.SampleActivity5, mock.Anything, []interface{}{"Result_SampleActivity1"}).Return("Result_SampleActivity5", nil).Once()
+
+	env.ExecuteWorkflow(SimpleDSLWorkflow, loadWorkflow(t, "workflow3.yaml"))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+}
+
+func Test_Workflow4(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	var a *SampleActivities
+	env.OnActivity(a.SampleActivity1, mock.Anything, []interface{}{"order-1", "item-1"}).Return("first", nil).Once()
+	env.OnActivity(a.SampleActivity1, mock.Anything, []interface{}{"order-1", "item-3"}).Return("last", nil).Once()
+
+	env.ExecuteWorkflow(SimpleDSLWorkflow, loadWorkflow(t, "workflow4.yaml"))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var output []byte
+	require.NoError(t, env.GetWorkflowResult(&output))
+	require.JSONEq(t, `"last"`, string(output))
+	env.AssertExpectations(t)
+}
+
+func Test_Workflow5(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	var a *SampleActivities
+	env.OnActivity(a.SampleActivity1, mock.Anything, []interface{}{"value1"}).Return("Result_SampleActivity1", nil).Once()
+	env.OnActivity(a.SampleActivity2, mock.Anything, []interface{}{"Result_SampleActivity1"}).Return("Result_SampleActivity2", nil).Once()
+	env.OnActivity(a.SampleActivity4, mock.Anything, []interface{}{"value1"}).Return("Result_SampleActivity4", nil).Once()
+	var infos []activity.Info
+	env.SetOnActivityStartedListener(func(activityInfo *activity.Info, ctx context.Context, args converter.EncodedValues) {
+		infos = append(infos, *activityInfo)
+	})
+
+	env.ExecuteWorkflow(SimpleDSLWorkflow, loadWorkflow(t, "workflow5.yaml"))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+	require.Len(t, infos, 3)
+	require.Equal(t, "dsl", infos[1].TaskQueue)
+	require.Equal(t, 5*time.Second, infos[1].HeartbeatTimeout)
+}
+
+func Test_ActivityOptions(t *testing.T) {
+	dslWorkflow := loadWorkflow(t, "workflow5.yaml")
+	elements := dslWorkflow.Root.Try.Body.Sequence.Elements
+	defaults := workflow.ActivityOptions{StartToCloseTimeout: time.Minute, TaskQueue: "default"}
+
+	ao := elements[0].Activity.activityOptions(defaults)
+	require.Equal(t, 10*time.Second, ao.StartToCloseTimeout)
+	require.Equal(t, "default", ao.TaskQueue)
+	require.Equal(t, &temporal.RetryPolicy{
+		InitialInterval:        time.Second,
+		BackoffCoefficient:     2,
+		MaximumAttempts:        3,
+		NonRetryableErrorTypes: []string{"InvalidInput"},
+	}, ao.RetryPolicy)
+
+	ao = elements[1].Activity.activityOptions(defaults)
+	require.Equal(t, 30*time.Second, ao.StartToCloseTimeout)
+	require.Equal(t, 5*time.Second, ao.HeartbeatTimeout)
+	require.Equal(t, "dsl", ao.TaskQueue)
+	require.Nil(t, ao.RetryPolicy)
+}
+
+func Test_Workflow5_Catch(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	var a *SampleActivities
+	env.OnActivity(a.SampleActivity1, mock.Anything, mock.Anything).Return("", temporal.NewApplicationError("bad input", "InvalidInput")).Once()
+	env.OnActivity(a.SampleActivity3, mock.Anything, []interface{}{"bad input"}).Return("Result_SampleActivity3", nil).Once()
+	env.OnActivity(a.SampleActivity4, mock.Anything, []interface{}{"value1"}).Return("Result_SampleActivity4", nil).Once()
+
+	env.ExecuteWorkflow(SimpleDSLWorkflow, loadWorkflow(t, "workflow5.yaml"))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+}
+
+func Test_Try_RetryAndFinally(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	var a *SampleActivities
+	env.OnActivity(a.SampleActivity1, mock.Anything, mock.Anything).Return("", errors.New("unavailable")).Twice()
+	env.OnActivity(a.SampleActivity2, mock.Anything, mock.Anything).Return("Result_SampleActivity2", nil).Once()
+
+	env.ExecuteWorkflow(SimpleDSLWorkflow, Workflow{
+		Root: Statement{Try: &Try{
+			Body: &Statement{Activity: &ActivityInvocation{
+				Name:        "SampleActivity1",
+				RetryPolicy: &RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 2},
+			}},
+			Finally: &Statement{Activity: &ActivityInvocation{Name: "SampleActivity2"}},
+		}},
+	})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.ErrorContains(t, env.GetWorkflowError(), "unavailable")
+	env.AssertExpectations(t)
+}
+
+func Test_StructuredResults(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(SimpleDSLWorkflow)
+	type item struct {
+		ID   int
+		Tags []string
+	}
+	env.RegisterActivityWithOptions(func(ctx context.Context, input []interface{}) (map[string][]item, error) {
+		return map[string][]item{"items": {{ID: 42, Tags: []string{"a", "b"}}}}, nil
+	}, activity.RegisterOptions{Name: "GetItems"})
+	env.RegisterActivityWithOptions(func(ctx context.Context, input []interface{}) (float64, error) {
+		require.Equal(t, []interface{}{float64(42), "b"}, input)
+		return 3.5, nil
+	}, activity.RegisterOptions{Name: "GetPrice"})
+
+	env.ExecuteWorkflow(SimpleDSLWorkflow, Workflow{
+		Root: Statement{Sequence: &Sequence{Elements: []*Statement{
+			{Activity: &ActivityInvocation{Name: "GetItems", Result: "result1"}},
+			{ChildWorkflow: &ChildWorkflowInvocation{
+				Arguments: []string{"result1"},
+				Root: Statement{Activity: &ActivityInvocation{
+					Name:      "GetPrice",
+					Arguments: []string{"result1.items[0].ID", "result1.items[0].Tags[1]"},
+					Result:    "result2",
+				}},
+				Output: "result2",
+				Result: "childResult",
+			}},
+		}}},
+	})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var output []byte
+	require.NoError(t, env.GetWorkflowResult(&output))
+	var bindings map[string]interface{}
+	require.NoError(t, json.Unmarshal(output, &bindings))
+	require.Equal(t, 3.5, bindings["childResult"])
+	require.Contains(t, bindings, "result1")
+}
+
+func Test_ChildWorkflow_PathArguments(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(func(ctx context.Context, input []interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{"items": []interface{}{map[string]interface{}{"id": "item-1"}}}, nil
+	}, activity.RegisterOptions{Name: "GetItems"})
+	env.RegisterActivityWithOptions(func(ctx context.Context, input []interface{}) (string, error) {
+		require.Equal(t, []interface{}{"item-1", "item-1"}, input)
+		return "priced", nil
+	}, activity.RegisterOptions{Name: "GetPrice"})
+
+	dslWorkflow := Workflow{
+		Root: Statement{Sequence: &Sequence{Elements: []*Statement{
+			{Activity: &ActivityInvocation{Name: "GetItems", Result: "result1"}},
+			{ChildWorkflow: &ChildWorkflowInvocation{
+				Arguments: []string{"result1.items[0].id", "result1.items"},
+				Root: Statement{Activity: &ActivityInvocation{
+					Name:      "GetPrice",
+					Arguments: []string{"id", "items[0].id"},
+					Result:    "result2",
+				}},
+				Output: "result2",
+				Result: "childResult",
+			}},
+		}}},
+		Output: "childResult",
+	}
+	require.Empty(t, Validate(dslWorkflow, []string{"GetItems", "GetPrice"}))
+	env.ExecuteWorkflow(SimpleDSLWorkflow, dslWorkflow)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var output []byte
+	require.NoError(t, env.GetWorkflowResult(&output))
+	require.JSONEq(t, `"priced"`, string(output))
+}
+
+func Test_ResolveReference(t *testing.T) {
+	bindings := map[string]interface{}{
+		"order": map[string]interface{}{"items": []interface{}{map[string]interface{}{"id": "item-1"}}},
+		"name":  "value",
+	}
+	for ref, expected := range map[string]interface{}{
+		"name":              "value",
+		"order.items[0].id": "item-1",
+		"order.missing":     nil,
+		"unbound.field":     nil,
+	} {
+		value, err := resolveReference(ref, bindings)
+		require.NoError(t, err, ref)
+		require.Equal(t, expected, value, ref)
+	}
+	for _, ref := range []string{"name.field", "order.items[1]", "order.items[x]", "order..items", "[0]"} {
+		_, err := resolveReference(ref, bindings)
+		require.Error(t, err, ref)
+	}
+}
+
+func Test_EvaluateCondition_ShortCircuit(t *testing.T) {
+	bindings := map[string]interface{}{"name": "value"}
+	for expression, expected := range map[string]bool{
+		"items != null && items[0].id == 'x'":        false,
+		"items == null || items[0].id == 'x'":        true,
+		"name == 'value' || name.field == 'x'":       true,
+		"!(name != 'value' && name[0] == 'x')":       true,
+		"(items != null && items[0].id == 'x') || 1": true,
+	} {
+		result, err := evaluateCondition(expression, bindings)
+		require.NoError(t, err, expression)
+		require.Equal(t, expected, result, expression)
+	}
+
+	// The evaluated side still fails, and the skipped side is still checked for syntax errors
+	_, err := evaluateCondition("name != null && name.field == 'x'", bindings)
+	require.Error(t, err)
+	_, err = evaluateCondition("items != null && items[0 == 'x'", bindings)
+	require.Error(t, err)
+	_, err = evaluateCondition("a-1 == 0", bindings)
+	require.Error(t, err)
+}
+
+func Test_If_Else(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	var a *SampleActivities
+	env.OnActivity(a.SampleActivity3, mock.Anything, []interface{}{"no"}).Return("Result_SampleActivity3", nil).Once()
+
+	env.ExecuteWorkflow(SimpleDSLWorkflow, Workflow{
+		Variables: map[string]interface{}{"flag": "no"},
+		Root: Statement{If: &If{
+			Condition: "flag == 'yes' || !(flag != 'yes')",
+			Then:      &Statement{Activity: &ActivityInvocation{Name: "SampleActivity2", Arguments: []string{"flag"}}},
+			Else:      &Statement{Activity: &ActivityInvocation{Name: "SampleActivity3", Arguments: []string{"flag"}}},
+		}},
+	})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+}
+
+func Test_While(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	var a *SampleActivities
+	env.OnActivity(a.SampleActivity1, mock.Anything, mock.Anything).Return("pending", nil).Twice()
+	env.OnActivity(a.SampleActivity1, mock.Anything, mock.Anything).Return("done", nil).Once()
+
+	env.ExecuteWorkflow(SimpleDSLWorkflow, Workflow{
+		Root: Statement{While: &While{
+			Condition: "status != 'done'",
+			Body:      &Statement{Activity: &ActivityInvocation{Name: "SampleActivity1", Result: "status"}},
+		}},
+	})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+}
+
+func Test_While_MaxIterations(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	var a *SampleActivities
+	env.OnActivity(a.SampleActivity1, mock.Anything, mock.Anything).Return("pending", nil).Times(3)
+
+	env.ExecuteWorkflow(SimpleDSLWorkflow, Workflow{
+		Root: Statement{While: &While{
+			Condition:     "status != 'done'",
+			Body:          &Statement{Activity: &ActivityInvocation{Name: "SampleActivity1", Result: "status"}},
+			MaxIterations: 3,
+		}},
+	})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.ErrorContains(t, env.GetWorkflowError(), "exceeded the limit of 3 iterations")
+	env.AssertExpectations(t)
+}
+
+func Test_ForEach_MaxIterations(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.ExecuteWorkflow(SimpleDSLWorkflow, Workflow{
+		Root: Statement{ForEach: &ForEach{
+			Items:         []string{"arg1", "arg2"},
+			Item:          "item",
+			Body:          &Statement{Activity: &ActivityInvocation{Name: "SampleActivity1", Arguments: []string{"item"}}},
+			MaxIterations: 1,
+		}},
+	})
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.ErrorContains(t, env.GetWorkflowError(), "exceeds the limit of 1 iterations")
+}
+
+func Test_Workflow6_Approved(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	var a *SampleActivities
+	env.OnActivity(a.SampleActivity1, mock.Anything, mock.Anything).Return("Result_SampleActivity1", nil).Once()
+	env.OnActivity(a.SampleActivity2, mock.Anything, []interface{}{"Result_SampleActivity1"}).Return("Result_SampleActivity2", nil).Once()
+	env.RegisterDelayedCallback(func() {
+		encoded, err := env.QueryWorkflow(StateQueryName)
+		require.NoError(t, err)
+		var state State
+		require.NoError(t, encoded.Get(&state))
+		require.Equal(t, []string{"root.sequence.elements[1]"}, state.Statements)
+		require.Equal(t, "Result_SampleActivity1", state.Bindings["result1"])
+
+		env.SignalWorkflow("approval", "yes")
+	}, time.Minute)
+
+	env.ExecuteWorkflow(SimpleDSLWorkflow, loadWorkflow(t, "workflow6.yaml"))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+}
+
+func Test_Workflow6_Timeout(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	var a *SampleActivities
+	env.OnActivity(a.SampleActivity1, mock.Anything, mock.Anything).Return("Result_SampleActivity1", nil).Once()
+	env.OnActivity(a.SampleActivity3, mock.Anything, []interface{}{"Result_SampleActivity1"}).Return("Result_SampleActivity3", nil).Once()
+
+	env.ExecuteWorkflow(SimpleDSLWorkflow, loadWorkflow(t, "workflow6.yaml"))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var output []byte
+	require.NoError(t, env.GetWorkflowResult(&output))
+	var bindings map[string]interface{}
+	require.NoError(t, json.Unmarshal(output, &bindings))
+	require.Contains(t, bindings, "approval")
+	require.Nil(t, bindings["approval"])
+	env.AssertExpectations(t)
+}
+
+// continuedAsNew returns the input of the next run of a workflow that continued as new.
+func continuedAsNew(t *testing.T, env *testsuite.TestWorkflowEnvironment) Workflow {
+	var canErr *workflow.ContinueAsNewError
+	require.True(t, errors.As(env.GetWorkflowError(), &canErr))
+	var next Workflow
+	require.NoError(t, converter.GetDefaultDataConverter().FromPayloads(canErr.Input, &next))
+	return next
+}
+
+func Test_ContinueAsNew_Sequence(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	env.SetContinueAsNewSuggested(true)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow("approval", "yes")
+	}, time.Second)
+
+	env.ExecuteWorkflow(SimpleDSLWorkflow, Workflow{
+		Output: "approval",
+		Root: Statement{Sequence: &Sequence{Elements: []*Statement{
+			{Sleep: &Sleep{Duration: time.Minute}},
+			{WaitForSignal: &WaitForSignal{Name: "approval", Result: "approval"}},
+		}}},
+	})
+
+	require.True(t, env.IsWorkflowCompleted())
+	next := continuedAsNew(t, env)
+	require.Equal(t, &Checkpoint{
+		Path:           "root.sequence.elements[1]",
+		PendingSignals: map[string][]interface{}{"approval": {"yes"}},
+	}, next.Checkpoint)
+
+	env = testSuite.NewTestWorkflowEnvironment()
+	env.ExecuteWorkflow(SimpleDSLWorkflow, next)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var output []byte
+	require.NoError(t, env.GetWorkflowResult(&output))
+	require.JSONEq(t, `"yes"`, string(output))
+}
+
+func Test_ContinueAsNew_ForEach(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	env.SetContinueAsNewSuggested(true)
+	var a *SampleActivities
+	env.OnActivity(a.SampleActivity1, mock.Anything, []interface{}{"a"}).Return("Result_a", nil).Once()
+	dslWorkflow := Workflow{
+		Variables: map[string]interface{}{"items": []interface{}{"a", "b", "c"}},
+		Root: Statement{ForEach: &ForEach{
+			In:   "items",
+			Item: "item",
+			Body: &Statement{If: &If{
+				Condition: "item != 'x'",
+				Then: &Statement{Activity: &ActivityInvocation{
+					Name:      "SampleActivity1",
+					Arguments: []string{"item"},
+					Result:    "result",
+				}},
+			}},
+		}},
+	}
+
+	env.ExecuteWorkflow(SimpleDSLWorkflow, dslWorkflow)
+
+	require.True(t, env.IsWorkflowCompleted())
+	env.AssertExpectations(t)
+	next := continuedAsNew(t, env)
+	require.Equal(t, "root.forEach.body[1]", next.Checkpoint.Path)
+	require.Equal(t, "Result_a", next.Variables["result"])
+
+	env = testSuite.NewTestWorkflowEnvironment()
+	env.OnActivity(a.SampleActivity1, mock.Anything, []interface{}{"b"}).Return("Result_b", nil).Once()
+	env.OnActivity(a.SampleActivity1, mock.Anything, []interface{}{"c"}).Return("Result_c", nil).Once()
+	env.ExecuteWorkflow(SimpleDSLWorkflow, next)
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	env.AssertExpectations(t)
+}
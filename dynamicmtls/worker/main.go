@@ -6,7 +6,7 @@ import (
 
 	"github.com/temporalio/samples-go/dynamicmtls"
 	"github.com/temporalio/samples-go/helloworld"
-	"go.temporal.io/sdk/client"
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 	"go.temporal.io/sdk/worker"
 )
 
@@ -16,10 +16,11 @@ func main() {
 	if err != nil {
 		log.Fatalf("Invalid arguments: %v", err)
 	}
-	c, err := client.Dial(clientOptions)
+	c, shutdown, err := bootstrap.Dial(clientOptions)
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
 	w := worker.New(c, "hello-world-mtls", worker.Options{})
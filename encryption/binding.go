@@ -0,0 +1,79 @@
+package encryption
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"go.temporal.io/sdk/activity"
+)
+
+// associatedDataVersion is the value of the MetadataEncryptionAssociatedData metadata, it versions the format of
+// associatedData.
+const associatedDataVersion = "v1"
+
+// Binding identifies the workflow a payload belongs to. With DataConverterOptions.BindToWorkflow the namespace and
+// workflow ID are stored in the payload metadata and, together with the rest of the metadata, authenticated as AES-GCM
+// associated data. A payload copied into another workflow or namespace, or whose metadata was changed, then fails to
+// decode.
+//
+// The binding is taken from the workflow info in WithWorkflowContext and from the activity info in WithContext. Clients
+// set it with NewBindingContext, for example when starting or signalling a workflow. Payloads decoded without a
+// binding, such as by a codec server or workflow results received by a client, are still authenticated but may belong
+// to any workflow. Note that payloads are bound to the workflow encoding them, so workflows exchanging payloads with
+// other workflows, such as child workflows, can't use this option.
+type Binding struct {
+	Namespace  string
+	WorkflowID string
+}
+
+type bindingContextKey struct{}
+
+// NewBindingContext returns a context that binds the payloads encoded with it to the given workflow.
+func NewBindingContext(ctx context.Context, namespace, workflowID string) context.Context {
+	return context.WithValue(ctx, bindingContextKey{}, Binding{Namespace: namespace, WorkflowID: workflowID})
+}
+
+func bindingFromContext(ctx context.Context) *Binding {
+	if activity.IsActivity(ctx) {
+		info := activity.GetInfo(ctx)
+		return &Binding{Namespace: info.WorkflowNamespace, WorkflowID: info.WorkflowExecution.ID}
+	}
+	if binding, ok := ctx.Value(bindingContextKey{}).(Binding); ok {
+		return &binding
+	}
+	return nil
+}
+
+// check returns an error when the payload metadata binds the payload to another workflow. Payloads encoded without a
+// binding are accepted.
+func (b *Binding) check(metadata map[string][]byte) error {
+	namespace, ok := metadata[MetadataEncryptionNamespace]
+	if b == nil || !ok {
+		return nil
+	}
+	workflowID := metadata[MetadataEncryptionWorkflowID]
+	if string(namespace) != b.Namespace || string(workflowID) != b.WorkflowID {
+		return fmt.Errorf("payload is bound to workflow %q in namespace %q", workflowID, namespace)
+	}
+	return nil
+}
+
+// associatedData serializes the payload metadata, sorted by key and length prefixed so that it's unambiguous.
+func associatedData(metadata map[string][]byte) []byte {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var ad []byte
+	for _, key := range keys {
+		ad = binary.BigEndian.AppendUint32(ad, uint32(len(key)))
+		ad = append(ad, key...)
+		ad = binary.BigEndian.AppendUint32(ad, uint32(len(metadata[key])))
+		ad = append(ad, metadata[key]...)
+	}
+	return ad
+}
@@ -7,26 +7,36 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"syscall"
 
 	"github.com/temporalio/samples-go/encryption"
 
 	"go.temporal.io/sdk/converter"
 )
 
-var portFlag int
+var (
+	portFlag    int
+	keyringFlag string
+)
 
 func init() {
 	flag.IntVar(&portFlag, "port", 8081, "Port to listen on")
+	flag.StringVar(&keyringFlag, "keyring", "keyring.json", "Path of the keyring file, reloaded on SIGHUP")
 }
 
 func main() {
 	flag.Parse()
 
+	keyring, err := encryption.LoadKeyring(keyringFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// This example codec server does not support varying config per namespace,
 	// decoding for the Temporal Web UI or oauth.
 	// For a more complete example of a codec server please see the codec-server sample at:
 	// ../../codec-server.
-	handler := converter.NewPayloadCodecHTTPHandler(&encryption.Codec{}, converter.NewZlibCodec(converter.ZlibCodecOptions{AlwaysEncode: true}))
+	handler := converter.NewPayloadCodecHTTPHandler(&encryption.Codec{KeyProvider: keyring}, converter.NewZlibCodec(converter.ZlibCodecOptions{AlwaysEncode: true}))
 
 	srv := &http.Server{
 		Addr:    "0.0.0.0:" + strconv.Itoa(portFlag),
@@ -37,12 +47,22 @@ func main() {
 	go func() { errCh <- srv.ListenAndServe() }()
 
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, os.Interrupt)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGHUP)
 
-	select {
-	case <-sigCh:
-		_ = srv.Close()
-	case err := <-errCh:
-		log.Fatal(err)
+	for {
+		select {
+		case sig := <-sigCh:
+			if sig == syscall.SIGHUP {
+				// Pick up rotated keys without restarting.
+				if err := keyring.Reload(keyringFlag); err != nil {
+					log.Println("Unable to reload keyring", err)
+				}
+				continue
+			}
+			_ = srv.Close()
+			return
+		case err := <-errCh:
+			log.Fatal(err)
+		}
 	}
 }
@@ -8,7 +8,18 @@ import (
 	"io"
 )
 
-func encrypt(plainData []byte, key []byte) ([]byte, error) {
+// dataKeySize is the size of the data keys, which selects AES-256.
+const dataKeySize = 32
+
+func newDataKey() ([]byte, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func encrypt(plainData []byte, key []byte, associatedData []byte) ([]byte, error) {
 	c, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -24,10 +35,10 @@ func encrypt(plainData []byte, key []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	return gcm.Seal(nonce, nonce, plainData, nil), nil
+	return gcm.Seal(nonce, nonce, plainData, associatedData), nil
 }
 
-func decrypt(encryptedData []byte, key []byte) ([]byte, error) {
+func decrypt(encryptedData []byte, key []byte, associatedData []byte) ([]byte, error) {
 	c, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -44,5 +55,5 @@ func decrypt(encryptedData []byte, key []byte) ([]byte, error) {
 	}
 
 	nonce, encryptedData := encryptedData[:nonceSize], encryptedData[nonceSize:]
-	return gcm.Open(nil, nonce, encryptedData, nil)
+	return gcm.Open(nil, nonce, encryptedData, associatedData)
 }
@@ -16,6 +16,18 @@ const (
 
 	// MetadataEncryptionKeyID is "encryption-key-id"
 	MetadataEncryptionKeyID = "encryption-key-id"
+
+	// MetadataEncryptionDataKey is "encryption-data-key"
+	MetadataEncryptionDataKey = "encryption-data-key"
+
+	// MetadataEncryptionAssociatedData is "encryption-associated-data"
+	MetadataEncryptionAssociatedData = "encryption-associated-data"
+
+	// MetadataEncryptionNamespace is "encryption-namespace"
+	MetadataEncryptionNamespace = "encryption-namespace"
+
+	// MetadataEncryptionWorkflowID is "encryption-workflow-id"
+	MetadataEncryptionWorkflowID = "encryption-workflow-id"
 )
 
 type DataConverter struct {
@@ -26,62 +38,92 @@ type DataConverter struct {
 }
 
 type DataConverterOptions struct {
+	// KeyID pins the key used to encrypt payloads. Defaults to the active key of KeyProvider.
 	KeyID string
+	// KeyProvider supplies the keys, required.
+	KeyProvider KeyProvider
 	// Enable ZLib compression before encryption.
 	Compress bool
+	// BindToWorkflow binds payloads to the namespace and workflow ID they are encoded for, see Binding.
+	BindToWorkflow bool
+
+	binding *Binding
 }
 
-// Codec implements PayloadCodec using AES Crypt.
+// Codec implements PayloadCodec using AES Crypt with envelope encryption: each payload is encrypted with a random data
+// key which is stored next to the payload, encrypted with the key from KeyProvider.
 type Codec struct {
-	KeyID string
+	// KeyID pins the key used to encrypt payloads. Defaults to the active key of KeyProvider.
+	KeyID       string
+	KeyProvider KeyProvider
+	// BindToWorkflow authenticates the payload metadata and Binding as AES-GCM associated data.
+	BindToWorkflow bool
+	// Binding is the workflow the payloads are encoded for. When set, bound payloads of other workflows fail to
+	// decode.
+	Binding *Binding
 }
 
 // TODO: Implement workflow.ContextAware in CodecDataConverter
-// Note that you only need to implement this function if you need to vary the encryption KeyID per workflow.
+// Note that you only need to implement this function if you need to vary the encryption KeyID per workflow
+// or bind payloads to workflows.
 func (dc *DataConverter) WithWorkflowContext(ctx workflow.Context) converter.DataConverter {
-	if val, ok := ctx.Value(PropagateKey).(CryptContext); ok {
-		parent := dc.parent
-		if parentWithContext, ok := parent.(workflow.ContextAware); ok {
-			parent = parentWithContext.WithWorkflowContext(ctx)
-		}
+	val, ok := ctx.Value(PropagateKey).(CryptContext)
+	if !ok && !dc.options.BindToWorkflow {
+		return dc
+	}
 
-		options := dc.options
-		options.KeyID = val.KeyID
+	parent := dc.parent
+	if parentWithContext, ok := parent.(workflow.ContextAware); ok {
+		parent = parentWithContext.WithWorkflowContext(ctx)
+	}
 
-		return NewEncryptionDataConverter(parent, options)
+	options := dc.options
+	if ok {
+		options.KeyID = val.KeyID
+	}
+	if options.BindToWorkflow {
+		info := workflow.GetInfo(ctx)
+		options.binding = &Binding{Namespace: info.Namespace, WorkflowID: info.WorkflowExecution.ID}
 	}
 
-	return dc
+	return NewEncryptionDataConverter(parent, options)
 }
 
 // TODO: Implement workflow.ContextAware in EncodingDataConverter
-// Note that you only need to implement this function if you need to vary the encryption KeyID per workflow.
+// Note that you only need to implement this function if you need to vary the encryption KeyID per workflow
+// or bind payloads to workflows.
 func (dc *DataConverter) WithContext(ctx context.Context) converter.DataConverter {
-	if val, ok := ctx.Value(PropagateKey).(CryptContext); ok {
-		parent := dc.parent
-		if parentWithContext, ok := parent.(workflow.ContextAware); ok {
-			parent = parentWithContext.WithContext(ctx)
-		}
-
-		options := dc.options
-		options.KeyID = val.KeyID
+	val, ok := ctx.Value(PropagateKey).(CryptContext)
+	binding := bindingFromContext(ctx)
+	if !ok && (!dc.options.BindToWorkflow || binding == nil) {
+		return dc
+	}
 
-		return NewEncryptionDataConverter(parent, options)
+	parent := dc.parent
+	if parentWithContext, ok := parent.(workflow.ContextAware); ok {
+		parent = parentWithContext.WithContext(ctx)
 	}
 
-	return dc
-}
+	options := dc.options
+	if ok {
+		options.KeyID = val.KeyID
+	}
+	if options.BindToWorkflow {
+		options.binding = binding
+	}
 
-func (e *Codec) getKey(keyID string) (key []byte) {
-	// Key must be fetched from secure storage in production (such as a KMS).
-	// For testing here we just hard code a key.
-	return []byte("test-key-test-key-test-key-test!")
+	return NewEncryptionDataConverter(parent, options)
 }
 
 // NewEncryptionDataConverter creates a new instance of EncryptionDataConverter wrapping a DataConverter
 func NewEncryptionDataConverter(dataConverter converter.DataConverter, options DataConverterOptions) *DataConverter {
 	codecs := []converter.PayloadCodec{
-		&Codec{KeyID: options.KeyID},
+		&Codec{
+			KeyID:          options.KeyID,
+			KeyProvider:    options.KeyProvider,
+			BindToWorkflow: options.BindToWorkflow,
+			Binding:        options.binding,
+		},
 	}
 	// Enable compression if requested.
 	// Note that this must be done before encryption to provide any value. Encrypted data should by design not compress very well.
@@ -99,6 +141,22 @@ func NewEncryptionDataConverter(dataConverter converter.DataConverter, options D
 
 // Encode implements converter.PayloadCodec.Encode.
 func (e *Codec) Encode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	if e.KeyProvider == nil {
+		return payloads, fmt.Errorf("no key provider")
+	}
+	keyID := e.KeyID
+	if keyID == "" {
+		var err error
+		if keyID, err = e.KeyProvider.ActiveKeyID(); err != nil {
+			return payloads, err
+		}
+	}
+	// Key must be stored in secure storage in production (such as a KMS).
+	key, err := e.KeyProvider.Key(keyID)
+	if err != nil {
+		return payloads, err
+	}
+
 	result := make([]*commonpb.Payload, len(payloads))
 	for i, p := range payloads {
 		origBytes, err := p.Marshal()
@@ -106,19 +164,39 @@ func (e *Codec) Encode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error
 			return payloads, err
 		}
 
-		key := e.getKey(e.KeyID)
+		dataKey, err := newDataKey()
+		if err != nil {
+			return payloads, err
+		}
 
-		b, err := encrypt(origBytes, key)
+		wrappedKey, err := encrypt(dataKey, key, nil)
+		if err != nil {
+			return payloads, err
+		}
+
+		metadata := map[string][]byte{
+			converter.MetadataEncoding: []byte(MetadataEncodingEncrypted),
+			MetadataEncryptionKeyID:    []byte(keyID),
+			MetadataEncryptionDataKey:  wrappedKey,
+		}
+		var ad []byte
+		if e.BindToWorkflow {
+			metadata[MetadataEncryptionAssociatedData] = []byte(associatedDataVersion)
+			if e.Binding != nil {
+				metadata[MetadataEncryptionNamespace] = []byte(e.Binding.Namespace)
+				metadata[MetadataEncryptionWorkflowID] = []byte(e.Binding.WorkflowID)
+			}
+			ad = associatedData(metadata)
+		}
+
+		b, err := encrypt(origBytes, dataKey, ad)
 		if err != nil {
 			return payloads, err
 		}
 
 		result[i] = &commonpb.Payload{
-			Metadata: map[string][]byte{
-				converter.MetadataEncoding: []byte(MetadataEncodingEncrypted),
-				MetadataEncryptionKeyID:    []byte(e.KeyID),
-			},
-			Data: b,
+			Metadata: metadata,
+			Data:     b,
 		}
 	}
 
@@ -135,14 +213,41 @@ func (e *Codec) Decode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error
 			continue
 		}
 
+		if e.KeyProvider == nil {
+			return payloads, fmt.Errorf("no key provider")
+		}
+
 		keyID, ok := p.Metadata[MetadataEncryptionKeyID]
 		if !ok {
 			return payloads, fmt.Errorf("no encryption key id")
 		}
 
-		key := e.getKey(string(keyID))
+		key, err := e.KeyProvider.Key(string(keyID))
+		if err != nil {
+			return payloads, err
+		}
+
+		// Payloads encrypted before envelope encryption was introduced have no data key and are encrypted with the
+		// key itself.
+		if wrappedKey, ok := p.Metadata[MetadataEncryptionDataKey]; ok {
+			if key, err = decrypt(wrappedKey, key, nil); err != nil {
+				return payloads, fmt.Errorf("failed decrypting data key: %w", err)
+			}
+		}
 
-		b, err := decrypt(p.Data, key)
+		// Removing the associated data marker doesn't help to tamper with a payload, it then fails to decrypt.
+		var ad []byte
+		if version, ok := p.Metadata[MetadataEncryptionAssociatedData]; ok {
+			if string(version) != associatedDataVersion {
+				return payloads, fmt.Errorf("unsupported associated data version %q", version)
+			}
+			if err := e.Binding.check(p.Metadata); err != nil {
+				return payloads, err
+			}
+			ad = associatedData(p.Metadata)
+		}
+
+		b, err := decrypt(p.Data, key, ad)
 		if err != nil {
 			return payloads, err
 		}
@@ -156,3 +261,48 @@ func (e *Codec) Decode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error
 
 	return result, nil
 }
+
+// Reencrypt re-encrypts the encrypted payloads that are not encrypted with the key Encode uses, which is the active key
+// unless KeyID is set. Other payloads are returned unchanged. This is used to retire a key after rotation.
+func (e *Codec) Reencrypt(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	if e.KeyProvider == nil {
+		return payloads, fmt.Errorf("no key provider")
+	}
+	keyID := e.KeyID
+	if keyID == "" {
+		var err error
+		if keyID, err = e.KeyProvider.ActiveKeyID(); err != nil {
+			return payloads, err
+		}
+	}
+
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, p := range payloads {
+		_, hasDataKey := p.Metadata[MetadataEncryptionDataKey]
+		if string(p.Metadata[converter.MetadataEncoding]) != MetadataEncodingEncrypted ||
+			(string(p.Metadata[MetadataEncryptionKeyID]) == keyID && hasDataKey) {
+			result[i] = p
+			continue
+		}
+
+		// Keep the associated data and binding of the payload.
+		codec := *e
+		_, codec.BindToWorkflow = p.Metadata[MetadataEncryptionAssociatedData]
+		codec.Binding = nil
+		if namespace, ok := p.Metadata[MetadataEncryptionNamespace]; ok {
+			codec.Binding = &Binding{Namespace: string(namespace), WorkflowID: string(p.Metadata[MetadataEncryptionWorkflowID])}
+		}
+
+		decoded, err := codec.Decode([]*commonpb.Payload{p})
+		if err != nil {
+			return payloads, err
+		}
+		encoded, err := codec.Encode(decoded)
+		if err != nil {
+			return payloads, err
+		}
+		result[i] = encoded[0]
+	}
+
+	return result, nil
+}
@@ -5,9 +5,22 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
 	"go.temporal.io/sdk/converter"
 )
 
+func newTestKeyring(t *testing.T, activeKeyID string, keyIDs ...string) *Keyring {
+	keys := make(map[string][]byte)
+	for _, keyID := range keyIDs {
+		key, err := newDataKey()
+		require.NoError(t, err)
+		keys[keyID] = key
+	}
+	keyring, err := NewKeyring(activeKeyID, keys)
+	require.NoError(t, err)
+	return keyring
+}
+
 func Test_DataConverter(t *testing.T) {
 	defaultDc := converter.GetDefaultDataConverter()
 
@@ -16,7 +29,7 @@ func Test_DataConverter(t *testing.T) {
 
 	cryptDc := NewEncryptionDataConverter(
 		converter.GetDefaultDataConverter(),
-		DataConverterOptions{},
+		DataConverterOptions{KeyProvider: newTestKeyring(t, "test", "test")},
 	)
 	cryptDcWc := cryptDc.WithContext(ctx)
 
@@ -34,3 +47,120 @@ func Test_DataConverter(t *testing.T) {
 
 	require.Equal(t, "Testing", result)
 }
+
+func Test_Codec_KeyRotation(t *testing.T) {
+	keyring := newTestKeyring(t, "key-1", "key-1")
+	codec := &Codec{KeyProvider: keyring}
+
+	payload, err := converter.GetDefaultDataConverter().ToPayload("Testing")
+	require.NoError(t, err)
+	oldPayloads, err := codec.Encode([]*commonpb.Payload{payload})
+	require.NoError(t, err)
+	require.Equal(t, "key-1", string(oldPayloads[0].Metadata[MetadataEncryptionKeyID]))
+	require.NotEmpty(t, oldPayloads[0].Metadata[MetadataEncryptionDataKey])
+
+	// Rotate: add key-2 and make it the active key.
+	key1, err := keyring.Key("key-1")
+	require.NoError(t, err)
+	key2, err := newDataKey()
+	require.NoError(t, err)
+	require.NoError(t, keyring.set("key-2", map[string][]byte{"key-1": key1, "key-2": key2}))
+
+	newPayloads, err := codec.Encode([]*commonpb.Payload{payload})
+	require.NoError(t, err)
+	require.Equal(t, "key-2", string(newPayloads[0].Metadata[MetadataEncryptionKeyID]))
+
+	// Payloads of both keys decrypt.
+	decoded, err := codec.Decode(append(oldPayloads, newPayloads...))
+	require.NoError(t, err)
+	for _, p := range decoded {
+		var result string
+		require.NoError(t, converter.GetDefaultDataConverter().FromPayload(p, &result))
+		require.Equal(t, "Testing", result)
+	}
+
+	// Re-encryption moves old payloads to the active key and leaves the others unchanged.
+	reencrypted, err := codec.Reencrypt(append(oldPayloads, newPayloads[0], payload))
+	require.NoError(t, err)
+	require.Equal(t, "key-2", string(reencrypted[0].Metadata[MetadataEncryptionKeyID]))
+	require.Same(t, newPayloads[0], reencrypted[1])
+	require.Same(t, payload, reencrypted[2])
+
+	// After retiring key-1 the re-encrypted payload still decrypts.
+	require.NoError(t, keyring.set("key-2", map[string][]byte{"key-2": key2}))
+	_, err = codec.Decode(oldPayloads)
+	require.Error(t, err)
+	decoded, err = codec.Decode(reencrypted[:1])
+	require.NoError(t, err)
+	var result string
+	require.NoError(t, converter.GetDefaultDataConverter().FromPayload(decoded[0], &result))
+	require.Equal(t, "Testing", result)
+}
+
+func Test_LoadKeyring(t *testing.T) {
+	keyring, err := LoadKeyring("keyring.json")
+	require.NoError(t, err)
+	activeKeyID, err := keyring.ActiveKeyID()
+	require.NoError(t, err)
+	require.Equal(t, "key-2", activeKeyID)
+	_, err = keyring.Key("key-1")
+	require.NoError(t, err)
+	_, err = keyring.Key("key-3")
+	require.Error(t, err)
+
+	_, err = NewKeyring("key-1", map[string][]byte{"key-1": []byte("short")})
+	require.Error(t, err)
+	_, err = NewKeyring("key-2", map[string][]byte{"key-1": make([]byte, 32)})
+	require.Error(t, err)
+}
+
+func Test_Codec_Binding(t *testing.T) {
+	keyring := newTestKeyring(t, "key-1", "key-1")
+	binding := &Binding{Namespace: "default", WorkflowID: "workflow-1"}
+	codec := &Codec{KeyProvider: keyring, BindToWorkflow: true, Binding: binding}
+
+	payload, err := converter.GetDefaultDataConverter().ToPayload("Testing")
+	require.NoError(t, err)
+	encoded, err := codec.Encode([]*commonpb.Payload{payload})
+	require.NoError(t, err)
+	require.Equal(t, "workflow-1", string(encoded[0].Metadata[MetadataEncryptionWorkflowID]))
+
+	// The same workflow and decoders without a binding decode the payload.
+	_, err = codec.Decode(encoded)
+	require.NoError(t, err)
+	_, err = (&Codec{KeyProvider: keyring}).Decode(encoded)
+	require.NoError(t, err)
+
+	// Another workflow rejects it.
+	other := &Codec{KeyProvider: keyring, BindToWorkflow: true, Binding: &Binding{Namespace: "default", WorkflowID: "workflow-2"}}
+	_, err = other.Decode(encoded)
+	require.ErrorContains(t, err, `payload is bound to workflow "workflow-1" in namespace "default"`)
+
+	// Tampering with the binding or removing the associated data marker fails authentication.
+	tampered := &commonpb.Payload{Metadata: make(map[string][]byte), Data: encoded[0].Data}
+	for key, value := range encoded[0].Metadata {
+		tampered.Metadata[key] = value
+	}
+	tampered.Metadata[MetadataEncryptionWorkflowID] = []byte("workflow-2")
+	_, err = other.Decode([]*commonpb.Payload{tampered})
+	require.Error(t, err)
+	delete(tampered.Metadata, MetadataEncryptionAssociatedData)
+	_, err = other.Decode([]*commonpb.Payload{tampered})
+	require.Error(t, err)
+
+	// Re-encryption keeps the binding.
+	require.NoError(t, keyring.set("key-2", map[string][]byte{"key-1": mustKey(t, keyring, "key-1"), "key-2": make([]byte, 32)}))
+	reencrypted, err := (&Codec{KeyProvider: keyring}).Reencrypt(encoded)
+	require.NoError(t, err)
+	require.Equal(t, "key-2", string(reencrypted[0].Metadata[MetadataEncryptionKeyID]))
+	_, err = codec.Decode(reencrypted)
+	require.NoError(t, err)
+	_, err = other.Decode(reencrypted)
+	require.Error(t, err)
+}
+
+func mustKey(t *testing.T, keyring *Keyring, keyID string) []byte {
+	key, err := keyring.Key(keyID)
+	require.NoError(t, err)
+	return key
+}
@@ -0,0 +1,126 @@
+package encryption
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// KeyProvider supplies the key-encryption keys used by Codec. Every payload is encrypted with its own data key, which is
+// wrapped with the key-encryption key named by the encryption-key-id metadata. Keys are rotated by changing the active
+// key ID: new payloads are encrypted with the active key while payloads encrypted with previous keys still decrypt as
+// long as their keys are provided.
+type KeyProvider interface {
+	// ActiveKeyID returns the ID of the key that encrypts new payloads.
+	ActiveKeyID() (string, error)
+	// Key returns the key with the given ID, it must be 16, 24 or 32 bytes long.
+	Key(keyID string) ([]byte, error)
+}
+
+// Keyring is a KeyProvider holding a fixed set of versioned keys.
+type Keyring struct {
+	mu       sync.RWMutex
+	activeID string
+	keys     map[string][]byte
+}
+
+// KeyringFile is the JSON representation of a Keyring. Keys are base64 encoded, for example:
+//
+//	{
+//	  "activeKeyId": "key-2",
+//	  "keys": [
+//	    {"id": "key-1", "key": "<base64 encoded 32 byte key>"},
+//	    {"id": "key-2", "key": "<base64 encoded 32 byte key>"}
+//	  ]
+//	}
+type KeyringFile struct {
+	ActiveKeyID string       `json:"activeKeyId"`
+	Keys        []KeyringKey `json:"keys"`
+}
+
+// KeyringKey is a single key of a KeyringFile.
+type KeyringKey struct {
+	ID  string `json:"id"`
+	Key []byte `json:"key"`
+}
+
+// NewKeyring creates a Keyring from the given keys, activeKeyID must be one of them.
+func NewKeyring(activeKeyID string, keys map[string][]byte) (*Keyring, error) {
+	k := &Keyring{}
+	if err := k.set(activeKeyID, keys); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// LoadKeyring creates a Keyring from a KeyringFile.
+func LoadKeyring(path string) (*Keyring, error) {
+	k := &Keyring{}
+	if err := k.Reload(path); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Reload replaces the keys of the Keyring with the keys of a KeyringFile. This allows to rotate keys without
+// restarting. On error the current keys are kept.
+func (k *Keyring) Reload(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed reading keyring: %w", err)
+	}
+	var file KeyringFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed parsing keyring %s: %w", path, err)
+	}
+	keys := make(map[string][]byte, len(file.Keys))
+	for _, key := range file.Keys {
+		if _, ok := keys[key.ID]; ok {
+			return fmt.Errorf("keyring %s: duplicate key %q", path, key.ID)
+		}
+		keys[key.ID] = key.Key
+	}
+	if err := k.set(file.ActiveKeyID, keys); err != nil {
+		return fmt.Errorf("keyring %s: %w", path, err)
+	}
+	return nil
+}
+
+func (k *Keyring) set(activeKeyID string, keys map[string][]byte) error {
+	for id, key := range keys {
+		if id == "" {
+			return fmt.Errorf("key without id")
+		}
+		if n := len(key); n != 16 && n != 24 && n != 32 {
+			return fmt.Errorf("key %q must be 16, 24 or 32 bytes long, got %d", id, n)
+		}
+	}
+	if _, ok := keys[activeKeyID]; !ok {
+		return fmt.Errorf("active key %q not found", activeKeyID)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.activeID = activeKeyID
+	k.keys = keys
+	return nil
+}
+
+// ActiveKeyID implements KeyProvider.ActiveKeyID.
+func (k *Keyring) ActiveKeyID() (string, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.activeID, nil
+}
+
+// Key implements KeyProvider.Key.
+func (k *Keyring) Key(keyID string) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key %q", keyID)
+	}
+	return key, nil
+}
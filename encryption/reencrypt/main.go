@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/temporalio/samples-go/encryption"
+)
+
+func main() {
+	var keyringPath, keyID, inPath, outPath string
+	flag.StringVar(&keyringPath, "keyring", "keyring.json", "Path of the keyring file")
+	flag.StringVar(&keyID, "key-id", "", "ID of the key to re-encrypt with, defaults to the active key of the keyring")
+	flag.StringVar(&inPath, "in", "", "Path of the payloads to re-encrypt, defaults to stdin")
+	flag.StringVar(&outPath, "out", "", "Path to write the re-encrypted payloads to, defaults to stdout")
+	flag.Parse()
+
+	// The payloads are read and written in the JSON format used by codec servers: {"payloads": [...]}.
+	// Payloads not encrypted by encryption.Codec, or already encrypted with the key, are written unchanged.
+	keyring, err := encryption.LoadKeyring(keyringPath)
+	if err != nil {
+		log.Fatalln("Unable to load keyring", err)
+	}
+
+	in := io.Reader(os.Stdin)
+	if inPath != "" {
+		f, err := os.Open(inPath)
+		if err != nil {
+			log.Fatalln("Unable to open payloads", err)
+		}
+		defer func() { _ = f.Close() }()
+		in = f
+	}
+	data, err := io.ReadAll(in)
+	if err != nil {
+		log.Fatalln("Unable to read payloads", err)
+	}
+	var payloads commonpb.Payloads
+	if err := protojson.Unmarshal(data, &payloads); err != nil {
+		log.Fatalln("Unable to parse payloads", err)
+	}
+
+	codec := &encryption.Codec{KeyID: keyID, KeyProvider: keyring}
+	result, err := codec.Reencrypt(payloads.Payloads)
+	if err != nil {
+		log.Fatalln("Unable to re-encrypt payloads", err)
+	}
+	reencrypted := 0
+	for i := range result {
+		if result[i] != payloads.Payloads[i] {
+			reencrypted++
+		}
+	}
+
+	data, err = protojson.Marshal(&commonpb.Payloads{Payloads: result})
+	if err != nil {
+		log.Fatalln("Unable to serialize payloads", err)
+	}
+	if outPath == "" {
+		_, err = os.Stdout.Write(append(data, '\n'))
+	} else {
+		err = os.WriteFile(outPath, data, 0644)
+	}
+	if err != nil {
+		log.Fatalln("Unable to write payloads", err)
+	}
+	log.Printf("Re-encrypted %d of %d payloads", reencrypted, len(result))
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 
 	"go.temporal.io/sdk/client"
@@ -11,7 +12,23 @@ import (
 	"github.com/temporalio/samples-go/encryption"
 )
 
+var keyringFlag string
+
+func init() {
+	flag.StringVar(&keyringFlag, "keyring", "keyring.json", "Path of the keyring file")
+}
+
 func main() {
+	flag.Parse()
+
+	// The keyring holds the encryption keys, see encryption.KeyringFile for its format.
+	// Keys are rotated by adding a new key to the file and making it the active key.
+	// Keep the previous keys as long as payloads encrypted with them need to be decoded.
+	keyring, err := encryption.LoadKeyring(keyringFlag)
+	if err != nil {
+		log.Fatalln("Unable to load keyring", err)
+	}
+
 	// The client is a heavyweight object that should be created once per process.
 	c, err := client.Dial(client.Options{
 		// If you intend to let the dataConverter to decide encryption key for all workflows
@@ -19,7 +36,7 @@ func main() {
 		//
 		//   DataConverter: encryption.NewEncryptionDataConverter(
 		// 	  converter.GetDefaultDataConverter(),
-		// 	  encryption.DataConverterOptions{KeyID: "test", Compress: true},
+		// 	  encryption.DataConverterOptions{KeyID: "key-2", KeyProvider: keyring, Compress: true},
 		//   ),
 		//
 		// In this case you do not need to use a ContextPropagator.
@@ -35,7 +52,8 @@ func main() {
 		// encrypted/decrypted as required.
 		DataConverter: encryption.NewEncryptionDataConverter(
 			converter.GetDefaultDataConverter(),
-			encryption.DataConverterOptions{Compress: true},
+			// BindToWorkflow rejects payloads copied from other workflows or namespaces.
+			encryption.DataConverterOptions{KeyProvider: keyring, Compress: true, BindToWorkflow: true},
 		),
 		// Use a ContextPropagator so that the KeyID value set in the workflow context is
 		// also availble in the context for activities.
@@ -53,8 +71,10 @@ func main() {
 
 	ctx := context.Background()
 	// If you are using a ContextPropagator and varying keys per workflow you need to set
-	// the KeyID to use for this workflow in the context:
-	ctx = context.WithValue(ctx, encryption.PropagateKey, encryption.CryptContext{KeyID: "test"})
+	// the KeyID to use for this workflow in the context. Without it the active key of the keyring is used:
+	ctx = context.WithValue(ctx, encryption.PropagateKey, encryption.CryptContext{KeyID: "key-2"})
+	// Bind the workflow input to the workflow it is started for.
+	ctx = encryption.NewBindingContext(ctx, client.DefaultNamespace, workflowOptions.ID)
 
 	// The workflow input "My Secret Friend" will be encrypted by the DataConverter before being sent to Temporal
 	we, err := c.ExecuteWorkflow(
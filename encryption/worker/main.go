@@ -1,24 +1,42 @@
 package main
 
 import (
+	"flag"
 	"log"
 
 	"github.com/temporalio/samples-go/encryption"
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/converter"
 	"go.temporal.io/sdk/worker"
 	"go.temporal.io/sdk/workflow"
 )
 
+var keyringFlag string
+
+func init() {
+	flag.StringVar(&keyringFlag, "keyring", "keyring.json", "Path of the keyring file")
+}
+
 func main() {
+	flag.Parse()
+
+	// The keyring holds the encryption keys, see encryption.KeyringFile for its format.
+	// Keys are rotated by adding a new key to the file and making it the active key.
+	// Keep the previous keys as long as payloads encrypted with them need to be decoded.
+	keyring, err := encryption.LoadKeyring(keyringFlag)
+	if err != nil {
+		log.Fatalln("Unable to load keyring", err)
+	}
+
 	// The client and worker are heavyweight objects that should be created once per process.
-	c, err := client.Dial(client.Options{
+	c, shutdown, err := bootstrap.Dial(client.Options{
 		// If you intend to let the dataConverter to decide encryption key for all workflows
 		// you can set the KeyID for the encryption encoder like so:
 		//
 		//   DataConverter: encryption.NewEncryptionDataConverter(
 		// 	  converter.GetDefaultDataConverter(),
-		// 	  encryption.DataConverterOptions{KeyID: "test", Compress: true},
+		// 	  encryption.DataConverterOptions{KeyID: "key-2", KeyProvider: keyring, Compress: true},
 		//   ),
 		//
 		// In this case you do not need to use a ContextPropagator.
@@ -34,7 +52,8 @@ func main() {
 		// encrypted/decrypted as required.
 		DataConverter: encryption.NewEncryptionDataConverter(
 			converter.GetDefaultDataConverter(),
-			encryption.DataConverterOptions{Compress: true},
+			// BindToWorkflow rejects payloads copied from other workflows or namespaces.
+			encryption.DataConverterOptions{KeyProvider: keyring, Compress: true, BindToWorkflow: true},
 		),
 		// Use a ContextPropagator so that the KeyID value set in the workflow context is
 		// also availble in the context for activities.
@@ -43,6 +62,7 @@ func main() {
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
 	w := worker.New(c, "encryption", worker.Options{})
@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/converter"
 	"go.temporal.io/sdk/testsuite"
 )
 
@@ -23,3 +24,21 @@ func Test_Workflow(t *testing.T) {
 	require.NoError(t, env.GetWorkflowResult(&result))
 	require.Equal(t, "Hello Temporal!", result)
 }
+
+func Test_Workflow_BindToWorkflow(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	env.SetDataConverter(NewEncryptionDataConverter(
+		converter.GetDefaultDataConverter(),
+		DataConverterOptions{KeyProvider: newTestKeyring(t, "test", "test"), BindToWorkflow: true},
+	))
+	env.RegisterActivity(Activity)
+
+	env.ExecuteWorkflow(Workflow, "Temporal")
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var result string
+	require.NoError(t, env.GetWorkflowResult(&result))
+	require.Equal(t, "Hello Temporal!", result)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/temporalio/samples-go/goroutine"
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 	"log"
 
 	"go.temporal.io/sdk/client"
@@ -10,12 +11,13 @@ import (
 
 func main() {
 	// The client and worker are heavyweight objects that should be created once per process.
-	c, err := client.Dial(client.Options{
+	c, shutdown, err := bootstrap.Dial(client.Options{
 		HostPort: client.DefaultHostPort,
 	})
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
 	w := worker.New(c, "goroutine", worker.Options{})
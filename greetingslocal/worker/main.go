@@ -4,18 +4,20 @@ import (
 	"log"
 
 	"github.com/temporalio/samples-go/greetingslocal"
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/worker"
 )
 
 func main() {
 	// The client and worker are heavyweight objects that should be created once per process.
-	c, err := client.Dial(client.Options{
+	c, shutdown, err := bootstrap.Dial(client.Options{
 		HostPort: client.DefaultHostPort,
 	})
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
 	w := worker.New(c, "greetings-local", worker.Options{})
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+
+	"go.temporal.io/sdk/converter"
+	"google.golang.org/grpc"
+)
+
+// allNamespaces is the Config entry that applies to namespaces without their own entry.
+const allNamespaces = "*"
+
+// newNamespaceCodecInterceptor creates a client interceptor that encodes the payloads of requests and decodes the
+// payloads of responses with the codec chain of the namespace of the request. Payloads of requests without a namespace
+// or of namespaces without a codec chain are passed through unchanged.
+func newNamespaceCodecInterceptor(chains map[string][]converter.PayloadCodec) (grpc.UnaryClientInterceptor, error) {
+	interceptors := make(map[string]grpc.UnaryClientInterceptor, len(chains))
+	for namespace, codecs := range chains {
+		interceptor, err := converter.NewPayloadCodecGRPCClientInterceptor(
+			converter.PayloadCodecGRPCClientInterceptorOptions{Codecs: codecs},
+		)
+		if err != nil {
+			return nil, err
+		}
+		interceptors[namespace] = interceptor
+	}
+
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		namespace := requestNamespace(req)
+		interceptor, ok := interceptors[namespace]
+		if !ok && namespace != "" {
+			interceptor = interceptors[allNamespaces]
+		}
+		if interceptor != nil {
+			return interceptor(ctx, method, req, reply, cc, invoker, opts...)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}, nil
+}
+
+// requestNamespace returns the namespace of a WorkflowService request, or "" for requests without a namespace.
+func requestNamespace(req interface{}) string {
+	if r, ok := req.(interface{ GetNamespace() string }); ok {
+		return r.GetNamespace()
+	}
+	return ""
+}
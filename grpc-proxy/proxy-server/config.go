@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"go.temporal.io/sdk/converter"
+
+	grpcproxy "github.com/temporalio/samples-go/grpc-proxy"
+)
+
+// Config is the proxy configuration file, for example:
+//
+//	{
+//	  "namespaces": {
+//	    "default": {"codecs": ["snappy"]},
+//	    "payments": {"codecs": ["zlib", "snappy"]},
+//	    "*": {"codecs": ["snappy"]}
+//	  }
+//	}
+type Config struct {
+	// Namespaces maps namespaces to their configuration. The "*" entry applies to all other namespaces, payloads of
+	// namespaces without an entry are passed through unchanged.
+	Namespaces map[string]NamespaceConfig `json:"namespaces"`
+}
+
+// NamespaceConfig configures a namespace.
+type NamespaceConfig struct {
+	// Codecs is the codec chain, in the order passed to converter.NewCodecDataConverter: on encode the codecs are
+	// applied last to first. The codecs are "snappy" and "zlib".
+	Codecs []string `json:"codecs"`
+}
+
+// defaultConfig is used without a -config flag, it applies the snappy codec to all namespaces.
+var defaultConfig = &Config{
+	Namespaces: map[string]NamespaceConfig{
+		"*": {Codecs: []string{"snappy"}},
+	},
+}
+
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed parsing config %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+// codecChains creates the codec chains of all namespaces.
+func (c *Config) codecChains() (map[string][]converter.PayloadCodec, error) {
+	chains := make(map[string][]converter.PayloadCodec, len(c.Namespaces))
+	for namespace, namespaceConfig := range c.Namespaces {
+		if len(namespaceConfig.Codecs) == 0 {
+			return nil, fmt.Errorf("namespace %s has no codecs", namespace)
+		}
+		for _, name := range namespaceConfig.Codecs {
+			codec, err := newCodec(name)
+			if err != nil {
+				return nil, fmt.Errorf("namespace %s: %w", namespace, err)
+			}
+			chains[namespace] = append(chains[namespace], codec)
+		}
+	}
+	return chains, nil
+}
+
+func newCodec(name string) (converter.PayloadCodec, error) {
+	switch name {
+	case "snappy":
+		return grpcproxy.NewPayloadCodec(), nil
+	case "zlib":
+		return converter.NewZlibCodec(converter.ZlibCodecOptions{AlwaysEncode: true}), nil
+	default:
+		return nil, fmt.Errorf("unknown codec %q", name)
+	}
+}
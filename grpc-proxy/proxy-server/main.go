@@ -7,16 +7,14 @@ import (
 
 	"go.temporal.io/api/workflowservice/v1"
 	"go.temporal.io/sdk/client"
-	"go.temporal.io/sdk/converter"
 	"go.temporal.io/server/common/authorization"
 	"go.temporal.io/server/common/log/tag"
 	"go.temporal.io/server/common/metrics"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 
 	"go.temporal.io/server/common/log"
-
-	grpcproxy "github.com/temporalio/samples-go/grpc-proxy"
 )
 
 var logger log.Logger
@@ -24,6 +22,16 @@ var providerFlag string
 var audienceFlag string
 var portFlag int
 var upstreamFlag string
+var configFlag string
+var tlsCertFlag string
+var tlsKeyFlag string
+var tlsClientCAFlag string
+var upstreamTLSFlag bool
+var upstreamCAFlag string
+var upstreamCertFlag string
+var upstreamKeyFlag string
+var upstreamServerNameFlag string
+var policyFlag string
 
 func init() {
 	logger = log.NewCLILogger()
@@ -31,32 +39,59 @@ func init() {
 	flag.StringVar(&providerFlag, "provider", "", "OIDC Provider URL. Optional: Enforces oauth authentication")
 	flag.StringVar(&audienceFlag, "audience", "", "OIDC Audience. Optional.")
 	flag.StringVar(&upstreamFlag, "upstream", ":7233", "Upstream Temporal Server Endpoint")
+	flag.StringVar(&configFlag, "config", "", "Config file mapping namespaces to codecs. Optional: defaults to snappy for all namespaces")
+	flag.StringVar(&tlsCertFlag, "tls-cert", "", "TLS certificate file of the listener. Optional: enables TLS, reloaded when changed")
+	flag.StringVar(&tlsKeyFlag, "tls-key", "", "TLS key file of the listener")
+	flag.StringVar(&tlsClientCAFlag, "tls-client-ca", "", "CA file to verify client certificates. Optional: enables mTLS, reloaded when changed")
+	flag.BoolVar(&upstreamTLSFlag, "upstream-tls", false, "Use TLS for the upstream connection. Implied by the other -upstream-* TLS flags")
+	flag.StringVar(&upstreamCAFlag, "upstream-ca", "", "CA file to verify the upstream server. Optional: defaults to the system CAs")
+	flag.StringVar(&upstreamCertFlag, "upstream-cert", "", "Client certificate file for the upstream connection. Optional: enables mTLS, reloaded when changed")
+	flag.StringVar(&upstreamKeyFlag, "upstream-key", "", "Client key file for the upstream connection")
+	flag.StringVar(&upstreamServerNameFlag, "upstream-server-name", "", "Server name to verify the upstream certificate against. Optional: defaults to the upstream host")
+	flag.StringVar(&policyFlag, "policy", "", "Policy file allowing methods, workflow types and task queues by token claims and roles. Optional: requires -provider")
 }
 
+// Temporal allows messages of up to 128MB, the gRPC default is 4MB.
+const maxMessageSize = 128 * 1024 * 1024
+
 func main() {
 	flag.Parse()
 
-	clientInterceptor, err := converter.NewPayloadCodecGRPCClientInterceptor(
-		converter.PayloadCodecGRPCClientInterceptorOptions{
-			Codecs: []converter.PayloadCodec{grpcproxy.NewPayloadCodec()},
-		},
-	)
+	config := defaultConfig
+	if configFlag != "" {
+		var err error
+		if config, err = loadConfig(configFlag); err != nil {
+			logger.Fatal("unable to load config", tag.Error(err))
+		}
+	}
+	codecChains, err := config.codecChains()
+	if err != nil {
+		logger.Fatal("unable to create codecs", tag.Error(err))
+	}
+	clientInterceptor, err := newNamespaceCodecInterceptor(codecChains)
 	if err != nil {
 		logger.Fatal("unable to create interceptor", tag.Error(err))
 	}
 
+	upstreamCredentials := insecure.NewCredentials()
+	if upstreamTLSFlag || upstreamCAFlag != "" || upstreamCertFlag != "" || upstreamServerNameFlag != "" {
+		tlsConfig, err := newClientTLSConfig(upstreamCAFlag, upstreamCertFlag, upstreamKeyFlag, upstreamServerNameFlag)
+		if err != nil {
+			logger.Fatal("unable to configure upstream TLS", tag.Error(err))
+		}
+		upstreamCredentials = credentials.NewTLS(tlsConfig)
+	}
+
 	grpcClient, err := grpc.Dial(
 		upstreamFlag,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithTransportCredentials(upstreamCredentials),
 		grpc.WithUnaryInterceptor(clientInterceptor),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMessageSize), grpc.MaxCallSendMsgSize(maxMessageSize)),
 	)
-	defer func() { _ = grpcClient.Close() }()
-
-	workflowClient := workflowservice.NewWorkflowServiceClient(grpcClient)
-
 	if err != nil {
 		logger.Fatal("unable to create client", tag.Error(err))
 	}
+	defer func() { _ = grpcClient.Close() }()
 
 	serverInterceptors := []grpc.UnaryServerInterceptor{}
 	if providerFlag != "" {
@@ -69,18 +104,28 @@ func main() {
 			provider.audience = audienceFlag
 		}
 
-		serverInterceptors = append(serverInterceptors,
-			authorization.NewInterceptor(
-				newClaimMapper(provider.JWKSURI),
-				authorization.NewDefaultAuthorizer(),
-				metrics.NoopMetricsHandler,
-				logger,
-				newNamespaceChecker(),
-				provider,
-				"",
-				"",
-			).Intercept,
+		authInterceptor := authorization.NewInterceptor(
+			newClaimMapper(provider.JWKSURI),
+			authorization.NewDefaultAuthorizer(),
+			metrics.NoopMetricsHandler,
+			logger,
+			newNamespaceChecker(),
+			provider,
+			"",
+			"",
 		)
+		serverInterceptors = append(serverInterceptors, authInterceptor.Intercept)
+
+		if policyFlag != "" {
+			policy, err := loadPolicy(policyFlag)
+			if err != nil {
+				logger.Fatal("unable to load policy", tag.Error(err))
+			}
+			policyInterceptor := newPolicyInterceptor(policy, provider)
+			serverInterceptors = append(serverInterceptors, policyInterceptor.Intercept)
+		}
+	} else if policyFlag != "" {
+		logger.Fatal("-policy requires -provider")
 	}
 
 	listener, err := net.Listen("tcp", ":"+strconv.Itoa(portFlag))
@@ -88,18 +133,47 @@ func main() {
 		logger.Fatal("unable to create listener", tag.Error(err))
 	}
 
-	server := grpc.NewServer(grpc.ChainUnaryInterceptor(serverInterceptors...))
-	handler, err := client.NewWorkflowServiceProxyServer(
-		client.WorkflowServiceProxyOptions{Client: workflowClient},
-	)
+	var serverOptions []grpc.ServerOption
+	if tlsCertFlag != "" {
+		tlsConfig, err := newServerTLSConfig(tlsCertFlag, tlsKeyFlag, tlsClientCAFlag)
+		if err != nil {
+			logger.Fatal("unable to configure TLS", tag.Error(err))
+		}
+		serverOptions = append(serverOptions, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	server, err := newProxyServer(grpcClient, serverInterceptors, serverOptions...)
 	if err != nil {
 		logger.Fatal("unable to create service proxy", tag.Error(err))
 	}
 
-	workflowservice.RegisterWorkflowServiceServer(server, handler)
-
 	err = server.Serve(listener)
 	if err != nil {
 		logger.Fatal("unable to serve", tag.Error(err))
 	}
 }
+
+// newProxyServer creates the proxy server, which forwards the WorkflowService calls to upstream after the
+// interceptors. Other services, such as OperatorService, are not served.
+func newProxyServer(
+	upstream *grpc.ClientConn,
+	unaryInterceptors []grpc.UnaryServerInterceptor,
+	opts ...grpc.ServerOption,
+) (*grpc.Server, error) {
+	serverOptions := append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.MaxRecvMsgSize(maxMessageSize),
+		grpc.MaxSendMsgSize(maxMessageSize),
+	}, opts...)
+
+	server := grpc.NewServer(serverOptions...)
+	handler, err := client.NewWorkflowServiceProxyServer(
+		client.WorkflowServiceProxyOptions{Client: workflowservice.NewWorkflowServiceClient(upstream)},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	workflowservice.RegisterWorkflowServiceServer(server, handler)
+	return server, nil
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/serviceerror"
+	taskqueuepb "go.temporal.io/api/taskqueue/v1"
+	"go.temporal.io/server/common/authorization"
+	"go.temporal.io/server/common/log/tag"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Policy is the request authorization policy file, for example:
+//
+//	{
+//	  "rules": [
+//	    {
+//	      "name": "support",
+//	      "claim": "groups",
+//	      "value": "support",
+//	      "methods": ["DescribeWorkflowExecution", "QueryWorkflow", "GetWorkflowExecutionHistory", "List*"]
+//	    },
+//	    {
+//	      "name": "orders-team",
+//	      "role": "writer",
+//	      "namespaces": ["orders"],
+//	      "methods": ["*"],
+//	      "workflowTypes": ["orders."],
+//	      "taskQueues": ["orders"]
+//	    }
+//	  ]
+//	}
+//
+// A call is allowed when at least one rule that applies to the caller allows it, all other calls are denied.
+type Policy struct {
+	Rules []PolicyRule `json:"rules"`
+}
+
+// PolicyRule allows callers to call Methods. The caller fields select the callers the rule applies to, all of the set
+// ones must match and a rule without any applies to all callers. The request fields restrict the requests that carry
+// the field, requests without it are not restricted by it, except for workflowTypes: a rule with workflowTypes denies
+// the calls on a workflow execution whose request has no workflow type, such as TerminateWorkflowExecution, as the type
+// of the workflow is unknown to the proxy.
+type PolicyRule struct {
+	// Name identifies the rule in denial logs.
+	Name string `json:"name"`
+
+	// Subjects are the token subjects the rule applies to.
+	Subjects []string `json:"subjects,omitempty"`
+	// Claim and Value select tokens whose Claim is Value or, for list claims, contains Value.
+	Claim string `json:"claim,omitempty"`
+	Value string `json:"value,omitempty"`
+	// Role is the minimum Temporal role of the caller in the namespace of the request: "worker", "reader", "writer"
+	// or "admin".
+	Role string `json:"role,omitempty"`
+
+	// Methods are gRPC method names without service, such as "QueryWorkflow". A trailing "*" matches any suffix and
+	// "*" matches all methods.
+	Methods []string `json:"methods"`
+	// Namespaces are the allowed namespaces, "*" or empty allows all.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// WorkflowTypes are the allowed workflow type prefixes, empty allows all.
+	WorkflowTypes []string `json:"workflowTypes,omitempty"`
+	// TaskQueues are the allowed task queues, empty allows all.
+	TaskQueues []string `json:"taskQueues,omitempty"`
+}
+
+// policyCall is the call checked against the policy.
+type policyCall struct {
+	subject      string
+	claims       *authorization.Claims
+	tokenClaims  map[string]interface{}
+	method       string
+	namespace    string
+	workflowType string
+	taskQueue    string
+	// onExecution is set for requests on a workflow execution, such as SignalWorkflowExecution
+	onExecution bool
+}
+
+var roles = map[string]authorization.Role{
+	"worker": authorization.RoleWorker,
+	"reader": authorization.RoleReader,
+	"writer": authorization.RoleWriter,
+	"admin":  authorization.RoleAdmin,
+}
+
+func loadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed parsing policy %s: %w", path, err)
+	}
+	for i, rule := range policy.Rules {
+		if rule.Role != "" {
+			if _, ok := roles[rule.Role]; !ok {
+				return nil, fmt.Errorf("rule %d (%s): unknown role %q", i, rule.Name, rule.Role)
+			}
+		}
+		if (rule.Claim == "") != (rule.Value == "") {
+			return nil, fmt.Errorf("rule %d (%s): claim and value must be set together", i, rule.Name)
+		}
+		if len(rule.Methods) == 0 {
+			return nil, fmt.Errorf("rule %d (%s): no methods", i, rule.Name)
+		}
+	}
+	return &policy, nil
+}
+
+// allows returns the rule that allows the call, or nil when the call is denied.
+func (p *Policy) allows(call *policyCall) *PolicyRule {
+	for i := range p.Rules {
+		if rule := &p.Rules[i]; rule.appliesTo(call) && rule.allows(call) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func (r *PolicyRule) appliesTo(call *policyCall) bool {
+	if len(r.Subjects) > 0 && !contains(r.Subjects, call.subject) {
+		return false
+	}
+	if r.Claim != "" && !hasClaimValue(call.tokenClaims, r.Claim, r.Value) {
+		return false
+	}
+	if r.Role != "" {
+		role := call.claims.System
+		if call.namespace != "" {
+			role |= call.claims.Namespaces[call.namespace]
+		}
+		if role < roles[r.Role] {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *PolicyRule) allows(call *policyCall) bool {
+	if !matchesMethod(r.Methods, call.method) {
+		return false
+	}
+	if call.namespace != "" && len(r.Namespaces) > 0 && !contains(r.Namespaces, call.namespace) && !contains(r.Namespaces, "*") {
+		return false
+	}
+	if len(r.WorkflowTypes) > 0 {
+		if call.workflowType != "" && !hasPrefix(r.WorkflowTypes, call.workflowType) {
+			return false
+		}
+		// Fail closed, the workflow may be of any type
+		if call.workflowType == "" && call.onExecution {
+			return false
+		}
+	}
+	if call.taskQueue != "" && len(r.TaskQueues) > 0 && !contains(r.TaskQueues, call.taskQueue) {
+		return false
+	}
+	return true
+}
+
+// policyInterceptor enforces a Policy on the calls of the proxy. It runs after the authorization interceptor, which
+// verifies the tokens and the namespace roles.
+type policyInterceptor struct {
+	policy   *Policy
+	provider *Provider
+}
+
+func newPolicyInterceptor(policy *Policy, provider *Provider) *policyInterceptor {
+	return &policyInterceptor{policy: policy, provider: provider}
+}
+
+func (i *policyInterceptor) Intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := i.authorize(ctx, req, info.FullMethod, i.provider.Audience(ctx, req, info)); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (i *policyInterceptor) authorize(ctx context.Context, req interface{}, fullMethod, audience string) error {
+	call := &policyCall{
+		method:    path.Base(fullMethod),
+		namespace: requestNamespace(req),
+	}
+	if r, ok := req.(interface{ GetWorkflowType() *commonpb.WorkflowType }); ok {
+		call.workflowType = r.GetWorkflowType().GetName()
+	}
+	if r, ok := req.(interface{ GetTaskQueue() *taskqueuepb.TaskQueue }); ok {
+		call.taskQueue = r.GetTaskQueue().GetName()
+	}
+	call.onExecution = onExecution(req)
+
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("authorization")) > 0 {
+		token = md.Get("authorization")[0]
+	}
+	claims, err := i.provider.mapper.GetClaims(&authorization.AuthInfo{AuthToken: token, Audience: audience})
+	if err != nil {
+		return i.deny(call, fmt.Sprintf("invalid token: %v", err))
+	}
+	call.subject = claims.Subject
+	call.claims = claims
+	// The token is verified, so its claims can be read without verifying it again.
+	if call.tokenClaims, err = parseTokenClaims(token); err != nil {
+		return i.deny(call, err.Error())
+	}
+
+	if i.policy.allows(call) == nil {
+		return i.deny(call, "no policy rule allows the call")
+	}
+	return nil
+}
+
+// The requests on a workflow execution name it by its execution or ID
+type (
+	workflowExecutionRequest interface {
+		GetWorkflowExecution() *commonpb.WorkflowExecution
+	}
+	executionRequest interface {
+		GetExecution() *commonpb.WorkflowExecution
+	}
+	workflowIDRequest interface {
+		GetWorkflowId() string
+	}
+)
+
+// onExecution reports whether the request is a call on a workflow execution
+func onExecution(req interface{}) bool {
+	switch req.(type) {
+	case workflowExecutionRequest, executionRequest, workflowIDRequest:
+		return true
+	}
+	return false
+}
+
+// deny logs the denied call and returns a PermissionDenied error with the reason.
+func (i *policyInterceptor) deny(call *policyCall, reason string) error {
+	logger.Warn("call denied by policy",
+		tag.NewStringTag("subject", call.subject),
+		tag.NewStringTag("method", call.method),
+		tag.NewStringTag("namespace", call.namespace),
+		tag.NewStringTag("workflow-type", call.workflowType),
+		tag.NewStringTag("task-queue", call.taskQueue),
+		tag.NewStringTag("reason", reason),
+	)
+	message := fmt.Sprintf("%s is not allowed for %q", call.method, call.subject)
+	return serviceerror.ToStatus(serviceerror.NewPermissionDenied(message, reason)).Err()
+}
+
+// parseTokenClaims returns the claims of a JWT without verifying it.
+func parseTokenClaims(token string) (map[string]interface{}, error) {
+	parts := strings.Split(strings.TrimPrefix(token, "Bearer "), ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("malformed token: %w", err)
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("malformed token: %w", err)
+	}
+	return claims, nil
+}
+
+// hasClaimValue reports whether the claim is value or, for list claims, contains value.
+func hasClaimValue(claims map[string]interface{}, claim, value string) bool {
+	switch v := claims[claim].(type) {
+	case string:
+		return v == value
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok && s == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchesMethod(patterns []string, method string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(method, prefix) {
+				return true
+			}
+		} else if pattern == method {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPrefix(prefixes []string, value string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/serviceerror"
+	taskqueuepb "go.temporal.io/api/taskqueue/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/server/common/authorization"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeClaimMapper verifies tokens by looking up the authorization header in a map.
+type fakeClaimMapper map[string]*authorization.Claims
+
+func (m fakeClaimMapper) GetClaims(authInfo *authorization.AuthInfo) (*authorization.Claims, error) {
+	if claims, ok := m[authInfo.AuthToken]; ok {
+		return claims, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+// newToken returns an unsigned JWT with the given claims, which is accepted by fakeClaimMapper once added to it.
+func newToken(claims map[string]interface{}) string {
+	payload, _ := json.Marshal(claims)
+	return "Bearer e30." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+// fakeUpstream is the Temporal server behind the proxy.
+type fakeUpstream struct {
+	workflowservice.UnimplementedWorkflowServiceServer
+	grpc_health_v1.UnimplementedHealthServer
+}
+
+func (fakeUpstream) QueryWorkflow(context.Context, *workflowservice.QueryWorkflowRequest) (*workflowservice.QueryWorkflowResponse, error) {
+	return &workflowservice.QueryWorkflowResponse{}, nil
+}
+
+func (fakeUpstream) TerminateWorkflowExecution(context.Context, *workflowservice.TerminateWorkflowExecutionRequest) (*workflowservice.TerminateWorkflowExecutionResponse, error) {
+	return &workflowservice.TerminateWorkflowExecutionResponse{}, nil
+}
+
+func (fakeUpstream) ListWorkflowExecutions(context.Context, *workflowservice.ListWorkflowExecutionsRequest) (*workflowservice.ListWorkflowExecutionsResponse, error) {
+	return &workflowservice.ListWorkflowExecutionsResponse{}, nil
+}
+
+func (fakeUpstream) SignalWorkflowExecution(context.Context, *workflowservice.SignalWorkflowExecutionRequest) (*workflowservice.SignalWorkflowExecutionResponse, error) {
+	return &workflowservice.SignalWorkflowExecutionResponse{}, nil
+}
+
+func (fakeUpstream) StartWorkflowExecution(context.Context, *workflowservice.StartWorkflowExecutionRequest) (*workflowservice.StartWorkflowExecutionResponse, error) {
+	return &workflowservice.StartWorkflowExecutionResponse{RunId: "run-id"}, nil
+}
+
+func (fakeUpstream) Check(context.Context, *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+var (
+	supportToken = newToken(map[string]interface{}{"sub": "support-agent", "groups": []string{"support"}})
+	ordersToken  = newToken(map[string]interface{}{"sub": "orders-dev"})
+)
+
+const testPolicy = `{
+  "rules": [
+    {
+      "name": "support",
+      "claim": "groups",
+      "value": "support",
+      "methods": ["DescribeWorkflowExecution", "QueryWorkflow"]
+    },
+    {
+      "name": "orders-team",
+      "role": "writer",
+      "namespaces": ["orders"],
+      "methods": ["*"],
+      "workflowTypes": ["orders."],
+      "taskQueues": ["orders"]
+    }
+  ]
+}`
+
+// newTestProxy starts a fakeUpstream and a proxy enforcing testPolicy in front of it over in-process connections.
+func newTestProxy(t *testing.T) *grpc.ClientConn {
+	policyFile := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(policyFile, []byte(testPolicy), 0600))
+	policy, err := loadPolicy(policyFile)
+	require.NoError(t, err)
+
+	upstreamServer := grpc.NewServer()
+	workflowservice.RegisterWorkflowServiceServer(upstreamServer, fakeUpstream{})
+	grpc_health_v1.RegisterHealthServer(upstreamServer, fakeUpstream{})
+	upstream := serve(t, upstreamServer)
+
+	interceptor := newPolicyInterceptor(policy, &Provider{mapper: fakeClaimMapper{
+		supportToken: {Subject: "support-agent"},
+		ordersToken:  {Subject: "orders-dev", Namespaces: map[string]authorization.Role{"orders": authorization.RoleWriter}},
+	}})
+	proxyServer, err := newProxyServer(
+		upstream,
+		[]grpc.UnaryServerInterceptor{interceptor.Intercept},
+	)
+	require.NoError(t, err)
+	return serve(t, proxyServer)
+}
+
+// serve serves the server on an in-process listener and returns a connection to it.
+func serve(t *testing.T, server *grpc.Server) *grpc.ClientConn {
+	listener := bufconn.Listen(1024 * 1024)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", token)
+}
+
+// requirePermissionDenied checks that err is a PermissionDenied error of the policy.
+func requirePermissionDenied(t *testing.T, err error) {
+	var permissionDenied *serviceerror.PermissionDenied
+	require.ErrorAs(t, serviceerror.FromStatus(status.Convert(err)), &permissionDenied)
+	require.Equal(t, "no policy rule allows the call", permissionDenied.Reason)
+}
+
+func Test_Policy_Methods(t *testing.T) {
+	client := workflowservice.NewWorkflowServiceClient(newTestProxy(t))
+
+	_, err := client.QueryWorkflow(withToken(supportToken), &workflowservice.QueryWorkflowRequest{Namespace: "orders"})
+	require.NoError(t, err)
+	_, err = client.TerminateWorkflowExecution(withToken(supportToken), &workflowservice.TerminateWorkflowExecutionRequest{Namespace: "orders"})
+	requirePermissionDenied(t, err)
+
+	// The writer rule allows all methods, but only in its namespace.
+	_, err = client.ListWorkflowExecutions(withToken(ordersToken), &workflowservice.ListWorkflowExecutionsRequest{Namespace: "orders"})
+	require.NoError(t, err)
+	_, err = client.ListWorkflowExecutions(withToken(ordersToken), &workflowservice.ListWorkflowExecutionsRequest{Namespace: "default"})
+	requirePermissionDenied(t, err)
+
+	_, err = client.QueryWorkflow(withToken("Bearer unknown"), &workflowservice.QueryWorkflowRequest{Namespace: "orders"})
+	var permissionDenied *serviceerror.PermissionDenied
+	require.ErrorAs(t, serviceerror.FromStatus(status.Convert(err)), &permissionDenied)
+	require.Contains(t, permissionDenied.Reason, "invalid token")
+}
+
+func Test_Policy_WorkflowTypesAndTaskQueues(t *testing.T) {
+	client := workflowservice.NewWorkflowServiceClient(newTestProxy(t))
+	start := func(workflowType, taskQueue string) error {
+		_, err := client.StartWorkflowExecution(withToken(ordersToken), &workflowservice.StartWorkflowExecutionRequest{
+			Namespace:    "orders",
+			WorkflowId:   "workflow-id",
+			WorkflowType: &commonpb.WorkflowType{Name: workflowType},
+			TaskQueue:    &taskqueuepb.TaskQueue{Name: taskQueue},
+		})
+		return err
+	}
+
+	require.NoError(t, start("orders.Fulfillment", "orders"))
+	requirePermissionDenied(t, start("billing.Invoice", "orders"))
+	requirePermissionDenied(t, start("orders.Fulfillment", "billing"))
+}
+
+func Test_Policy_WorkflowTypesOnExecutions(t *testing.T) {
+	client := workflowservice.NewWorkflowServiceClient(newTestProxy(t))
+	execution := &commonpb.WorkflowExecution{WorkflowId: "orders-1"}
+
+	// The requests have no workflow type, so the rule limited to orders. workflows denies them
+	_, err := client.TerminateWorkflowExecution(withToken(ordersToken), &workflowservice.TerminateWorkflowExecutionRequest{
+		Namespace:         "orders",
+		WorkflowExecution: execution,
+	})
+	requirePermissionDenied(t, err)
+	_, err = client.SignalWorkflowExecution(withToken(ordersToken), &workflowservice.SignalWorkflowExecutionRequest{
+		Namespace:         "orders",
+		WorkflowExecution: execution,
+		SignalName:        "cancel",
+	})
+	requirePermissionDenied(t, err)
+	_, err = client.QueryWorkflow(withToken(ordersToken), &workflowservice.QueryWorkflowRequest{Namespace: "orders", Execution: execution})
+	requirePermissionDenied(t, err)
+
+	// Rules without workflow types allow them
+	_, err = client.QueryWorkflow(withToken(supportToken), &workflowservice.QueryWorkflowRequest{Namespace: "orders", Execution: execution})
+	require.NoError(t, err)
+}
+
+func Test_Policy_OtherServices(t *testing.T) {
+	client := grpc_health_v1.NewHealthClient(newTestProxy(t))
+
+	// The upstream serves the health service, but only WorkflowService goes through the proxy
+	_, err := client.Check(withToken(supportToken), &grpc_health_v1.HealthCheckRequest{})
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func Test_LoadPolicy(t *testing.T) {
+	for _, invalid := range []string{
+		`{"rules": [{"name": "r", "role": "owner", "methods": ["*"]}]}`,
+		`{"rules": [{"name": "r", "claim": "groups", "methods": ["*"]}]}`,
+		`{"rules": [{"name": "r"}]}`,
+	} {
+		policyFile := filepath.Join(t.TempDir(), "policy.json")
+		require.NoError(t, os.WriteFile(policyFile, []byte(invalid), 0600))
+		_, err := loadPolicy(policyFile)
+		require.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.temporal.io/server/common/log/tag"
+)
+
+// reloadCheckInterval is how often, at most, the files of a reloader are checked for changes.
+const reloadCheckInterval = 10 * time.Second
+
+// reloader holds a value loaded from files and reloads it on use when the files changed. When reloading fails the
+// previous value is kept.
+type reloader[T any] struct {
+	files []string
+	load  func() (T, error)
+
+	mu      sync.Mutex
+	value   T
+	modTime time.Time
+	checked time.Time
+}
+
+func newReloader[T any](load func() (T, error), files ...string) (*reloader[T], error) {
+	r := &reloader[T]{files: files, load: load}
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return nil, err
+	}
+	if r.value, err = load(); err != nil {
+		return nil, err
+	}
+	r.modTime = modTime
+	r.checked = time.Now()
+	return r, nil
+}
+
+func (r *reloader[T]) get() T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checked) < reloadCheckInterval {
+		return r.value
+	}
+	r.checked = time.Now()
+
+	modTime, err := r.latestModTime()
+	if err != nil || !modTime.After(r.modTime) {
+		return r.value
+	}
+	value, err := r.load()
+	if err != nil {
+		logger.Error("unable to reload TLS files", tag.Error(err))
+		return r.value
+	}
+	r.value = value
+	r.modTime = modTime
+	logger.Info(fmt.Sprintf("reloaded TLS files %v", r.files))
+	return r.value
+}
+
+func (r *reloader[T]) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range r.files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func newCertificateReloader(certFile, keyFile string) (*reloader[*tls.Certificate], error) {
+	return newReloader(func() (*tls.Certificate, error) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &cert, nil
+	}, certFile, keyFile)
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in %s", caFile)
+	}
+	return pool, nil
+}
+
+// newServerTLSConfig creates the TLS config of the listener. With clientCAFile clients must present a certificate
+// signed by it (mTLS). The certificate and the client CAs are reloaded when their files change.
+func newServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	certs, err := newCertificateReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return certs.get(), nil
+		},
+	}
+	if clientCAFile == "" {
+		return config, nil
+	}
+
+	clientCAs, err := newReloader(func() (*x509.CertPool, error) { return loadCertPool(clientCAFile) }, clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	base := config.Clone()
+	base.ClientAuth = tls.RequireAndVerifyClientCert
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c := base.Clone()
+		c.ClientCAs = clientCAs.get()
+		return c, nil
+	}
+	return config, nil
+}
+
+// newClientTLSConfig creates the TLS config of the upstream connection. caFile overrides the system CAs, certFile and
+// keyFile enable mTLS. The client certificate is reloaded when its files change, and used when the connection is
+// re-established.
+func newClientTLSConfig(caFile, certFile, keyFile, serverName string) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+	}
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	if certFile != "" {
+		certs, err := newCertificateReloader(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return certs.get(), nil
+		}
+	}
+	return config, nil
+}
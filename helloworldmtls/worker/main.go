@@ -5,7 +5,7 @@ import (
 	"os"
 
 	"github.com/temporalio/samples-go/helloworldmtls"
-	"go.temporal.io/sdk/client"
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 	"go.temporal.io/sdk/worker"
 )
 
@@ -15,10 +15,11 @@ func main() {
 	if err != nil {
 		log.Fatalf("Invalid arguments: %v", err)
 	}
-	c, err := client.Dial(clientOptions)
+	c, shutdown, err := bootstrap.Dial(clientOptions)
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
 	w := worker.New(c, "hello-world-mtls", worker.Options{})
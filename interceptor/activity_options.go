@@ -0,0 +1,292 @@
+package interceptor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+	"gopkg.in/yaml.v3"
+)
+
+// ActivityOptionsChangeID is the change ID of workflow.GetVersion guarding the overrides, so that the histories of
+// workflows recorded before the interceptor was added replay without overrides.
+const ActivityOptionsChangeID = "activity-options-overrides"
+
+type (
+	// ActivityOptionsConfig overrides the options of activities by activity type. The Version must be incremented on
+	// every change of the configuration.
+	ActivityOptionsConfig struct {
+		Version    int                                `json:"version" yaml:"version"`
+		Activities map[string]ActivityOptionsOverride `json:"activities" yaml:"activities"`
+	}
+
+	// ActivityOptionsOverride are the options replacing the ones set by the workflow. Zero values are not overridden.
+	// Task queue and heartbeat timeout do not apply to local activities.
+	ActivityOptionsOverride struct {
+		TaskQueue              string               `json:"taskQueue,omitempty" yaml:"taskQueue"`
+		ScheduleToCloseTimeout Duration             `json:"scheduleToCloseTimeout,omitempty" yaml:"scheduleToCloseTimeout"`
+		ScheduleToStartTimeout Duration             `json:"scheduleToStartTimeout,omitempty" yaml:"scheduleToStartTimeout"`
+		StartToCloseTimeout    Duration             `json:"startToCloseTimeout,omitempty" yaml:"startToCloseTimeout"`
+		HeartbeatTimeout       Duration             `json:"heartbeatTimeout,omitempty" yaml:"heartbeatTimeout"`
+		RetryPolicy            *RetryPolicyOverride `json:"retryPolicy,omitempty" yaml:"retryPolicy"`
+	}
+
+	// RetryPolicyOverride overrides fields of the retry policy of an activity. Zero values are not overridden.
+	RetryPolicyOverride struct {
+		InitialInterval        Duration `json:"initialInterval,omitempty" yaml:"initialInterval"`
+		BackoffCoefficient     float64  `json:"backoffCoefficient,omitempty" yaml:"backoffCoefficient"`
+		MaximumInterval        Duration `json:"maximumInterval,omitempty" yaml:"maximumInterval"`
+		MaximumAttempts        int32    `json:"maximumAttempts,omitempty" yaml:"maximumAttempts"`
+		NonRetryableErrorTypes []string `json:"nonRetryableErrorTypes,omitempty" yaml:"nonRetryableErrorTypes"`
+	}
+
+	// Duration is a time.Duration written as a string like "1m30s" in the configuration
+	Duration time.Duration
+
+	// ActivityOptionsConfigFile is a YAML or JSON configuration file, which can be reloaded while the worker runs
+	ActivityOptionsConfigFile struct {
+		Path    string
+		current atomic.Pointer[ActivityOptionsConfig]
+	}
+
+	// ActivityOptionsInterceptorOptions are the options of the activity options interceptor
+	ActivityOptionsInterceptorOptions struct {
+		// Config returns the current configuration. It is called by workflows scheduling activities, so it must not
+		// block. No activity options are overridden when nil.
+		Config func() ActivityOptionsConfig
+	}
+)
+
+// recordedOverride is the override of an activity type recorded in the workflow history
+type recordedOverride struct {
+	Version  int
+	Override *ActivityOptionsOverride `json:",omitempty"`
+}
+
+// NewActivityOptionsInterceptor creates an interceptor which overrides the options of the activities and local
+// activities scheduled by workflows, by activity type.
+//
+// The override of an activity type is recorded in the workflow history with workflow.MutableSideEffect, and only
+// recorded again when the Version of the configuration changes. Replays use the recorded overrides, so changing the
+// configuration does not cause nondeterminism, while running workflows use the new configuration for the activities
+// they schedule after the change.
+//
+// The histories of workflows which ran with the interceptor only replay with the interceptor, so it must stay
+// installed on the worker. Without configuration, it records that no activity options are overridden.
+func NewActivityOptionsInterceptor(options ActivityOptionsInterceptorOptions) interceptor.WorkerInterceptor {
+	return &activityOptionsWorkerInterceptor{options: options}
+}
+
+// LoadActivityOptionsConfig parses a YAML or JSON configuration file
+func LoadActivityOptionsConfig(path string) (ActivityOptionsConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ActivityOptionsConfig{}, err
+	}
+
+	// JSON is a subset of YAML, so both are decoded as YAML
+	var config ActivityOptionsConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		return ActivityOptionsConfig{}, fmt.Errorf("invalid activity options config %s: %w", path, err)
+	}
+	if config.Version <= 0 {
+		return ActivityOptionsConfig{}, fmt.Errorf("invalid activity options config %s: version must be positive", path)
+	}
+	return config, nil
+}
+
+// Reload loads the file again. The previous configuration is kept if the file is invalid.
+func (f *ActivityOptionsConfigFile) Reload() error {
+	config, err := LoadActivityOptionsConfig(f.Path)
+	if err != nil {
+		return err
+	}
+	if previous := f.current.Load(); previous != nil && previous.Version > config.Version {
+		return fmt.Errorf("version %d of %s is older than the loaded version %d", config.Version, f.Path, previous.Version)
+	}
+	f.current.Store(&config)
+	return nil
+}
+
+// Config returns the last loaded configuration
+func (f *ActivityOptionsConfigFile) Config() ActivityOptionsConfig {
+	if config := f.current.Load(); config != nil {
+		return *config
+	}
+	return ActivityOptionsConfig{}
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return d.parse(s)
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode {
+		return errors.New("duration must be a string like 1m30s")
+	}
+	return d.parse(value.Value)
+}
+
+func (d *Duration) parse(s string) error {
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// applyTo returns the activity options with the override
+func (o *ActivityOptionsOverride) applyTo(options workflow.ActivityOptions) workflow.ActivityOptions {
+	if o.TaskQueue != "" {
+		options.TaskQueue = o.TaskQueue
+	}
+	setDuration(&options.ScheduleToCloseTimeout, o.ScheduleToCloseTimeout)
+	setDuration(&options.ScheduleToStartTimeout, o.ScheduleToStartTimeout)
+	setDuration(&options.StartToCloseTimeout, o.StartToCloseTimeout)
+	setDuration(&options.HeartbeatTimeout, o.HeartbeatTimeout)
+	if o.RetryPolicy != nil {
+		options.RetryPolicy = o.RetryPolicy.applyTo(options.RetryPolicy)
+	}
+	return options
+}
+
+// applyToLocal returns the local activity options with the override
+func (o *ActivityOptionsOverride) applyToLocal(options workflow.LocalActivityOptions) workflow.LocalActivityOptions {
+	setDuration(&options.ScheduleToCloseTimeout, o.ScheduleToCloseTimeout)
+	setDuration(&options.StartToCloseTimeout, o.StartToCloseTimeout)
+	if o.RetryPolicy != nil {
+		options.RetryPolicy = o.RetryPolicy.applyTo(options.RetryPolicy)
+	}
+	return options
+}
+
+// applyTo returns a copy of the retry policy with the override
+func (o *RetryPolicyOverride) applyTo(policy *temporal.RetryPolicy) *temporal.RetryPolicy {
+	var result temporal.RetryPolicy
+	if policy != nil {
+		result = *policy
+	}
+	setDuration(&result.InitialInterval, o.InitialInterval)
+	setDuration(&result.MaximumInterval, o.MaximumInterval)
+	if o.BackoffCoefficient != 0 {
+		result.BackoffCoefficient = o.BackoffCoefficient
+	}
+	if o.MaximumAttempts != 0 {
+		result.MaximumAttempts = o.MaximumAttempts
+	}
+	if o.NonRetryableErrorTypes != nil {
+		result.NonRetryableErrorTypes = o.NonRetryableErrorTypes
+	}
+	return &result
+}
+
+func setDuration(target *time.Duration, override Duration) {
+	if override != 0 {
+		*target = time.Duration(override)
+	}
+}
+
+type activityOptionsWorkerInterceptor struct {
+	interceptor.WorkerInterceptorBase
+	options ActivityOptionsInterceptorOptions
+}
+
+func (w *activityOptionsWorkerInterceptor) InterceptWorkflow(
+	ctx workflow.Context,
+	next interceptor.WorkflowInboundInterceptor,
+) interceptor.WorkflowInboundInterceptor {
+	i := &activityOptionsWorkflowInboundInterceptor{root: w}
+	i.Next = next
+	return i
+}
+
+type activityOptionsWorkflowInboundInterceptor struct {
+	interceptor.WorkflowInboundInterceptorBase
+	root *activityOptionsWorkerInterceptor
+}
+
+func (w *activityOptionsWorkflowInboundInterceptor) Init(outbound interceptor.WorkflowOutboundInterceptor) error {
+	i := &activityOptionsWorkflowOutboundInterceptor{root: w.root}
+	i.Next = outbound
+	return w.Next.Init(i)
+}
+
+type activityOptionsWorkflowOutboundInterceptor struct {
+	interceptor.WorkflowOutboundInterceptorBase
+	root *activityOptionsWorkerInterceptor
+	// enabled is set on the first activity of the workflow, by the version of ActivityOptionsChangeID
+	enabled *bool
+}
+
+func (w *activityOptionsWorkflowOutboundInterceptor) ExecuteActivity(
+	ctx workflow.Context,
+	activityType string,
+	args ...interface{},
+) workflow.Future {
+	if override := w.override(ctx, activityType); override != nil {
+		ctx = workflow.WithActivityOptions(ctx, override.applyTo(workflow.GetActivityOptions(ctx)))
+	}
+	return w.Next.ExecuteActivity(ctx, activityType, args...)
+}
+
+func (w *activityOptionsWorkflowOutboundInterceptor) ExecuteLocalActivity(
+	ctx workflow.Context,
+	activityType string,
+	args ...interface{},
+) workflow.Future {
+	if override := w.override(ctx, activityType); override != nil {
+		ctx = workflow.WithLocalActivityOptions(ctx, override.applyToLocal(workflow.GetLocalActivityOptions(ctx)))
+	}
+	return w.Next.ExecuteLocalActivity(ctx, activityType, args...)
+}
+
+// override returns the override of the activity type recorded in the workflow history, if any
+func (w *activityOptionsWorkflowOutboundInterceptor) override(ctx workflow.Context, activityType string) *ActivityOptionsOverride {
+	if w.enabled == nil {
+		enabled := w.Next.GetVersion(ctx, ActivityOptionsChangeID, workflow.DefaultVersion, 1) != workflow.DefaultVersion
+		w.enabled = &enabled
+	}
+	if !*w.enabled {
+		return nil
+	}
+
+	var recorded recordedOverride
+	value := w.Next.MutableSideEffect(ctx, "activity-options/"+activityType,
+		func(ctx workflow.Context) interface{} {
+			var config ActivityOptionsConfig
+			if w.root.options.Config != nil {
+				config = w.root.options.Config()
+			}
+			recorded := recordedOverride{Version: config.Version}
+			if override, ok := config.Activities[activityType]; ok {
+				recorded.Override = &override
+			}
+			return recorded
+		},
+		func(a, b interface{}) bool {
+			return a.(recordedOverride).Version == b.(recordedOverride).Version
+		})
+	if err := value.Get(&recorded); err != nil {
+		workflow.GetLogger(ctx).Error("Failed to decode activity options override.", "ActivityType", activityType, "Error", err)
+		return nil
+	}
+	return recorded.Override
+}
@@ -0,0 +1,289 @@
+package interceptor_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/temporalio/samples-go/interceptor"
+	commonpb "go.temporal.io/api/common/v1"
+	enumspb "go.temporal.io/api/enums/v1"
+	historypb "go.temporal.io/api/history/v1"
+	taskqueuepb "go.temporal.io/api/taskqueue/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	sdkinterceptor "go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// configuredWorkflow runs the failingActivity twice, an hour apart, and then a local activity.
+func configuredWorkflow(ctx workflow.Context) error {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 10 * time.Second,
+		RetryPolicy:         &temporal.RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 1},
+	})
+	for i := 0; i < 2; i++ {
+		_ = workflow.ExecuteActivity(ctx, "failingActivity").Get(ctx, nil)
+		if err := workflow.Sleep(ctx, time.Hour); err != nil {
+			return err
+		}
+	}
+	ctx = workflow.WithLocalActivityOptions(ctx, workflow.LocalActivityOptions{
+		StartToCloseTimeout: 10 * time.Second,
+		RetryPolicy:         &temporal.RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 1},
+	})
+	_ = workflow.ExecuteLocalActivity(ctx, "failingActivity").Get(ctx, nil)
+	return nil
+}
+
+func TestActivityOptionsInterceptor(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(configuredWorkflow)
+
+	// Record the attempts and heartbeat timeouts of the activity executions
+	var attempts []int32
+	var heartbeatTimeouts []time.Duration
+	env.RegisterActivityWithOptions(func(ctx context.Context) error {
+		info := activity.GetInfo(ctx)
+		attempts = append(attempts, info.Attempt)
+		heartbeatTimeouts = append(heartbeatTimeouts, info.HeartbeatTimeout)
+		return errors.New("failure")
+	}, activity.RegisterOptions{Name: "failingActivity"})
+
+	config := interceptor.ActivityOptionsConfig{
+		Version: 1,
+		Activities: map[string]interceptor.ActivityOptionsOverride{
+			"failingActivity": {
+				HeartbeatTimeout: interceptor.Duration(time.Minute),
+				RetryPolicy:      &interceptor.RetryPolicyOverride{MaximumAttempts: 3},
+			},
+		},
+	}
+	env.SetWorkerOptions(worker.Options{
+		Interceptors: []sdkinterceptor.WorkerInterceptor{interceptor.NewActivityOptionsInterceptor(
+			interceptor.ActivityOptionsInterceptorOptions{
+				Config: func() interceptor.ActivityOptionsConfig { return config },
+			})},
+	})
+	env.RegisterDelayedCallback(func() {
+		config = interceptor.ActivityOptionsConfig{
+			Version: 2,
+			Activities: map[string]interceptor.ActivityOptionsOverride{
+				"failingActivity": {RetryPolicy: &interceptor.RetryPolicyOverride{MaximumAttempts: 2}},
+			},
+		}
+	}, 30*time.Minute)
+
+	env.ExecuteWorkflow(configuredWorkflow)
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	// Version 1 for the first activity, version 2 for the second activity and the local activity
+	require.Equal(t, []int32{1, 2, 3, 1, 2, 1, 2}, attempts)
+	require.Equal(t, []time.Duration{time.Minute, time.Minute, time.Minute, 0, 0}, heartbeatTimeouts[:5])
+}
+
+func TestLoadActivityOptionsConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+		return path
+	}
+
+	config, err := interceptor.LoadActivityOptionsConfig(write("config.yaml", `
+version: 3
+activities:
+  Activity:
+    taskQueue: slow
+    startToCloseTimeout: 1m30s
+    retryPolicy:
+      maximumAttempts: 5
+      nonRetryableErrorTypes: [InvalidInput]
+`))
+	require.NoError(t, err)
+	require.Equal(t, interceptor.ActivityOptionsConfig{
+		Version: 3,
+		Activities: map[string]interceptor.ActivityOptionsOverride{
+			"Activity": {
+				TaskQueue:           "slow",
+				StartToCloseTimeout: interceptor.Duration(90 * time.Second),
+				RetryPolicy: &interceptor.RetryPolicyOverride{
+					MaximumAttempts:        5,
+					NonRetryableErrorTypes: []string{"InvalidInput"},
+				},
+			},
+		},
+	}, config)
+
+	jsonConfig, err := interceptor.LoadActivityOptionsConfig(write("config.json", `{
+  "version": 3,
+  "activities": {
+    "Activity": {
+      "taskQueue": "slow",
+      "startToCloseTimeout": "1m30s",
+      "retryPolicy": {"maximumAttempts": 5, "nonRetryableErrorTypes": ["InvalidInput"]}
+    }
+  }
+}`))
+	require.NoError(t, err)
+	require.Equal(t, config, jsonConfig)
+
+	_, err = interceptor.LoadActivityOptionsConfig(write("unknown.yaml", "version: 1\nactivities:\n  Activity:\n    timeout: 1m\n"))
+	require.ErrorContains(t, err, "field timeout not found")
+	_, err = interceptor.LoadActivityOptionsConfig(write("duration.yaml", "version: 1\nactivities:\n  Activity:\n    heartbeatTimeout: 10\n"))
+	require.ErrorContains(t, err, "missing unit")
+	_, err = interceptor.LoadActivityOptionsConfig(write("version.yaml", "activities: {}\n"))
+	require.ErrorContains(t, err, "version must be positive")
+
+	// A reload keeps the previous configuration when the new one is invalid or older
+	file := &interceptor.ActivityOptionsConfigFile{Path: filepath.Join(dir, "config.yaml")}
+	require.NoError(t, file.Reload())
+	require.Equal(t, config, file.Config())
+	file.Path = filepath.Join(dir, "version.yaml")
+	require.Error(t, file.Reload())
+	file.Path = write("older.yaml", "version: 2\n")
+	require.ErrorContains(t, file.Reload(), "older than the loaded version 3")
+	require.Equal(t, config, file.Config())
+}
+
+// overriddenHistory returns the history of Workflow run by a worker with the activity options interceptor and a
+// configuration overriding the StartToCloseTimeout of Activity, as recorded by the SDK.
+func overriddenHistory(t *testing.T) *historypb.History {
+	dc := converter.GetDefaultDataConverter()
+	payloads := func(values ...interface{}) *commonpb.Payloads {
+		result, err := dc.ToPayloads(values...)
+		require.NoError(t, err)
+		return result
+	}
+	taskQueue := &taskqueuepb.TaskQueue{Name: "interceptor"}
+	// The recorded override, the same JSON as the one of the interceptor
+	override := payloads(struct {
+		Version  int
+		Override *interceptor.ActivityOptionsOverride
+	}{1, &interceptor.ActivityOptionsOverride{StartToCloseTimeout: interceptor.Duration(time.Minute)}})
+	changeVersion, err := dc.ToPayload([]string{interceptor.ActivityOptionsChangeID + "-1"})
+	require.NoError(t, err)
+
+	events := []*historypb.HistoryEvent{
+		{EventType: enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_STARTED, Attributes: &historypb.HistoryEvent_WorkflowExecutionStartedEventAttributes{
+			WorkflowExecutionStartedEventAttributes: &historypb.WorkflowExecutionStartedEventAttributes{
+				WorkflowType: &commonpb.WorkflowType{Name: "Workflow"},
+				TaskQueue:    taskQueue,
+				Input:        payloads("Temporal"),
+			}}},
+		{EventType: enumspb.EVENT_TYPE_WORKFLOW_TASK_SCHEDULED, Attributes: &historypb.HistoryEvent_WorkflowTaskScheduledEventAttributes{
+			WorkflowTaskScheduledEventAttributes: &historypb.WorkflowTaskScheduledEventAttributes{TaskQueue: taskQueue}}},
+		{EventType: enumspb.EVENT_TYPE_WORKFLOW_TASK_STARTED, Attributes: &historypb.HistoryEvent_WorkflowTaskStartedEventAttributes{
+			WorkflowTaskStartedEventAttributes: &historypb.WorkflowTaskStartedEventAttributes{ScheduledEventId: 2}}},
+		{EventType: enumspb.EVENT_TYPE_WORKFLOW_TASK_COMPLETED, Attributes: &historypb.HistoryEvent_WorkflowTaskCompletedEventAttributes{
+			WorkflowTaskCompletedEventAttributes: &historypb.WorkflowTaskCompletedEventAttributes{ScheduledEventId: 2, StartedEventId: 3}}},
+		// workflow.GetVersion records a marker and the TemporalChangeVersion search attribute
+		{EventType: enumspb.EVENT_TYPE_MARKER_RECORDED, Attributes: &historypb.HistoryEvent_MarkerRecordedEventAttributes{
+			MarkerRecordedEventAttributes: &historypb.MarkerRecordedEventAttributes{
+				MarkerName: "Version",
+				Details: map[string]*commonpb.Payloads{
+					"change-id": payloads(interceptor.ActivityOptionsChangeID),
+					"version":   payloads(1),
+				},
+				WorkflowTaskCompletedEventId: 4,
+			}}},
+		{EventType: enumspb.EVENT_TYPE_UPSERT_WORKFLOW_SEARCH_ATTRIBUTES, Attributes: &historypb.HistoryEvent_UpsertWorkflowSearchAttributesEventAttributes{
+			UpsertWorkflowSearchAttributesEventAttributes: &historypb.UpsertWorkflowSearchAttributesEventAttributes{
+				SearchAttributes: &commonpb.SearchAttributes{
+					IndexedFields: map[string]*commonpb.Payload{"TemporalChangeVersion": changeVersion},
+				},
+				WorkflowTaskCompletedEventId: 4,
+			}}},
+		// workflow.MutableSideEffect records the override of the activity type
+		{EventType: enumspb.EVENT_TYPE_MARKER_RECORDED, Attributes: &historypb.HistoryEvent_MarkerRecordedEventAttributes{
+			MarkerRecordedEventAttributes: &historypb.MarkerRecordedEventAttributes{
+				MarkerName: "MutableSideEffect",
+				Details: map[string]*commonpb.Payloads{
+					"side-effect-id":                   payloads("activity-options/Activity_7"),
+					"data":                             payloads("activity-options/Activity", override),
+					"mutable-side-effect-call-counter": payloads(1),
+				},
+				WorkflowTaskCompletedEventId: 4,
+			}}},
+		{EventType: enumspb.EVENT_TYPE_ACTIVITY_TASK_SCHEDULED, Attributes: &historypb.HistoryEvent_ActivityTaskScheduledEventAttributes{
+			ActivityTaskScheduledEventAttributes: &historypb.ActivityTaskScheduledEventAttributes{
+				ActivityId:                   "8",
+				ActivityType:                 &commonpb.ActivityType{Name: "Activity"},
+				TaskQueue:                    taskQueue,
+				Input:                        payloads("Temporal"),
+				StartToCloseTimeout:          durationpb.New(time.Minute),
+				WorkflowTaskCompletedEventId: 4,
+			}}},
+		{EventType: enumspb.EVENT_TYPE_ACTIVITY_TASK_STARTED, Attributes: &historypb.HistoryEvent_ActivityTaskStartedEventAttributes{
+			ActivityTaskStartedEventAttributes: &historypb.ActivityTaskStartedEventAttributes{ScheduledEventId: 8, Attempt: 1}}},
+		{EventType: enumspb.EVENT_TYPE_ACTIVITY_TASK_COMPLETED, Attributes: &historypb.HistoryEvent_ActivityTaskCompletedEventAttributes{
+			ActivityTaskCompletedEventAttributes: &historypb.ActivityTaskCompletedEventAttributes{
+				ScheduledEventId: 8,
+				StartedEventId:   9,
+				Result:           payloads("Hello Temporal!"),
+			}}},
+		{EventType: enumspb.EVENT_TYPE_WORKFLOW_TASK_SCHEDULED, Attributes: &historypb.HistoryEvent_WorkflowTaskScheduledEventAttributes{
+			WorkflowTaskScheduledEventAttributes: &historypb.WorkflowTaskScheduledEventAttributes{TaskQueue: taskQueue}}},
+		{EventType: enumspb.EVENT_TYPE_WORKFLOW_TASK_STARTED, Attributes: &historypb.HistoryEvent_WorkflowTaskStartedEventAttributes{
+			WorkflowTaskStartedEventAttributes: &historypb.WorkflowTaskStartedEventAttributes{ScheduledEventId: 11}}},
+		{EventType: enumspb.EVENT_TYPE_WORKFLOW_TASK_COMPLETED, Attributes: &historypb.HistoryEvent_WorkflowTaskCompletedEventAttributes{
+			WorkflowTaskCompletedEventAttributes: &historypb.WorkflowTaskCompletedEventAttributes{ScheduledEventId: 11, StartedEventId: 12}}},
+		{EventType: enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED, Attributes: &historypb.HistoryEvent_WorkflowExecutionCompletedEventAttributes{
+			WorkflowExecutionCompletedEventAttributes: &historypb.WorkflowExecutionCompletedEventAttributes{
+				Result:                       payloads("Hello Temporal!"),
+				WorkflowTaskCompletedEventId: 13,
+			}}},
+	}
+	for i, event := range events {
+		event.EventId = int64(i + 1)
+	}
+	return &historypb.History{Events: events}
+}
+
+// replay replays the history with the interceptors.
+func replay(t *testing.T, history *historypb.History, interceptors ...sdkinterceptor.WorkerInterceptor) error {
+	replayer, err := worker.NewWorkflowReplayerWithOptions(worker.WorkflowReplayerOptions{Interceptors: interceptors})
+	require.NoError(t, err)
+	replayer.RegisterWorkflow(interceptor.Workflow)
+	return replayer.ReplayWorkflowHistory(nil, history)
+}
+
+func TestActivityOptionsInterceptor_Replay(t *testing.T) {
+	history := overriddenHistory(t)
+
+	// Without configuration, as the worker runs without -activity-options
+	require.NoError(t, replay(t, history, interceptor.NewActivityOptionsInterceptor(interceptor.ActivityOptionsInterceptorOptions{})))
+
+	// After a change of the configuration, the recorded override is used
+	require.NoError(t, replay(t, history, interceptor.NewActivityOptionsInterceptor(interceptor.ActivityOptionsInterceptorOptions{
+		Config: func() interceptor.ActivityOptionsConfig {
+			return interceptor.ActivityOptionsConfig{
+				Version: 2,
+				Activities: map[string]interceptor.ActivityOptionsOverride{
+					"Activity": {StartToCloseTimeout: interceptor.Duration(time.Hour)},
+				},
+			}
+		},
+	})))
+
+	// Without the interceptor, the markers don't match the workflow
+	require.Error(t, replay(t, history))
+
+	// Histories recorded before the interceptor was added replay with it
+	replayer, err := worker.NewWorkflowReplayerWithOptions(worker.WorkflowReplayerOptions{
+		Interceptors: []sdkinterceptor.WorkerInterceptor{interceptor.NewActivityOptionsInterceptor(interceptor.ActivityOptionsInterceptorOptions{})},
+	})
+	require.NoError(t, err)
+	replayer.RegisterWorkflow(interceptor.Workflow)
+	require.NoError(t, replayer.ReplayWorkflowHistoryFromJSONFile(nil, "workflow_history.json"))
+}
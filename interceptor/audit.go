@@ -0,0 +1,378 @@
+package interceptor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+	"google.golang.org/protobuf/proto"
+)
+
+// AuditKind is the kind of call of an AuditRecord
+type AuditKind string
+
+const (
+	AuditWorkflowStarted  AuditKind = "WorkflowStarted"
+	AuditWorkflowFinished AuditKind = "WorkflowFinished"
+	AuditActivity         AuditKind = "Activity"
+	AuditLocalActivity    AuditKind = "LocalActivity"
+	AuditChildWorkflow    AuditKind = "ChildWorkflow"
+	AuditSignal           AuditKind = "Signal"
+	AuditUpdate           AuditKind = "Update"
+	AuditUpdateRejected   AuditKind = "UpdateRejected"
+	AuditQuery            AuditKind = "Query"
+)
+
+// AuditRecord is a call recorded by the audit interceptor
+type AuditRecord struct {
+	Kind AuditKind
+	// Name is the workflow type, activity type, signal, update or query name.
+	Name       string
+	Time       time.Time
+	WorkflowID string
+	RunID      string
+	Duration   time.Duration `json:",omitempty"`
+	Attempt    int32         `json:",omitempty"`
+	// ErrorType is the type of the ApplicationError ("ApplicationError" when it has none), or the kind of the error,
+	// when the call failed.
+	ErrorType string `json:",omitempty"`
+	// InputBytes and ResultBytes are the sizes of the encoded payloads, if AuditOptions.PayloadSizes is set.
+	InputBytes  int `json:",omitempty"`
+	ResultBytes int `json:",omitempty"`
+}
+
+// AuditSink receives the records of the audit interceptor. Records of workflows are sent from the workflow, so Record
+// should not block.
+type AuditSink interface {
+	Record(record AuditRecord)
+}
+
+// AuditOptions are the options of the audit interceptor
+type AuditOptions struct {
+	Sink AuditSink
+	// PayloadSizes adds the sizes of inputs and results to the records.
+	PayloadSizes bool
+	// DataConverter encodes the payloads to compute their sizes. Defaults to converter.GetDefaultDataConverter().
+	DataConverter converter.DataConverter
+}
+
+type (
+	// LoggerAuditSink logs the records
+	LoggerAuditSink struct {
+		Logger log.Logger
+	}
+
+	// JSONLinesAuditSink writes the records as JSON lines, for instance to a file
+	JSONLinesAuditSink struct {
+		mut     sync.Mutex
+		encoder *json.Encoder
+	}
+
+	// ChannelAuditSink sends the records to a channel, for instance in tests
+	ChannelAuditSink chan AuditRecord
+)
+
+// NewAuditInterceptor creates an interceptor which records the workflows, activities, child workflows, signals,
+// updates and queries handled by a worker. The records of workflows are not repeated when the workflow is replayed.
+// Activities are recorded by the worker running them, with the attempt of each execution. Updates rejected by their
+// validator are recorded as AuditUpdateRejected, with the type of the validation error.
+func NewAuditInterceptor(options AuditOptions) interceptor.WorkerInterceptor {
+	if options.DataConverter == nil {
+		options.DataConverter = converter.GetDefaultDataConverter()
+	}
+	return &auditWorkerInterceptor{options: options}
+}
+
+// Record logs the record
+func (s *LoggerAuditSink) Record(record AuditRecord) {
+	keyvals := []interface{}{
+		"Kind", record.Kind,
+		"Name", record.Name,
+		"WorkflowID", record.WorkflowID,
+		"RunID", record.RunID,
+		"Duration", record.Duration,
+		"Attempt", record.Attempt,
+	}
+	if record.ErrorType != "" {
+		keyvals = append(keyvals, "ErrorType", record.ErrorType)
+	}
+	if record.InputBytes != 0 || record.ResultBytes != 0 {
+		keyvals = append(keyvals, "InputBytes", record.InputBytes, "ResultBytes", record.ResultBytes)
+	}
+	s.Logger.Info("Audit", keyvals...)
+}
+
+// NewJSONLinesAuditSink creates a sink writing the records as JSON lines to w
+func NewJSONLinesAuditSink(w io.Writer) *JSONLinesAuditSink {
+	return &JSONLinesAuditSink{encoder: json.NewEncoder(w)}
+}
+
+// Record writes the record as a JSON line. Write errors are ignored.
+func (s *JSONLinesAuditSink) Record(record AuditRecord) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	_ = s.encoder.Encode(record)
+}
+
+// Record sends the record to the channel
+func (s ChannelAuditSink) Record(record AuditRecord) {
+	s <- record
+}
+
+type auditWorkerInterceptor struct {
+	interceptor.WorkerInterceptorBase
+	options AuditOptions
+}
+
+func (w *auditWorkerInterceptor) InterceptActivity(
+	ctx context.Context,
+	next interceptor.ActivityInboundInterceptor,
+) interceptor.ActivityInboundInterceptor {
+	i := &auditActivityInboundInterceptor{root: w}
+	i.Next = next
+	return i
+}
+
+type auditActivityInboundInterceptor struct {
+	interceptor.ActivityInboundInterceptorBase
+	root *auditWorkerInterceptor
+}
+
+func (a *auditActivityInboundInterceptor) ExecuteActivity(
+	ctx context.Context,
+	in *interceptor.ExecuteActivityInput,
+) (interface{}, error) {
+	start := time.Now()
+	result, err := a.Next.ExecuteActivity(ctx, in)
+
+	info := activity.GetInfo(ctx)
+	record := AuditRecord{
+		Kind:        AuditActivity,
+		Name:        info.ActivityType.Name,
+		Time:        start,
+		WorkflowID:  info.WorkflowExecution.ID,
+		RunID:       info.WorkflowExecution.RunID,
+		Duration:    time.Since(start),
+		Attempt:     info.Attempt,
+		ErrorType:   errorType(err),
+		InputBytes:  a.root.argsSize(in.Args...),
+		ResultBytes: a.root.resultSize(result, err),
+	}
+	if info.IsLocalActivity {
+		record.Kind = AuditLocalActivity
+	}
+	a.root.options.Sink.Record(record)
+	return result, err
+}
+
+func (w *auditWorkerInterceptor) InterceptWorkflow(
+	ctx workflow.Context,
+	next interceptor.WorkflowInboundInterceptor,
+) interceptor.WorkflowInboundInterceptor {
+	i := &auditWorkflowInboundInterceptor{root: w}
+	i.Next = next
+	return i
+}
+
+type auditWorkflowInboundInterceptor struct {
+	interceptor.WorkflowInboundInterceptorBase
+	root *auditWorkerInterceptor
+}
+
+func (w *auditWorkflowInboundInterceptor) Init(outbound interceptor.WorkflowOutboundInterceptor) error {
+	i := &auditWorkflowOutboundInterceptor{root: w.root}
+	i.Next = outbound
+	return w.Next.Init(i)
+}
+
+func (w *auditWorkflowInboundInterceptor) ExecuteWorkflow(
+	ctx workflow.Context,
+	in *interceptor.ExecuteWorkflowInput,
+) (interface{}, error) {
+	info := workflow.GetInfo(ctx)
+	w.root.record(ctx, AuditRecord{
+		Kind:       AuditWorkflowStarted,
+		Name:       info.WorkflowType.Name,
+		Attempt:    info.Attempt,
+		InputBytes: w.root.argsSize(in.Args...),
+	})
+
+	result, err := w.Next.ExecuteWorkflow(ctx, in)
+
+	w.root.record(ctx, AuditRecord{
+		Kind:        AuditWorkflowFinished,
+		Name:        info.WorkflowType.Name,
+		Duration:    workflow.Now(ctx).Sub(info.WorkflowStartTime),
+		Attempt:     info.Attempt,
+		ErrorType:   errorType(err),
+		ResultBytes: w.root.resultSize(result, err),
+	})
+	return result, err
+}
+
+func (w *auditWorkflowInboundInterceptor) HandleSignal(ctx workflow.Context, in *interceptor.HandleSignalInput) error {
+	err := w.Next.HandleSignal(ctx, in)
+	record := AuditRecord{Kind: AuditSignal, Name: in.SignalName, ErrorType: errorType(err)}
+	if w.root.options.PayloadSizes {
+		record.InputBytes = payloadsSize(in.Arg)
+	}
+	w.root.record(ctx, record)
+	return err
+}
+
+func (w *auditWorkflowInboundInterceptor) ExecuteUpdate(
+	ctx workflow.Context,
+	in *interceptor.UpdateInput,
+) (interface{}, error) {
+	start := workflow.Now(ctx)
+	result, err := w.Next.ExecuteUpdate(ctx, in)
+	w.root.record(ctx, AuditRecord{
+		Kind:        AuditUpdate,
+		Name:        in.Name,
+		Duration:    workflow.Now(ctx).Sub(start),
+		ErrorType:   errorType(err),
+		InputBytes:  w.root.argsSize(in.Args...),
+		ResultBytes: w.root.resultSize(result, err),
+	})
+	return result, err
+}
+
+func (w *auditWorkflowInboundInterceptor) ValidateUpdate(ctx workflow.Context, in *interceptor.UpdateInput) error {
+	err := w.Next.ValidateUpdate(ctx, in)
+	// Accepted updates are recorded once they complete
+	if err != nil {
+		w.root.record(ctx, AuditRecord{
+			Kind:       AuditUpdateRejected,
+			Name:       in.Name,
+			ErrorType:  errorType(err),
+			InputBytes: w.root.argsSize(in.Args...),
+		})
+	}
+	return err
+}
+
+func (w *auditWorkflowInboundInterceptor) HandleQuery(
+	ctx workflow.Context,
+	in *interceptor.HandleQueryInput,
+) (interface{}, error) {
+	result, err := w.Next.HandleQuery(ctx, in)
+	// Queries are not part of the history, so they are recorded even when replaying. Built-in queries are skipped.
+	if !strings.HasPrefix(in.QueryType, "__") {
+		w.root.emit(ctx, AuditRecord{
+			Kind:        AuditQuery,
+			Name:        in.QueryType,
+			ErrorType:   errorType(err),
+			InputBytes:  w.root.argsSize(in.Args...),
+			ResultBytes: w.root.resultSize(result, err),
+		})
+	}
+	return result, err
+}
+
+type auditWorkflowOutboundInterceptor struct {
+	interceptor.WorkflowOutboundInterceptorBase
+	root *auditWorkerInterceptor
+}
+
+func (w *auditWorkflowOutboundInterceptor) ExecuteChildWorkflow(
+	ctx workflow.Context,
+	childWorkflowType string,
+	args ...interface{},
+) workflow.ChildWorkflowFuture {
+	start := workflow.Now(ctx)
+	future := w.Next.ExecuteChildWorkflow(ctx, childWorkflowType, args...)
+	record := AuditRecord{Kind: AuditChildWorkflow, Name: childWorkflowType, InputBytes: w.root.argsSize(args...)}
+	// Record the child workflow once it is finished
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		err := future.Get(ctx, nil)
+		record.Duration = workflow.Now(ctx).Sub(start)
+		record.ErrorType = errorType(err)
+		w.root.record(ctx, record)
+	})
+	return future
+}
+
+// record sends a record of the workflow to the sink, unless the workflow is replaying
+func (w *auditWorkerInterceptor) record(ctx workflow.Context, record AuditRecord) {
+	if workflow.IsReplaying(ctx) {
+		return
+	}
+	w.emit(ctx, record)
+}
+
+// emit sends a record of the workflow to the sink
+func (w *auditWorkerInterceptor) emit(ctx workflow.Context, record AuditRecord) {
+	info := workflow.GetInfo(ctx)
+	record.Time = workflow.Now(ctx)
+	record.WorkflowID = info.WorkflowExecution.ID
+	record.RunID = info.WorkflowExecution.RunID
+	w.options.Sink.Record(record)
+}
+
+func (w *auditWorkerInterceptor) argsSize(args ...interface{}) int {
+	if !w.options.PayloadSizes || len(args) == 0 {
+		return 0
+	}
+	payloads, err := w.options.DataConverter.ToPayloads(args...)
+	if err != nil {
+		return 0
+	}
+	return payloadsSize(payloads)
+}
+
+func (w *auditWorkerInterceptor) resultSize(result interface{}, err error) int {
+	if !w.options.PayloadSizes || err != nil || result == nil {
+		return 0
+	}
+	payload, err := w.options.DataConverter.ToPayload(result)
+	if err != nil {
+		return 0
+	}
+	return proto.Size(payload)
+}
+
+func payloadsSize(payloads *commonpb.Payloads) int {
+	if payloads == nil {
+		return 0
+	}
+	return proto.Size(payloads)
+}
+
+// errorType returns the ErrorType of a record, which is only empty when err is nil
+func errorType(err error) string {
+	var applicationErr *temporal.ApplicationError
+	var canceledErr *temporal.CanceledError
+	var timeoutErr *temporal.TimeoutError
+	var terminatedErr *temporal.TerminatedError
+	var continueAsNewErr *workflow.ContinueAsNewError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &continueAsNewErr):
+		return "ContinueAsNew"
+	case errors.As(err, &applicationErr) && applicationErr.Type() != "":
+		return applicationErr.Type()
+	case applicationErr != nil:
+		return "ApplicationError"
+	case errors.As(err, &canceledErr):
+		return "Canceled"
+	case errors.As(err, &timeoutErr):
+		return "Timeout"
+	case errors.As(err, &terminatedErr):
+		return "Terminated"
+	default:
+		return fmt.Sprintf("%T", err)
+	}
+}
@@ -0,0 +1,213 @@
+package interceptor_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/temporalio/samples-go/interceptor"
+	"go.temporal.io/sdk/activity"
+	sdkinterceptor "go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+// flakyActivity fails its first attempt.
+func flakyActivity(ctx context.Context) error {
+	if activity.GetInfo(ctx).Attempt == 1 {
+		return temporal.NewApplicationError("first attempt", "Flaky")
+	}
+	return nil
+}
+
+// auditedWorkflow makes every kind of call recorded by the audit interceptor.
+func auditedWorkflow(ctx workflow.Context, name string) (string, error) {
+	if err := workflow.SetQueryHandler(ctx, "name", func() (string, error) { return name, nil }); err != nil {
+		return "", err
+	}
+	if err := workflow.SetUpdateHandlerWithOptions(ctx, "rename", func(ctx workflow.Context, newName string) (string, error) {
+		name = newName
+		return name, nil
+	}, workflow.UpdateHandlerOptions{Validator: func(ctx workflow.Context, newName string) error {
+		if newName == "" {
+			return temporal.NewApplicationError("name is required", "InvalidName")
+		}
+		return nil
+	}}); err != nil {
+		return "", err
+	}
+
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 10 * time.Second,
+		RetryPolicy:         &temporal.RetryPolicy{InitialInterval: time.Second},
+	})
+	if err := workflow.ExecuteActivity(ctx, flakyActivity).Get(ctx, nil); err != nil {
+		return "", err
+	}
+	ctx = workflow.WithLocalActivityOptions(ctx, workflow.LocalActivityOptions{StartToCloseTimeout: 10 * time.Second})
+	if err := workflow.ExecuteLocalActivity(ctx, interceptor.Activity, name).Get(ctx, nil); err != nil {
+		return "", err
+	}
+
+	workflow.GetSignalChannel(ctx, "done").Receive(ctx, nil)
+
+	var result string
+	err := workflow.ExecuteChildWorkflow(ctx, interceptor.Workflow, name).Get(ctx, &result)
+	return result, err
+}
+
+func TestAuditInterceptor(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(auditedWorkflow)
+	env.RegisterWorkflow(interceptor.Workflow)
+	env.RegisterActivity(interceptor.Activity)
+	env.RegisterActivity(flakyActivity)
+
+	sink := make(interceptor.ChannelAuditSink, 100)
+	env.SetWorkerOptions(worker.Options{
+		Interceptors: []sdkinterceptor.WorkerInterceptor{interceptor.NewAuditInterceptor(interceptor.AuditOptions{
+			Sink:         sink,
+			PayloadSizes: true,
+		})},
+	})
+
+	env.RegisterDelayedCallback(func() {
+		_, err := env.QueryWorkflow("name")
+		require.NoError(t, err)
+		env.UpdateWorkflow("rename", "", &testsuite.TestUpdateCallback{
+			OnAccept:   func() {},
+			OnReject:   func(err error) { require.Fail(t, "update rejected", err) },
+			OnComplete: func(interface{}, error) {},
+		}, "Audit")
+		env.UpdateWorkflow("rename", "", &testsuite.TestUpdateCallback{
+			OnAccept:   func() { require.Fail(t, "update accepted") },
+			OnReject:   func(err error) {},
+			OnComplete: func(interface{}, error) {},
+		}, "")
+		env.SignalWorkflow("done", nil)
+	}, time.Minute)
+	env.ExecuteWorkflow(auditedWorkflow, "Temporal")
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	var result string
+	require.NoError(t, env.GetWorkflowResult(&result))
+	require.Equal(t, "Hello Audit!", result)
+	close(sink)
+
+	var records []interceptor.AuditRecord
+	for record := range sink {
+		records = append(records, record)
+	}
+	find := func(kind interceptor.AuditKind, name string) []interceptor.AuditRecord {
+		var found []interceptor.AuditRecord
+		for _, record := range records {
+			if record.Kind == kind && record.Name == name {
+				found = append(found, record)
+			}
+		}
+		return found
+	}
+
+	started := find(interceptor.AuditWorkflowStarted, "auditedWorkflow")
+	require.Len(t, started, 1)
+	require.NotZero(t, started[0].InputBytes)
+	finished := find(interceptor.AuditWorkflowFinished, "auditedWorkflow")
+	require.Len(t, finished, 1)
+	require.GreaterOrEqual(t, finished[0].Duration, time.Minute)
+	require.NotZero(t, finished[0].ResultBytes)
+	require.Empty(t, finished[0].ErrorType)
+
+	flaky := find(interceptor.AuditActivity, "flakyActivity")
+	require.Len(t, flaky, 2)
+	require.Equal(t, int32(1), flaky[0].Attempt)
+	require.Equal(t, "Flaky", flaky[0].ErrorType)
+	require.Equal(t, int32(2), flaky[1].Attempt)
+	require.Empty(t, flaky[1].ErrorType)
+
+	require.Len(t, find(interceptor.AuditLocalActivity, "Activity"), 1)
+	require.Len(t, find(interceptor.AuditSignal, "done"), 1)
+	require.Len(t, find(interceptor.AuditQuery, "name"), 1)
+	updates := find(interceptor.AuditUpdate, "rename")
+	require.Len(t, updates, 1)
+	require.NotZero(t, updates[0].InputBytes)
+	rejected := find(interceptor.AuditUpdateRejected, "rename")
+	require.Len(t, rejected, 1)
+	require.Equal(t, "InvalidName", rejected[0].ErrorType)
+
+	children := find(interceptor.AuditChildWorkflow, "Workflow")
+	require.Len(t, children, 1)
+	require.Empty(t, children[0].ErrorType)
+	// The child workflow and its activity are recorded too
+	require.Len(t, find(interceptor.AuditWorkflowFinished, "Workflow"), 1)
+	require.Len(t, find(interceptor.AuditActivity, "Activity"), 1)
+
+	for _, record := range records {
+		require.NotEmpty(t, record.WorkflowID, "%+v", record)
+	}
+}
+
+func TestAuditInterceptor_ApplicationErrorWithoutType(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	sink := make(interceptor.ChannelAuditSink, 10)
+	env.SetWorkerOptions(worker.Options{
+		Interceptors: []sdkinterceptor.WorkerInterceptor{interceptor.NewAuditInterceptor(interceptor.AuditOptions{Sink: sink})},
+	})
+
+	env.ExecuteWorkflow(func(ctx workflow.Context) error {
+		return temporal.NewApplicationError("failed", "")
+	})
+	require.Error(t, env.GetWorkflowError())
+	close(sink)
+
+	var finished []interceptor.AuditRecord
+	for record := range sink {
+		if record.Kind == interceptor.AuditWorkflowFinished {
+			finished = append(finished, record)
+		}
+	}
+	require.Len(t, finished, 1)
+	require.Equal(t, "ApplicationError", finished[0].ErrorType)
+}
+
+// workflow_history.json is a history of Workflow, downloaded as described in helloworld/replay_test.go.
+func TestAuditInterceptor_Replay(t *testing.T) {
+	sink := make(interceptor.ChannelAuditSink, 100)
+	replayer, err := worker.NewWorkflowReplayerWithOptions(worker.WorkflowReplayerOptions{
+		Interceptors: []sdkinterceptor.WorkerInterceptor{interceptor.NewAuditInterceptor(interceptor.AuditOptions{
+			Sink:         sink,
+			PayloadSizes: true,
+		})},
+	})
+	require.NoError(t, err)
+	replayer.RegisterWorkflow(interceptor.Workflow)
+
+	require.NoError(t, replayer.ReplayWorkflowHistoryFromJSONFile(nil, "workflow_history.json"))
+	close(sink)
+
+	// The workflow was recorded when it ran, and activities are not run by the replayer
+	for record := range sink {
+		require.Fail(t, "unexpected record of the replayed workflow", "%+v", record)
+	}
+}
+
+func TestJSONLinesAuditSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := interceptor.NewJSONLinesAuditSink(&buf)
+	sink.Record(interceptor.AuditRecord{Kind: interceptor.AuditSignal, Name: "done", WorkflowID: "id"})
+	sink.Record(interceptor.AuditRecord{Kind: interceptor.AuditActivity, Name: "Activity", Attempt: 2, ErrorType: "Flaky"})
+
+	decoder := json.NewDecoder(&buf)
+	var record interceptor.AuditRecord
+	require.NoError(t, decoder.Decode(&record))
+	require.Equal(t, "done", record.Name)
+	require.NoError(t, decoder.Decode(&record))
+	require.Equal(t, interceptor.AuditRecord{Kind: interceptor.AuditActivity, Name: "Activity", Attempt: 2, ErrorType: "Flaky"}, record)
+	require.False(t, decoder.More())
+}
@@ -2,35 +2,87 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/temporalio/samples-go/interceptor"
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 	"go.temporal.io/sdk/activity"
 	"go.temporal.io/sdk/client"
 	sdkinterceptor "go.temporal.io/sdk/interceptor"
+	tlog "go.temporal.io/sdk/log"
 	"go.temporal.io/sdk/worker"
 	"go.temporal.io/sdk/workflow"
 )
 
 func main() {
+	var auditFile, activityOptionsFile string
+	flag.StringVar(&auditFile, "audit-file", "", "Write audit records as JSON lines to this file instead of the log.")
+	flag.StringVar(&activityOptionsFile, "activity-options", "", "YAML or JSON file overriding activity options, reloaded on SIGHUP. Optional.")
+	flag.Parse()
+
 	// The client and worker are heavyweight objects that should be created once per process.
-	c, err := client.Dial(client.Options{})
+	c, shutdown, err := bootstrap.Dial(client.Options{})
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
-	w := worker.New(c, "interceptor", worker.Options{
+	// Create audit interceptor that records the calls handled by the worker
+	var auditSink interceptor.AuditSink = &interceptor.LoggerAuditSink{Logger: tlog.NewStructuredLogger(slog.Default())}
+	if auditFile != "" {
+		f, err := os.OpenFile(auditFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatalln("Unable to open audit file", err)
+		}
+		defer func() { _ = f.Close() }()
+		auditSink = interceptor.NewJSONLinesAuditSink(f)
+	}
+
+	interceptors := []sdkinterceptor.WorkerInterceptor{
 		// Create interceptor that will put started time on the logger
-		Interceptors: []sdkinterceptor.WorkerInterceptor{interceptor.NewWorkerInterceptor(interceptor.InterceptorOptions{
+		interceptor.NewWorkerInterceptor(interceptor.InterceptorOptions{
 			GetExtraLogTagsForWorkflow: func(ctx workflow.Context) []interface{} {
 				return []interface{}{"WorkflowStartTime", workflow.GetInfo(ctx).WorkflowStartTime.Format(time.RFC3339)}
 			},
 			GetExtraLogTagsForActivity: func(ctx context.Context) []interface{} {
 				return []interface{}{"ActivityStartTime", activity.GetInfo(ctx).StartedTime.Format(time.RFC3339)}
 			},
-		})},
+		}),
+		interceptor.NewAuditInterceptor(interceptor.AuditOptions{Sink: auditSink, PayloadSizes: true}),
+	}
+
+	// Create interceptor that overrides activity options from the configuration file. It is installed even without
+	// configuration, as the histories of the workflows it ran don't replay without it.
+	var activityOptions interceptor.ActivityOptionsInterceptorOptions
+	if activityOptionsFile != "" {
+		config := &interceptor.ActivityOptionsConfigFile{Path: activityOptionsFile}
+		if err := config.Reload(); err != nil {
+			log.Fatalln("Unable to load activity options", err)
+		}
+		reload := make(chan os.Signal, 1)
+		signal.Notify(reload, syscall.SIGHUP)
+		go func() {
+			for range reload {
+				if err := config.Reload(); err != nil {
+					log.Println("Unable to reload activity options", err)
+				} else {
+					log.Println("Reloaded activity options", "Version", config.Config().Version)
+				}
+			}
+		}()
+		activityOptions.Config = config.Config
+	}
+	interceptors = append(interceptors, interceptor.NewActivityOptionsInterceptor(activityOptions))
+
+	w := worker.New(c, "interceptor", worker.Options{
+		Interceptors: interceptors,
 	})
 
 	w.RegisterWorkflow(interceptor.Workflow)
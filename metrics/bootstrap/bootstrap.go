@@ -0,0 +1,309 @@
+// Package bootstrap builds the client.MetricsHandler of a worker, backed by Prometheus or OpenTelemetry.
+//
+// Workers replace client.Dial by Dial, which selects the exporter with the environment variables:
+//
+//	c, shutdown, err := bootstrap.Dial(client.Options{})
+//	...
+//	defer shutdown()
+//	defer c.Close()
+//
+// Workers with a command line add the flags and pass the options to DialWithOptions instead:
+//
+//	var metricsOptions bootstrap.Options
+//	metricsOptions.AddFlags(flag.CommandLine)
+//	flag.Parse()
+//	c, shutdown, err := bootstrap.DialWithOptions(ctx, client.Options{}, metricsOptions)
+//
+// The shutdown function flushes the metrics, so a worker should return from main rather than exit with log.Fatal once
+// the client is created.
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/uber-go/tally/v4"
+	"github.com/uber-go/tally/v4/prometheus"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/contrib/opentelemetry"
+	sdktally "go.temporal.io/sdk/contrib/tally"
+)
+
+// The exporters of the metrics
+const (
+	ExporterNone       = "none"
+	ExporterPrometheus = "prometheus"
+	ExporterOTLP       = "otlp"
+	ExporterStdout     = "stdout"
+)
+
+// The environment variables used as defaults of the flags
+const (
+	ExporterEnv          = "TEMPORAL_METRICS_EXPORTER"
+	PrometheusAddressEnv = "TEMPORAL_METRICS_PROMETHEUS_ADDRESS"
+	OTLPEndpointEnv      = "TEMPORAL_METRICS_OTLP_ENDPOINT"
+)
+
+const (
+	DefaultPrometheusAddress = "0.0.0.0:9090"
+	DefaultPrefix            = "temporal_samples"
+	DefaultExportInterval    = 10 * time.Second
+)
+
+// DefaultBuckets are the histogram buckets of the timers, from the latency of an activity to the duration of a
+// workflow.
+var DefaultBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	10 * time.Minute,
+}
+
+// Options configure the metrics handler. Zero values are replaced by the defaults.
+type Options struct {
+	// Exporter is one of ExporterNone, ExporterPrometheus, ExporterOTLP or ExporterStdout. Defaults to ExporterNone.
+	Exporter string
+	// PrometheusAddress is the address serving the /metrics endpoint of the Prometheus exporter
+	PrometheusAddress string
+	// OTLPEndpoint is the URL of the OTLP gRPC collector, like http://localhost:4317. Defaults to the
+	// OTEL_EXPORTER_OTLP_ENDPOINT environment variable of the OpenTelemetry SDK.
+	OTLPEndpoint string
+	// Prefix is prepended to the metric names, separated by an underscore
+	Prefix string
+	// Buckets are the histogram buckets of the timers, with every exporter
+	Buckets []time.Duration
+	// ExportInterval is the interval between exports of the OTLP and stdout exporters
+	ExportInterval time.Duration
+	// Writer receives the metrics of the stdout exporter. Defaults to os.Stdout.
+	Writer io.Writer
+}
+
+// FromEnv returns the options set by the environment variables
+func FromEnv() Options {
+	return Options{
+		Exporter:          os.Getenv(ExporterEnv),
+		PrometheusAddress: os.Getenv(PrometheusAddressEnv),
+		OTLPEndpoint:      os.Getenv(OTLPEndpointEnv),
+	}
+}
+
+// AddFlags adds the -metrics, -metrics-prometheus-address and -metrics-otlp-endpoint flags setting the options to the
+// flag set. Flags which are not given default to their environment variable, and then to the value of the option.
+func (o *Options) AddFlags(set *flag.FlagSet) {
+	set.StringVar(&o.Exporter, "metrics", envOr(ExporterEnv, o.Exporter),
+		fmt.Sprintf("Metrics exporter: %s, %s, %s or %s (env %s)",
+			ExporterNone, ExporterPrometheus, ExporterOTLP, ExporterStdout, ExporterEnv))
+	set.StringVar(&o.PrometheusAddress, "metrics-prometheus-address", envOr(PrometheusAddressEnv, o.PrometheusAddress),
+		fmt.Sprintf("Address of the Prometheus /metrics endpoint, %s by default (env %s)",
+			DefaultPrometheusAddress, PrometheusAddressEnv))
+	set.StringVar(&o.OTLPEndpoint, "metrics-otlp-endpoint", envOr(OTLPEndpointEnv, o.OTLPEndpoint),
+		fmt.Sprintf("URL of the OTLP gRPC collector (env %s or OTEL_EXPORTER_OTLP_ENDPOINT)", OTLPEndpointEnv))
+}
+
+// NewMetricsHandler creates the metrics handler of the exporter. The shutdown function flushes the metrics and stops
+// the exporter, it must be called once the client is closed.
+func NewMetricsHandler(
+	ctx context.Context,
+	options Options,
+) (handler client.MetricsHandler, shutdown func(context.Context) error, err error) {
+	options.setDefaults()
+	switch options.Exporter {
+	case ExporterNone:
+		return client.MetricsNopHandler, func(context.Context) error { return nil }, nil
+	case ExporterPrometheus:
+		return newPrometheusHandler(options)
+	case ExporterOTLP:
+		var exporterOptions []otlpmetricgrpc.Option
+		if options.OTLPEndpoint != "" {
+			exporterOptions = append(exporterOptions, otlpmetricgrpc.WithEndpointURL(options.OTLPEndpoint))
+		}
+		exporter, err := otlpmetricgrpc.New(ctx, exporterOptions...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to create OTLP exporter: %w", err)
+		}
+		return newOpenTelemetryHandler(options, exporter)
+	case ExporterStdout:
+		exporter, err := stdoutmetric.New(stdoutmetric.WithWriter(options.Writer))
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to create stdout exporter: %w", err)
+		}
+		return newOpenTelemetryHandler(options, exporter)
+	default:
+		return nil, nil, fmt.Errorf("unknown metrics exporter %q", options.Exporter)
+	}
+}
+
+func (o *Options) setDefaults() {
+	o.Exporter = strings.ToLower(o.Exporter)
+	if o.Exporter == "" {
+		o.Exporter = ExporterNone
+	}
+	if o.PrometheusAddress == "" {
+		o.PrometheusAddress = DefaultPrometheusAddress
+	}
+	if o.Prefix == "" {
+		o.Prefix = DefaultPrefix
+	}
+	if len(o.Buckets) == 0 {
+		o.Buckets = DefaultBuckets
+	}
+	if o.ExportInterval <= 0 {
+		o.ExportInterval = DefaultExportInterval
+	}
+	if o.Writer == nil {
+		o.Writer = os.Stdout
+	}
+}
+
+// Dial creates a client exporting its metrics as configured by the environment variables, with the exporter none by
+// default. See DialWithOptions.
+func Dial(options client.Options) (c client.Client, shutdown func(), err error) {
+	return DialWithOptions(context.Background(), options, FromEnv())
+}
+
+// DialWithOptions creates a client with the metrics handler of metricsOptions, unless options already has a handler.
+// The shutdown function flushes the metrics and stops the exporter, it must be deferred before closing the client so
+// that it runs after the client is closed. The client is the one of client.Dial, as worker.New requires.
+func DialWithOptions(
+	ctx context.Context,
+	options client.Options,
+	metricsOptions Options,
+) (c client.Client, shutdown func(), err error) {
+	shutdownHandler := func(context.Context) error { return nil }
+	if options.MetricsHandler == nil {
+		if options.MetricsHandler, shutdownHandler, err = NewMetricsHandler(ctx, metricsOptions); err != nil {
+			return nil, nil, err
+		}
+	}
+	if c, err = client.Dial(options); err != nil {
+		return nil, nil, errors.Join(err, shutdownHandler(ctx))
+	}
+	return c, func() {
+		if err := shutdownHandler(context.Background()); err != nil {
+			log.Println("unable to shut down metrics handler", err)
+		}
+	}, nil
+}
+
+// newPrometheusHandler creates a tally handler reporting to Prometheus, and serves its /metrics endpoint
+func newPrometheusHandler(options Options) (client.MetricsHandler, func(context.Context) error, error) {
+	registry := prom.NewRegistry()
+	reporter := prometheus.NewReporter(prometheus.Options{
+		Registerer:              registry,
+		DefaultTimerType:        prometheus.HistogramTimerType,
+		DefaultHistogramBuckets: seconds(options.Buckets),
+		OnRegisterError: func(err error) {
+			log.Println("error in prometheus reporter", err)
+		},
+	})
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+		CachedReporter:  reporter,
+		Separator:       prometheus.DefaultSeparator,
+		SanitizeOptions: &sdktally.PrometheusSanitizeOptions,
+		Prefix:          options.Prefix,
+	}, time.Second)
+	scope = sdktally.NewPrometheusNamingScope(scope)
+
+	// Listen before returning, so that an address in use fails the worker start
+	listener, err := net.Listen("tcp", options.PrometheusAddress)
+	if err != nil {
+		_ = closer.Close()
+		return nil, nil, fmt.Errorf("unable to listen on %s: %w", options.PrometheusAddress, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", reporter.HTTPHandler())
+	server := &http.Server{Handler: mux}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Println("error serving prometheus metrics", err)
+		}
+	}()
+	log.Printf("prometheus metrics served at http://%s/metrics", listener.Addr())
+
+	return sdktally.NewMetricsHandler(scope), func(ctx context.Context) error {
+		return errors.Join(closer.Close(), server.Shutdown(ctx))
+	}, nil
+}
+
+// newOpenTelemetryHandler creates an OpenTelemetry handler periodically exporting to the exporter
+func newOpenTelemetryHandler(
+	options Options,
+	exporter sdkmetric.Exporter,
+) (client.MetricsHandler, func(context.Context) error, error) {
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(options.ExportInterval))),
+		sdkmetric.WithView(sdkmetric.NewView(
+			sdkmetric.Instrument{Kind: sdkmetric.InstrumentKindHistogram},
+			sdkmetric.Stream{Aggregation: sdkmetric.AggregationExplicitBucketHistogram{Boundaries: seconds(options.Buckets)}},
+		)),
+	)
+	handler := opentelemetry.NewMetricsHandler(opentelemetry.MetricsHandlerOptions{
+		Meter: provider.Meter("github.com/temporalio/samples-go"),
+		OnError: func(err error) {
+			log.Println("error in opentelemetry metrics handler", err)
+		},
+	})
+	return prefixHandler{MetricsHandler: handler, prefix: options.Prefix + "_"}, provider.Shutdown, nil
+}
+
+// prefixHandler prepends the prefix to the metric names, as the tally scope does for Prometheus
+type prefixHandler struct {
+	client.MetricsHandler
+	prefix string
+}
+
+func (h prefixHandler) WithTags(tags map[string]string) client.MetricsHandler {
+	return prefixHandler{MetricsHandler: h.MetricsHandler.WithTags(tags), prefix: h.prefix}
+}
+
+func (h prefixHandler) Counter(name string) client.MetricsCounter {
+	return h.MetricsHandler.Counter(h.prefix + name)
+}
+
+func (h prefixHandler) Gauge(name string) client.MetricsGauge {
+	return h.MetricsHandler.Gauge(h.prefix + name)
+}
+
+func (h prefixHandler) Timer(name string) client.MetricsTimer {
+	return h.MetricsHandler.Timer(h.prefix + name)
+}
+
+// seconds converts the buckets to seconds, the unit of the timers of both Prometheus and OpenTelemetry
+func seconds(buckets []time.Duration) []float64 {
+	result := make([]float64, len(buckets))
+	for i, bucket := range buckets {
+		result[i] = bucket.Seconds()
+	}
+	return result
+}
+
+func envOr(name, value string) string {
+	if env := os.Getenv(name); env != "" {
+		return env
+	}
+	return value
+}
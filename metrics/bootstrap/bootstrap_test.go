@@ -0,0 +1,160 @@
+package bootstrap_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/worker"
+	"google.golang.org/grpc"
+
+	"github.com/temporalio/samples-go/metrics/bootstrap"
+)
+
+func TestAddFlags(t *testing.T) {
+	t.Setenv(bootstrap.ExporterEnv, "otlp")
+	t.Setenv(bootstrap.OTLPEndpointEnv, "http://collector:4317")
+
+	// Flags override the environment, which overrides the options
+	options := bootstrap.Options{Exporter: bootstrap.ExporterPrometheus, PrometheusAddress: "localhost:9091"}
+	set := flag.NewFlagSet("worker", flag.ContinueOnError)
+	options.AddFlags(set)
+	require.NoError(t, set.Parse([]string{"-metrics-otlp-endpoint", "http://localhost:4317"}))
+	require.Equal(t, bootstrap.Options{
+		Exporter:          bootstrap.ExporterOTLP,
+		PrometheusAddress: "localhost:9091",
+		OTLPEndpoint:      "http://localhost:4317",
+	}, options)
+}
+
+func TestNewMetricsHandler(t *testing.T) {
+	handler, shutdown, err := bootstrap.NewMetricsHandler(context.Background(), bootstrap.Options{})
+	require.NoError(t, err)
+	require.Equal(t, client.MetricsNopHandler, handler)
+	require.NoError(t, shutdown(context.Background()))
+
+	_, _, err = bootstrap.NewMetricsHandler(context.Background(), bootstrap.Options{Exporter: "statsd"})
+	require.ErrorContains(t, err, `unknown metrics exporter "statsd"`)
+}
+
+func TestPrometheus(t *testing.T) {
+	address := freeAddress(t)
+	handler, shutdown, err := bootstrap.NewMetricsHandler(context.Background(), bootstrap.Options{
+		Exporter:          bootstrap.ExporterPrometheus,
+		PrometheusAddress: address,
+		Buckets:           []time.Duration{time.Second, time.Minute},
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, shutdown(context.Background())) }()
+
+	handler.WithTags(map[string]string{"workflow_type": "Workflow"}).Timer("workflow_latency").Record(2 * time.Second)
+	handler.Counter("workflow_succeeded").Inc(1)
+
+	// The scope reports every second
+	require.Eventually(t, func() bool {
+		body := scrape(t, "http://"+address+"/metrics")
+		return strings.Contains(body, `temporal_samples_workflow_latency_seconds_bucket{workflow_type="Workflow",le="1"} 0`) &&
+			strings.Contains(body, `temporal_samples_workflow_latency_seconds_bucket{workflow_type="Workflow",le="60"} 1`) &&
+			strings.Contains(body, `temporal_samples_workflow_succeeded_total 1`)
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// The address is in use until the shutdown
+	_, _, err = bootstrap.NewMetricsHandler(context.Background(), bootstrap.Options{
+		Exporter:          bootstrap.ExporterPrometheus,
+		PrometheusAddress: address,
+	})
+	require.Error(t, err)
+}
+
+func TestStdout(t *testing.T) {
+	var out bytes.Buffer
+	handler, shutdown, err := bootstrap.NewMetricsHandler(context.Background(), bootstrap.Options{
+		Exporter: bootstrap.ExporterStdout,
+		Prefix:   "sample",
+		Buckets:  []time.Duration{500 * time.Millisecond, time.Second, time.Minute},
+		Writer:   &out,
+	})
+	require.NoError(t, err)
+
+	handler.WithTags(map[string]string{"workflow_type": "Workflow"}).Timer("workflow_latency").Record(2 * time.Second)
+	handler.Counter("workflow_succeeded").Inc(1)
+	// The shutdown exports the metrics recorded since the last interval
+	require.NoError(t, shutdown(context.Background()))
+
+	var exported struct {
+		ScopeMetrics []struct {
+			Metrics []struct {
+				Name string
+				Data struct {
+					DataPoints []struct {
+						Bounds       []float64
+						BucketCounts []uint64
+					}
+				}
+			}
+		}
+	}
+	require.NoError(t, json.NewDecoder(&out).Decode(&exported))
+	require.Len(t, exported.ScopeMetrics, 1)
+	bounds := map[string][]float64{}
+	for _, metric := range exported.ScopeMetrics[0].Metrics {
+		require.Len(t, metric.Data.DataPoints, 1, metric.Name)
+		bounds[metric.Name] = metric.Data.DataPoints[0].Bounds
+		// 2s falls in the (1, 60] bucket
+		if metric.Name == "sample_workflow_latency" {
+			require.Equal(t, []uint64{0, 0, 1, 0}, metric.Data.DataPoints[0].BucketCounts)
+		}
+	}
+	require.Equal(t, map[string][]float64{
+		"sample_workflow_latency":   {0.5, 1, 60},
+		"sample_workflow_succeeded": nil,
+	}, bounds)
+}
+
+func TestDialWithOptions(t *testing.T) {
+	_, _, err := bootstrap.DialWithOptions(context.Background(), client.Options{}, bootstrap.Options{Exporter: "statsd"})
+	require.ErrorContains(t, err, `unknown metrics exporter "statsd"`)
+
+	// The dial only gets the system info of the server, which may be unimplemented
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	for _, exporter := range []string{bootstrap.ExporterNone, bootstrap.ExporterStdout} {
+		c, shutdown, err := bootstrap.DialWithOptions(context.Background(), client.Options{
+			HostPort: listener.Addr().String(),
+		}, bootstrap.Options{Exporter: exporter, Writer: io.Discard})
+		require.NoError(t, err, exporter)
+		// worker.New panics unless the client is the one of client.Dial
+		require.NotPanics(t, func() { worker.New(c, "bootstrap", worker.Options{}) }, exporter)
+		c.Close()
+		shutdown()
+	}
+}
+
+func freeAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func scrape(t *testing.T, url string) string {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
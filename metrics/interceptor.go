@@ -0,0 +1,255 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+const (
+	// workflowStartToFirstTaskLatency is the time from the start of a run to the first workflow task running its
+	// code, recorded once per run. The latencies of every workflow task are the workflow_task_* metrics of the SDK.
+	workflowStartToFirstTaskLatency = "workflow_start_to_first_task_latency"
+	workflowLatency                 = "workflow_latency"
+	workflowSuccessCount            = "workflow_succeeded"
+	workflowFailedCount             = "workflow_failed"
+	workflowContinuedAsNewCount     = "workflow_continued_as_new"
+
+	signalCount       = "workflow_signal_received"
+	updateCount       = "workflow_update_handled"
+	updateFailedCount = "workflow_update_failed"
+	queryCount        = "workflow_query_handled"
+
+	localActivityLatency      = "local_activity_latency"
+	localActivitySuccessCount = "local_activity_succeeded"
+	localActivityFailedCount  = "local_activity_failed"
+
+	childWorkflowLatency      = "child_workflow_latency"
+	childWorkflowSuccessCount = "child_workflow_succeeded"
+	childWorkflowFailedCount  = "child_workflow_failed"
+
+	nexusOperationLatency      = "nexus_operation_latency"
+	nexusOperationSuccessCount = "nexus_operation_succeeded"
+	nexusOperationFailedCount  = "nexus_operation_failed"
+)
+
+// NewWorkerInterceptor creates an interceptor emitting metrics of the workflows, activities, local activities, child
+// workflows and Nexus operations of a worker, tagged with the workflow type, task queue and namespace. Failures are
+// tagged with their error type.
+//
+// Workflow metrics go through workflow.GetMetricsHandler, so they are not emitted again when a workflow is replayed.
+func NewWorkerInterceptor() interceptor.WorkerInterceptor {
+	return &workerInterceptor{}
+}
+
+type workerInterceptor struct {
+	interceptor.WorkerInterceptorBase
+}
+
+func (w *workerInterceptor) InterceptActivity(
+	ctx context.Context,
+	next interceptor.ActivityInboundInterceptor,
+) interceptor.ActivityInboundInterceptor {
+	i := &activityInboundInterceptor{}
+	i.Next = next
+	return i
+}
+
+type activityInboundInterceptor struct {
+	interceptor.ActivityInboundInterceptorBase
+}
+
+func (a *activityInboundInterceptor) ExecuteActivity(
+	ctx context.Context,
+	in *interceptor.ExecuteActivityInput,
+) (interface{}, error) {
+	info := activity.GetInfo(ctx)
+	tags := map[string]string{
+		"activity_type": info.ActivityType.Name,
+		"task_queue":    info.TaskQueue,
+		"namespace":     info.WorkflowNamespace,
+	}
+	if info.WorkflowType != nil {
+		tags["workflow_type"] = info.WorkflowType.Name
+	}
+	handler := activity.GetMetricsHandler(ctx).WithTags(tags)
+
+	if info.IsLocalActivity {
+		startTime := time.Now()
+		result, err := a.Next.ExecuteActivity(ctx, in)
+		handler.Timer(localActivityLatency).Record(time.Since(startTime))
+		recordOutcome(handler, localActivitySuccessCount, localActivityFailedCount, err)
+		return result, err
+	}
+
+	handler = recordActivityStart(handler, info.ScheduledTime)
+	startTime := time.Now()
+	result, err := a.Next.ExecuteActivity(ctx, in)
+	recordActivityEnd(handler, startTime, err)
+	return result, err
+}
+
+func (w *workerInterceptor) InterceptWorkflow(
+	ctx workflow.Context,
+	next interceptor.WorkflowInboundInterceptor,
+) interceptor.WorkflowInboundInterceptor {
+	i := &workflowInboundInterceptor{}
+	i.Next = next
+	return i
+}
+
+type workflowInboundInterceptor struct {
+	interceptor.WorkflowInboundInterceptorBase
+}
+
+func (w *workflowInboundInterceptor) Init(outbound interceptor.WorkflowOutboundInterceptor) error {
+	i := &workflowOutboundInterceptor{}
+	i.Next = outbound
+	return w.Next.Init(i)
+}
+
+func (w *workflowInboundInterceptor) ExecuteWorkflow(
+	ctx workflow.Context,
+	in *interceptor.ExecuteWorkflowInput,
+) (interface{}, error) {
+	info := workflow.GetInfo(ctx)
+	handler := workflowMetricsHandler(ctx)
+	if !workflow.IsReplaying(ctx) {
+		// Wall clock time is fine here, as metrics do not affect the workflow
+		handler.Timer(workflowStartToFirstTaskLatency).Record(time.Since(info.WorkflowStartTime))
+	}
+
+	result, err := w.Next.ExecuteWorkflow(ctx, in)
+
+	handler.Timer(workflowLatency).Record(workflow.Now(ctx).Sub(info.WorkflowStartTime))
+	var continueAsNewErr *workflow.ContinueAsNewError
+	if errors.As(err, &continueAsNewErr) {
+		handler.Counter(workflowContinuedAsNewCount).Inc(1)
+	} else {
+		recordOutcome(handler, workflowSuccessCount, workflowFailedCount, err)
+	}
+	return result, err
+}
+
+func (w *workflowInboundInterceptor) HandleSignal(ctx workflow.Context, in *interceptor.HandleSignalInput) error {
+	workflowMetricsHandler(ctx).WithTags(map[string]string{"signal_name": in.SignalName}).Counter(signalCount).Inc(1)
+	return w.Next.HandleSignal(ctx, in)
+}
+
+func (w *workflowInboundInterceptor) ExecuteUpdate(
+	ctx workflow.Context,
+	in *interceptor.UpdateInput,
+) (interface{}, error) {
+	result, err := w.Next.ExecuteUpdate(ctx, in)
+	handler := workflowMetricsHandler(ctx).WithTags(map[string]string{"update_name": in.Name})
+	recordOutcome(handler, updateCount, updateFailedCount, err)
+	return result, err
+}
+
+func (w *workflowInboundInterceptor) HandleQuery(
+	ctx workflow.Context,
+	in *interceptor.HandleQueryInput,
+) (interface{}, error) {
+	workflowMetricsHandler(ctx).WithTags(map[string]string{"query_type": in.QueryType}).Counter(queryCount).Inc(1)
+	return w.Next.HandleQuery(ctx, in)
+}
+
+type workflowOutboundInterceptor struct {
+	interceptor.WorkflowOutboundInterceptorBase
+}
+
+func (w *workflowOutboundInterceptor) ExecuteChildWorkflow(
+	ctx workflow.Context,
+	childWorkflowType string,
+	args ...interface{},
+) workflow.ChildWorkflowFuture {
+	handler := workflowMetricsHandler(ctx).WithTags(map[string]string{"child_workflow_type": childWorkflowType})
+	startTime := workflow.Now(ctx)
+	future := w.Next.ExecuteChildWorkflow(ctx, childWorkflowType, args...)
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		err := future.Get(ctx, nil)
+		handler.Timer(childWorkflowLatency).Record(workflow.Now(ctx).Sub(startTime))
+		recordOutcome(handler, childWorkflowSuccessCount, childWorkflowFailedCount, err)
+	})
+	return future
+}
+
+func (w *workflowOutboundInterceptor) ExecuteNexusOperation(
+	ctx workflow.Context,
+	input interceptor.ExecuteNexusOperationInput,
+) workflow.NexusOperationFuture {
+	handler := workflowMetricsHandler(ctx).WithTags(map[string]string{
+		"nexus_endpoint":  input.Client.Endpoint(),
+		"nexus_service":   input.Client.Service(),
+		"nexus_operation": operationName(input.Operation),
+	})
+	startTime := workflow.Now(ctx)
+	future := w.Next.ExecuteNexusOperation(ctx, input)
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		err := future.Get(ctx, nil)
+		handler.Timer(nexusOperationLatency).Record(workflow.Now(ctx).Sub(startTime))
+		recordOutcome(handler, nexusOperationSuccessCount, nexusOperationFailedCount, err)
+	})
+	return future
+}
+
+// workflowMetricsHandler returns the metrics handler of the workflow with the workflow tags
+func workflowMetricsHandler(ctx workflow.Context) client.MetricsHandler {
+	info := workflow.GetInfo(ctx)
+	return workflow.GetMetricsHandler(ctx).WithTags(map[string]string{
+		"workflow_type": info.WorkflowType.Name,
+		"task_queue":    info.TaskQueueName,
+		"namespace":     info.Namespace,
+	})
+}
+
+// recordOutcome increments the success counter, or the failure counter tagged with the error type
+func recordOutcome(handler client.MetricsHandler, successCount, failedCount string, err error) {
+	if err != nil {
+		handler.WithTags(map[string]string{"error_type": errorType(err)}).Counter(failedCount).Inc(1)
+		return
+	}
+	handler.Counter(successCount).Inc(1)
+}
+
+// errorType returns the error_type tag of a failure: the type of an ApplicationError, "ApplicationError" when it has
+// none, or the kind of other errors. The audit interceptor of the interceptor sample uses the same values.
+func errorType(err error) string {
+	var applicationErr *temporal.ApplicationError
+	var canceledErr *temporal.CanceledError
+	var timeoutErr *temporal.TimeoutError
+	var terminatedErr *temporal.TerminatedError
+	switch {
+	case errors.As(err, &applicationErr) && applicationErr.Type() != "":
+		return applicationErr.Type()
+	case applicationErr != nil:
+		return "ApplicationError"
+	case errors.As(err, &canceledErr):
+		return "Canceled"
+	case errors.As(err, &timeoutErr):
+		return "Timeout"
+	case errors.As(err, &terminatedErr):
+		return "Terminated"
+	default:
+		return fmt.Sprintf("%T", err)
+	}
+}
+
+// operationName returns the name of a Nexus operation given by name or reference
+func operationName(operation interface{}) string {
+	switch op := operation.(type) {
+	case string:
+		return op
+	case interface{ Name() string }:
+		return op.Name()
+	default:
+		return fmt.Sprintf("%v", op)
+	}
+}
@@ -0,0 +1,240 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nexus-rpc/sdk-go/nexus"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/temporalnexus"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/temporalio/samples-go/metrics"
+)
+
+var echoOperation = temporalnexus.NewSyncOperation("echo", func(ctx context.Context, c client.Client, input string, options nexus.StartOperationOptions) (string, error) {
+	return input, nil
+})
+
+func failingActivity(ctx context.Context) error {
+	return temporal.NewNonRetryableApplicationError("failure", "Failing", nil)
+}
+
+func childWorkflow(ctx workflow.Context) error {
+	return errors.New("child failure")
+}
+
+// instrumentedWorkflow makes every kind of call measured by the interceptor.
+func instrumentedWorkflow(ctx workflow.Context) error {
+	if err := workflow.SetQueryHandler(ctx, "state", func() (string, error) { return "running", nil }); err != nil {
+		return err
+	}
+	if err := workflow.SetUpdateHandler(ctx, "update", func(ctx workflow.Context) error { return nil }); err != nil {
+		return err
+	}
+
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{StartToCloseTimeout: 10 * time.Second})
+	_ = workflow.ExecuteActivity(ctx, failingActivity).Get(ctx, nil)
+	ctx = workflow.WithLocalActivityOptions(ctx, workflow.LocalActivityOptions{StartToCloseTimeout: 10 * time.Second})
+	if err := workflow.ExecuteLocalActivity(ctx, metrics.LocalActivity).Get(ctx, nil); err != nil {
+		return err
+	}
+	_ = workflow.ExecuteChildWorkflow(ctx, childWorkflow).Get(ctx, nil)
+
+	c := workflow.NewNexusClient("endpoint", "service")
+	if err := c.ExecuteOperation(ctx, echoOperation, "hello", workflow.NexusOperationOptions{}).Get(ctx, nil); err != nil {
+		return err
+	}
+
+	workflow.GetSignalChannel(ctx, "done").Receive(ctx, nil)
+	return nil
+}
+
+func TestWorkerInterceptor(t *testing.T) {
+	handler := newCapturingHandler()
+	var suite testsuite.WorkflowTestSuite
+	suite.SetMetricsHandler(handler)
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(instrumentedWorkflow)
+	env.RegisterWorkflow(childWorkflow)
+	env.RegisterActivity(failingActivity)
+	env.RegisterActivity(metrics.LocalActivity)
+	service := nexus.NewService("service")
+	require.NoError(t, service.Register(echoOperation))
+	env.RegisterNexusService(service)
+	env.SetWorkerOptions(worker.Options{
+		Interceptors: []interceptor.WorkerInterceptor{metrics.NewWorkerInterceptor()},
+	})
+
+	env.RegisterDelayedCallback(func() {
+		_, err := env.QueryWorkflow("state")
+		require.NoError(t, err)
+		env.UpdateWorkflow("update", "", &testsuite.TestUpdateCallback{
+			OnAccept:   func() {},
+			OnReject:   func(err error) { require.Fail(t, "update rejected", err) },
+			OnComplete: func(interface{}, error) {},
+		})
+		env.SignalWorkflow("done", nil)
+	}, time.Minute)
+	env.ExecuteWorkflow(instrumentedWorkflow)
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	workflowTags := map[string]string{
+		"workflow_type": "instrumentedWorkflow",
+		"task_queue":    "default-test-taskqueue",
+		"namespace":     "default-test-namespace",
+	}
+	with := func(tags map[string]string) map[string]string {
+		result := map[string]string{}
+		for k, v := range workflowTags {
+			result[k] = v
+		}
+		for k, v := range tags {
+			result[k] = v
+		}
+		return result
+	}
+
+	require.Len(t, handler.find("workflow_start_to_first_task_latency", workflowTags), 1)
+	latency := handler.find("workflow_latency", workflowTags)
+	require.Len(t, latency, 1)
+	require.GreaterOrEqual(t, latency[0].value, float64(time.Minute))
+	require.Equal(t, 1.0, handler.sum("workflow_succeeded", workflowTags))
+	require.Equal(t, 1.0, handler.sum("workflow_signal_received", with(map[string]string{"signal_name": "done"})))
+	require.Equal(t, 1.0, handler.sum("workflow_update_handled", with(map[string]string{"update_name": "update"})))
+	require.Equal(t, 1.0, handler.sum("workflow_query_handled", with(map[string]string{"query_type": "state"})))
+
+	activityTags := with(map[string]string{"activity_type": "failingActivity"})
+	require.Len(t, handler.find("schedule_to_start_latency", activityTags), 1)
+	require.Len(t, handler.find("activity_latency", activityTags), 1)
+	require.Equal(t, 1.0, handler.sum("activity_started", activityTags))
+	require.Equal(t, 1.0, handler.sum("activity_failed", with(map[string]string{
+		"activity_type": "failingActivity",
+		"error_type":    "Failing",
+	})))
+
+	localActivityTags := with(map[string]string{"activity_type": "LocalActivity"})
+	require.Len(t, handler.find("local_activity_latency", localActivityTags), 1)
+	require.Equal(t, 1.0, handler.sum("local_activity_succeeded", localActivityTags))
+	require.Empty(t, handler.find("activity_latency", localActivityTags))
+
+	require.Len(t, handler.find("child_workflow_latency", with(map[string]string{"child_workflow_type": "childWorkflow"})), 1)
+	require.Equal(t, 1.0, handler.sum("child_workflow_failed", with(map[string]string{
+		"child_workflow_type": "childWorkflow",
+		"error_type":          "ApplicationError",
+	})))
+	require.Equal(t, 1.0, handler.sum("workflow_failed", map[string]string{
+		"workflow_type": "childWorkflow",
+		"task_queue":    "default-test-taskqueue",
+		"namespace":     "default-test-namespace",
+		"error_type":    "*errors.errorString",
+	}))
+
+	nexusTags := with(map[string]string{
+		"nexus_endpoint":  "endpoint",
+		"nexus_service":   "service",
+		"nexus_operation": "echo",
+	})
+	require.Len(t, handler.find("nexus_operation_latency", nexusTags), 1)
+	require.Equal(t, 1.0, handler.sum("nexus_operation_succeeded", nexusTags))
+}
+
+// capturedMetric is a value recorded by the capturingHandler.
+type capturedMetric struct {
+	name  string
+	tags  map[string]string
+	value float64
+}
+
+// capturingHandler is a client.MetricsHandler keeping the recorded values in memory.
+type capturingHandler struct {
+	tags    map[string]string
+	mu      *sync.Mutex
+	metrics *[]capturedMetric
+}
+
+func newCapturingHandler() *capturingHandler {
+	return &capturingHandler{tags: map[string]string{}, mu: &sync.Mutex{}, metrics: &[]capturedMetric{}}
+}
+
+func (h *capturingHandler) WithTags(tags map[string]string) client.MetricsHandler {
+	merged := map[string]string{}
+	for k, v := range h.tags {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return &capturingHandler{tags: merged, mu: h.mu, metrics: h.metrics}
+}
+
+func (h *capturingHandler) Counter(name string) client.MetricsCounter {
+	return counterFunc(func(v int64) { h.record(name, float64(v)) })
+}
+
+func (h *capturingHandler) Gauge(name string) client.MetricsGauge {
+	return gaugeFunc(func(v float64) { h.record(name, v) })
+}
+
+func (h *capturingHandler) Timer(name string) client.MetricsTimer {
+	return timerFunc(func(d time.Duration) { h.record(name, float64(d)) })
+}
+
+type (
+	counterFunc func(int64)
+	gaugeFunc   func(float64)
+	timerFunc   func(time.Duration)
+)
+
+func (f counterFunc) Inc(v int64)          { f(v) }
+func (f gaugeFunc) Update(v float64)       { f(v) }
+func (f timerFunc) Record(d time.Duration) { f(d) }
+
+func (h *capturingHandler) record(name string, value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	*h.metrics = append(*h.metrics, capturedMetric{name: name, tags: h.tags, value: value})
+}
+
+// find returns the values recorded for the metric with exactly the tags
+func (h *capturingHandler) find(name string, tags map[string]string) []capturedMetric {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var found []capturedMetric
+	for _, metric := range *h.metrics {
+		if metric.name == name && equalTags(metric.tags, tags) {
+			found = append(found, metric)
+		}
+	}
+	return found
+}
+
+// sum returns the sum of the values recorded for the metric with exactly the tags
+func (h *capturingHandler) sum(name string, tags map[string]string) float64 {
+	var sum float64
+	for _, metric := range h.find(name, tags) {
+		sum += metric.value
+	}
+	return sum
+}
+
+func equalTags(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
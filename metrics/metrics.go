@@ -15,14 +15,12 @@ const (
 	activitySuccessCount = "activity_succeeded"
 )
 
+// recordActivityStart emits metrics at the start of an activity function
 func recordActivityStart(
 	handler client.MetricsHandler,
-	activityType string,
-	scheduledTimeNanos int64,
+	scheduledTime time.Time,
 ) client.MetricsHandler {
-	handler = handler.WithTags(map[string]string{"operation": activityType})
-	scheduleToStartDuration := time.Duration(time.Now().UnixNano() - scheduledTimeNanos)
-	handler.Timer(scheduleToStartLatency).Record(scheduleToStartDuration)
+	handler.Timer(scheduleToStartLatency).Record(time.Since(scheduledTime))
 	handler.Counter(activityStartedCount).Inc(1)
 	return handler
 }
@@ -30,9 +28,5 @@ func recordActivityStart(
 // recordActivityEnd emits metrics at the end of an activity function
 func recordActivityEnd(handler client.MetricsHandler, startTime time.Time, err error) {
 	handler.Timer(activityLatency).Record(time.Since(startTime))
-	if err != nil {
-		handler.Counter(activityFailedCount).Inc(1)
-		return
-	}
-	handler.Counter(activitySuccessCount).Inc(1)
+	recordOutcome(handler, activitySuccessCount, activityFailedCount, err)
 }
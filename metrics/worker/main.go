@@ -1,64 +1,49 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
-	"time"
 
-	prom "github.com/prometheus/client_golang/prometheus"
-	"github.com/uber-go/tally/v4"
-	"github.com/uber-go/tally/v4/prometheus"
 	"go.temporal.io/sdk/client"
-	sdktally "go.temporal.io/sdk/contrib/tally"
+	"go.temporal.io/sdk/interceptor"
 	"go.temporal.io/sdk/worker"
 
 	"github.com/temporalio/samples-go/metrics"
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 )
 
 func main() {
+	metricsOptions := bootstrap.Options{Exporter: bootstrap.ExporterPrometheus}
+	metricsOptions.AddFlags(flag.CommandLine)
+	flag.Parse()
+
+	if err := run(metricsOptions); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// run returns its errors instead of exiting, so that the deferred shutdown flushes the metrics
+func run(metricsOptions bootstrap.Options) error {
 	// The client and worker are heavyweight objects that should be created once per process.
-	c, err := client.Dial(client.Options{
-		MetricsHandler: sdktally.NewMetricsHandler(newPrometheusScope(prometheus.Configuration{
-			ListenAddress: "0.0.0.0:9090",
-			TimerType:     "histogram",
-		})),
-	})
+	c, shutdown, err := bootstrap.DialWithOptions(context.Background(), client.Options{}, metricsOptions)
 	if err != nil {
-		log.Fatalln("Unable to create client", err)
+		return fmt.Errorf("unable to create client: %w", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
-	w := worker.New(c, "metrics", worker.Options{})
+	w := worker.New(c, "metrics", worker.Options{
+		Interceptors: []interceptor.WorkerInterceptor{metrics.NewWorkerInterceptor()},
+	})
 
 	w.RegisterWorkflow(metrics.Workflow)
 	w.RegisterActivity(metrics.Activity)
+	w.RegisterActivity(metrics.LocalActivity)
 
-	err = w.Run(worker.InterruptCh())
-	if err != nil {
-		log.Fatalln("Unable to start worker", err)
+	if err := w.Run(worker.InterruptCh()); err != nil {
+		return fmt.Errorf("unable to start worker: %w", err)
 	}
-}
-
-func newPrometheusScope(c prometheus.Configuration) tally.Scope {
-	reporter, err := c.NewReporter(
-		prometheus.ConfigurationOptions{
-			Registry: prom.NewRegistry(),
-			OnError: func(err error) {
-				log.Println("error in prometheus reporter", err)
-			},
-		},
-	)
-	if err != nil {
-		log.Fatalln("error creating prometheus reporter", err)
-	}
-	scopeOpts := tally.ScopeOptions{
-		CachedReporter:  reporter,
-		Separator:       prometheus.DefaultSeparator,
-		SanitizeOptions: &sdktally.PrometheusSanitizeOptions,
-		Prefix:          "temporal_samples",
-	}
-	scope, _ := tally.NewRootScope(scopeOpts, time.Second)
-	scope = sdktally.NewPrometheusNamingScope(scope)
-
-	log.Println("prometheus metrics scope created")
-	return scope
+	return nil
 }
@@ -17,28 +17,37 @@ func Workflow(ctx workflow.Context) error {
 	logger := workflow.GetLogger(ctx)
 	logger.Info("Metrics workflow started.")
 
-	scheduledTimeNanos := workflow.Now(ctx).UnixNano()
 	_ = workflow.Sleep(ctx, 500*time.Millisecond)
-	err := workflow.ExecuteActivity(ctx, Activity, scheduledTimeNanos).Get(ctx, nil)
+	err := workflow.ExecuteActivity(ctx, Activity).Get(ctx, nil)
 	if err != nil {
 		logger.Error("Activity failed.", "Error", err)
 		return err
 	}
 
+	lao := workflow.LocalActivityOptions{
+		StartToCloseTimeout: 10 * time.Second,
+	}
+	ctx = workflow.WithLocalActivityOptions(ctx, lao)
+	err = workflow.ExecuteLocalActivity(ctx, LocalActivity).Get(ctx, nil)
+	if err != nil {
+		logger.Error("Local activity failed.", "Error", err)
+		return err
+	}
+
 	logger.Info("Metrics workflow completed.")
 	return nil
 }
 
-func Activity(ctx context.Context, scheduledTimeNanos int64) error {
+// Activity does not emit metrics itself, they are emitted by the interceptor returned by NewWorkerInterceptor.
+func Activity(ctx context.Context) error {
 	logger := activity.GetLogger(ctx)
 
-	var err error
-	metricsHandler := activity.GetMetricsHandler(ctx)
-	metricsHandler = recordActivityStart(metricsHandler, "metrics.Activity", scheduledTimeNanos)
-	startTime := time.Now()
-	defer func() { recordActivityEnd(metricsHandler, startTime, err) }()
-
 	time.Sleep(time.Second)
 	logger.Info("Metrics reported.")
-	return err
+	return nil
+}
+
+func LocalActivity(ctx context.Context) error {
+	time.Sleep(100 * time.Millisecond)
+	return nil
 }
@@ -2,6 +2,7 @@ package mutex
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -18,10 +19,23 @@ const (
 	AcquireLockSignalName = "acquire-lock-event"
 	// RequestLockSignalName channel name for request lock
 	RequestLockSignalName = "request-lock-event"
+	// RenewLockSignalName channel name for lock lease renewal
+	RenewLockSignalName = "renew-lock-event"
+	// RenewLockResultSignalName channel name for the result of a renewal, true when the lease was extended
+	RenewLockResultSignalName = "renew-lock-result"
+	// LockStateQueryName query type name for the lock state
+	LockStateQueryName = "lock-state"
+
+	// idleTimeout is how long MutexWorkflow waits for a new lock request once the lock is free and nobody waits for it
+	idleTimeout = time.Minute
 
 	ClientContextKey ContextKey = "Client"
 )
 
+// ErrLockLost is returned by Mutex.Renew when the current workflow no longer holds the lock, for example because its
+// lease expired before the renewal.
+var ErrLockLost = errors.New("lock is no longer held by this workflow")
+
 type (
 	ContextKey string
 
@@ -31,6 +45,29 @@ type (
 		currentWorkflowID string
 		lockNamespace     string
 	}
+
+	// RenewLockRequest is the payload of RenewLockSignalName. Timeout is the new lease duration counted from the time
+	// the renewal is received, the unlockTimeout of the mutex workflow is used when it is zero.
+	RenewLockRequest struct {
+		WorkflowID string
+		Timeout    time.Duration
+	}
+
+	// MutexState is the state of MutexWorkflow that is carried over continue-as-new. Waiters holds the workflow IDs
+	// waiting for the lock in the order of their requests.
+	MutexState struct {
+		Holder                 string
+		ReleaseLockChannelName string
+		ExpiresAt              time.Time
+		Waiters                []string
+	}
+
+	// LockState is returned by LockStateQueryName
+	LockState struct {
+		Holder    string
+		ExpiresAt time.Time
+		Waiters   []string
+	}
 )
 
 // NewMutex initializes mutex
@@ -67,18 +104,38 @@ func (s *Mutex) Lock(ctx workflow.Context,
 		Receive(ctx, &releaseLockChannelName)
 
 	unlockFunc := func() error {
-		return workflow.SignalExternalWorkflow(ctx, execution.ID, execution.RunID,
+		// The run ID is left empty as the mutex workflow may have continued as new since the lock was acquired.
+		return workflow.SignalExternalWorkflow(ctx, execution.ID, "",
 			releaseLockChannelName, "releaseLock").Get(ctx, nil)
 	}
 	return unlockFunc, nil
 }
 
-// MutexWorkflow used for locking a resource
+// Renew extends the lease of a lock held by the current workflow to timeout from now. A holder that stops renewing,
+// for example because its worker crashed, loses the lock once the lease expires, and its next renewal returns
+// ErrLockLost.
+func (s *Mutex) Renew(ctx workflow.Context, resourceID string, timeout time.Duration) error {
+	err := workflow.SignalExternalWorkflow(ctx, mutexWorkflowID(s.lockNamespace, resourceID), "",
+		RenewLockSignalName, RenewLockRequest{WorkflowID: s.currentWorkflowID, Timeout: timeout}).Get(ctx, nil)
+	if err != nil {
+		return err
+	}
+	var renewed bool
+	workflow.GetSignalChannel(ctx, RenewLockResultSignalName).Receive(ctx, &renewed)
+	if !renewed {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// MutexWorkflow used for locking a resource. It grants the lock to the requesting workflows one at a time in the
+// order of their requests, and completes once the lock has been free for idleTimeout.
 func MutexWorkflow(
 	ctx workflow.Context,
 	namespace string,
 	resourceID string,
 	unlockTimeout time.Duration,
+	state *MutexState,
 ) error {
 	currentWorkflowID := workflow.GetInfo(ctx).WorkflowExecution.ID
 	if currentWorkflowID == "default-test-workflow-id" {
@@ -87,43 +144,176 @@ func MutexWorkflow(
 	}
 	logger := workflow.GetLogger(ctx)
 	logger.Info("started", "currentWorkflowID", currentWorkflowID)
-	var ack string
+	if state == nil {
+		state = &MutexState{}
+	}
+	err := workflow.SetQueryHandler(ctx, LockStateQueryName, func() (LockState, error) {
+		return LockState{
+			Holder:    state.Holder,
+			ExpiresAt: state.ExpiresAt,
+			Waiters:   append([]string(nil), state.Waiters...),
+		}, nil
+	})
+	if err != nil {
+		logger.Info("SetQueryHandler failed.", "Error", err)
+		return err
+	}
+
 	requestLockCh := workflow.GetSignalChannel(ctx, RequestLockSignalName)
-	for {
+	renewLockCh := workflow.GetSignalChannel(ctx, RenewLockSignalName)
+	enqueue := func(senderWorkflowID string) {
+		if senderWorkflowID == state.Holder {
+			return
+		}
+		for _, waiter := range state.Waiters {
+			if waiter == senderWorkflowID {
+				return
+			}
+		}
+		state.Waiters = append(state.Waiters, senderWorkflowID)
+	}
+	drainRequests := func() {
 		var senderWorkflowID string
-		if !requestLockCh.ReceiveAsync(&senderWorkflowID) {
-			logger.Info("no more signals")
-			break
+		for requestLockCh.ReceiveAsync(&senderWorkflowID) {
+			enqueue(senderWorkflowID)
 		}
-		var releaseLockChannelName string
-		_ = workflow.SideEffect(ctx, func(ctx workflow.Context) interface{} {
-			return generateUnlockChannelName(senderWorkflowID)
-		}).Get(&releaseLockChannelName)
-		logger.Info("generated release lock channel name", "releaseLockChannelName", releaseLockChannelName)
-		// Send release lock channel name back to a senderWorkflowID, so that it can
-		// release the lock using release lock channel name
-		err := workflow.SignalExternalWorkflow(ctx, senderWorkflowID, "",
-			AcquireLockSignalName, releaseLockChannelName).Get(ctx, nil)
+	}
+	// renew extends the lease if the request comes from the holder, and tells the requester whether it did
+	renew := func(request RenewLockRequest) {
+		renewed := request.WorkflowID == state.Holder
+		if renewed {
+			timeout := request.Timeout
+			if timeout == 0 {
+				timeout = unlockTimeout
+			}
+			state.ExpiresAt = workflow.Now(ctx).Add(timeout)
+			logger.Info("lock renewed", "holder", state.Holder, "expiresAt", state.ExpiresAt)
+		} else {
+			logger.Info("rejected renewal from a workflow that does not hold the lock", "WorkflowID", request.WorkflowID)
+		}
+		err := workflow.SignalExternalWorkflow(ctx, request.WorkflowID, "",
+			RenewLockResultSignalName, renewed).Get(ctx, nil)
 		if err != nil {
-			// .Get(ctx, nil) blocks until the signal is sent.
-			// If the senderWorkflowID is closed (terminated/canceled/timeouted/completed/etc), this would return error.
-			// In this case we release the lock immediately instead of failing the mutex workflow.
-			// Mutex workflow failing would lead to all workflows that have sent requestLock will be waiting.
 			logger.Info("SignalExternalWorkflow error", "Error", err)
-			continue
 		}
-		logger.Info("signaled external workflow")
+	}
+	drainRenewals := func() {
+		var request RenewLockRequest
+		for renewLockCh.ReceiveAsync(&request) {
+			renew(request)
+		}
+	}
+
+	for {
+		drainRequests()
+		drainRenewals()
+		if state.Holder == "" {
+			if len(state.Waiters) == 0 {
+				// Wait for a new request, rejecting renewals of expired leases meanwhile
+				timerCtx, cancelTimer := workflow.WithCancel(ctx)
+				idle := false
+				selector := workflow.NewSelector(ctx)
+				selector.AddFuture(workflow.NewTimer(timerCtx, idleTimeout), func(f workflow.Future) {
+					idle = f.Get(ctx, nil) == nil
+				})
+				selector.AddReceive(requestLockCh, func(c workflow.ReceiveChannel, more bool) {
+					var senderWorkflowID string
+					c.Receive(ctx, &senderWorkflowID)
+					enqueue(senderWorkflowID)
+				})
+				selector.AddReceive(renewLockCh, func(c workflow.ReceiveChannel, more bool) {
+					var request RenewLockRequest
+					c.Receive(ctx, &request)
+					renew(request)
+				})
+				selector.Select(ctx)
+				cancelTimer()
+				if idle {
+					// A request may land in the same workflow task as the timer, serve it instead of dropping it
+					drainRequests()
+					drainRenewals()
+					if len(state.Waiters) > 0 {
+						continue
+					}
+					logger.Info("no more signals")
+					return nil
+				}
+				continue
+			}
+			senderWorkflowID := state.Waiters[0]
+			state.Waiters = state.Waiters[1:]
+			var releaseLockChannelName string
+			_ = workflow.SideEffect(ctx, func(ctx workflow.Context) interface{} {
+				return generateUnlockChannelName(senderWorkflowID)
+			}).Get(&releaseLockChannelName)
+			logger.Info("generated release lock channel name", "releaseLockChannelName", releaseLockChannelName)
+			// Send release lock channel name back to a senderWorkflowID, so that it can
+			// release the lock using release lock channel name
+			err := workflow.SignalExternalWorkflow(ctx, senderWorkflowID, "",
+				AcquireLockSignalName, releaseLockChannelName).Get(ctx, nil)
+			if err != nil {
+				// .Get(ctx, nil) blocks until the signal is sent.
+				// If the senderWorkflowID is closed (terminated/canceled/timeouted/completed/etc), this would return error.
+				// In this case we release the lock immediately instead of failing the mutex workflow.
+				// Mutex workflow failing would lead to all workflows that have sent requestLock will be waiting.
+				logger.Info("SignalExternalWorkflow error", "Error", err)
+				continue
+			}
+			logger.Info("signaled external workflow")
+			state.Holder = senderWorkflowID
+			state.ReleaseLockChannelName = releaseLockChannelName
+			state.ExpiresAt = workflow.Now(ctx).Add(unlockTimeout)
+		}
+
+		// Wait until the lock is released, renewed or expires. New requests are queued meanwhile.
+		leaseLeft := state.ExpiresAt.Sub(workflow.Now(ctx))
+		if leaseLeft < 0 {
+			leaseLeft = 0
+		}
+		timerCtx, cancelTimer := workflow.WithCancel(ctx)
 		selector := workflow.NewSelector(ctx)
-		selector.AddFuture(workflow.NewTimer(ctx, unlockTimeout), func(f workflow.Future) {
-			logger.Info("unlockTimeout exceeded")
+		selector.AddFuture(workflow.NewTimer(timerCtx, leaseLeft), func(f workflow.Future) {
+			if f.Get(ctx, nil) == nil {
+				logger.Info("unlockTimeout exceeded", "holder", state.Holder)
+				state.Holder = ""
+			}
 		})
-		selector.AddReceive(workflow.GetSignalChannel(ctx, releaseLockChannelName), func(c workflow.ReceiveChannel, more bool) {
+		selector.AddReceive(workflow.GetSignalChannel(ctx, state.ReleaseLockChannelName), func(c workflow.ReceiveChannel, more bool) {
+			var ack string
 			c.Receive(ctx, &ack)
-			logger.Info("release signal received")
+			logger.Info("release signal received", "holder", state.Holder)
+			state.Holder = ""
+		})
+		selector.AddReceive(renewLockCh, func(c workflow.ReceiveChannel, more bool) {
+			var request RenewLockRequest
+			c.Receive(ctx, &request)
+			renew(request)
+		})
+		selector.AddReceive(requestLockCh, func(c workflow.ReceiveChannel, more bool) {
+			var senderWorkflowID string
+			c.Receive(ctx, &senderWorkflowID)
+			enqueue(senderWorkflowID)
 		})
 		selector.Select(ctx)
+		cancelTimer()
+		if state.Holder == "" {
+			state.ReleaseLockChannelName = ""
+			state.ExpiresAt = time.Time{}
+		}
+
+		if workflow.GetInfo(ctx).GetContinueAsNewSuggested() {
+			drainRequests()
+			drainRenewals()
+			if state.Holder != "" {
+				var ack string
+				if workflow.GetSignalChannel(ctx, state.ReleaseLockChannelName).ReceiveAsync(&ack) {
+					state.Holder, state.ReleaseLockChannelName, state.ExpiresAt = "", "", time.Time{}
+				}
+			}
+			logger.Info("continuing as new", "holder", state.Holder, "waiters", len(state.Waiters))
+			return workflow.NewContinueAsNewError(ctx, MutexWorkflow, namespace, resourceID, unlockTimeout, state)
+		}
 	}
-	return nil
 }
 
 // SignalWithStartMutexWorkflowActivity ...
@@ -136,12 +326,7 @@ func SignalWithStartMutexWorkflowActivity(
 ) (*workflow.Execution, error) {
 
 	c := ctx.Value(ClientContextKey).(client.Client)
-	workflowID := fmt.Sprintf(
-		"%s:%s:%s",
-		"mutex",
-		namespace,
-		resourceID,
-	)
+	workflowID := mutexWorkflowID(namespace, resourceID)
 	workflowOptions := client.StartWorkflowOptions{
 		ID:        workflowID,
 		TaskQueue: "mutex",
@@ -154,7 +339,7 @@ func SignalWithStartMutexWorkflowActivity(
 	}
 	wr, err := c.SignalWithStartWorkflow(
 		ctx, workflowID, RequestLockSignalName, senderWorkflowID,
-		workflowOptions, MutexWorkflow, namespace, resourceID, unlockTimeout)
+		workflowOptions, MutexWorkflow, namespace, resourceID, unlockTimeout, nil)
 
 	if err != nil {
 		activity.GetLogger(ctx).Error("Unable to signal with start workflow", "Error", err)
@@ -168,12 +353,22 @@ func SignalWithStartMutexWorkflowActivity(
 	}, nil
 }
 
+// mutexWorkflowID returns the ID of the mutex workflow that guards resourceID
+func mutexWorkflowID(namespace string, resourceID string) string {
+	return fmt.Sprintf(
+		"%s:%s:%s",
+		"mutex",
+		namespace,
+		resourceID,
+	)
+}
+
 // generateUnlockChannelName generates release lock channel name
 func generateUnlockChannelName(senderWorkflowID string) string {
 	return fmt.Sprintf("unlock-event-%s", senderWorkflowID)
 }
 
-// MockMutexLock stubs mutex.Lock call
+// MockMutexLock stubs mutex.Lock call, and the mutex.Renew calls which keep the lock
 func MockMutexLock(env *testsuite.TestWorkflowEnvironment, resourceID string, mockError error) {
 	execution := &workflow.Execution{ID: "mockID", RunID: "mockRunID"}
 	env.OnActivity(SignalWithStartMutexWorkflowActivity,
@@ -183,7 +378,13 @@ func MockMutexLock(env *testsuite.TestWorkflowEnvironment, resourceID string, mo
 		env.SignalWorkflow(AcquireLockSignalName, "mockReleaseLockChannelName")
 	}, time.Millisecond*0)
 	if mockError == nil {
-		env.OnSignalExternalWorkflow(mock.Anything, mock.Anything, execution.RunID,
+		env.OnSignalExternalWorkflow(mock.Anything, mock.Anything, mock.Anything,
+			RenewLockSignalName, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			env.RegisterDelayedCallback(func() {
+				env.SignalWorkflow(RenewLockResultSignalName, true)
+			}, 0)
+		})
+		env.OnSignalExternalWorkflow(mock.Anything, mock.Anything, mock.Anything,
 			mock.Anything, mock.Anything).Return(nil)
 	}
 }
@@ -197,15 +398,22 @@ func SampleWorkflowWithMutex(
 	logger.Info("started", "currentWorkflowID", currentWorkflowID, "resourceID", resourceID)
 
 	mutex := NewMutex(currentWorkflowID, "TestUseCase")
-	unlockFunc, err := mutex.Lock(ctx, resourceID, 10*time.Minute)
+	unlockFunc, err := mutex.Lock(ctx, resourceID, time.Minute)
 	if err != nil {
 		return err
 	}
 	logger.Info("resource locked")
 
-	// emulate long running process
+	// emulate long running process, renewing the lease after every step
 	logger.Info("critical operation started")
-	_ = workflow.Sleep(ctx, 10*time.Second)
+	for step := 0; step < 3; step++ {
+		_ = workflow.Sleep(ctx, 10*time.Second)
+		if err := mutex.Renew(ctx, resourceID, time.Minute); err != nil {
+			// The lock may be held by another workflow now, so the operation must stop
+			logger.Info("lock renewal failed", "Error", err)
+			return err
+		}
+	}
 	logger.Info("critical operation finished")
 
 	_ = unlockFunc()
@@ -3,14 +3,22 @@ package mutex
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	commonpb "go.temporal.io/api/common/v1"
+	enumspb "go.temporal.io/api/enums/v1"
+	historypb "go.temporal.io/api/history/v1"
+	taskqueuepb "go.temporal.io/api/taskqueue/v1"
+	"go.temporal.io/sdk/converter"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/testsuite"
 	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 type UnitTestSuite struct {
@@ -75,6 +83,7 @@ func (s *UnitTestSuite) Test_MutexWorkflow_Success() {
 		mockNamespace,
 		mockResourceID,
 		mockUnlockTimeout,
+		(*MutexState)(nil),
 	)
 
 	s.True(s.env.IsWorkflowCompleted())
@@ -97,8 +106,432 @@ func (s *UnitTestSuite) Test_MutexWorkflow_TimeoutSuccess() {
 		mockNamespace,
 		mockResourceID,
 		mockUnlockTimeout,
+		(*MutexState)(nil),
 	)
 
 	s.True(s.env.IsWorkflowCompleted())
 	s.NoError(s.env.GetWorkflowError())
 }
+
+func (s *UnitTestSuite) Test_MutexWorkflow_RequestOnIdle() {
+	s.NoError(s.replayRequestOnIdle(MutexWorkflow, []interface{}{"mockNamespace", "mockResourceID", 10 * time.Minute, (*MutexState)(nil)},
+		RequestLockSignalName, "waiter", AcquireLockSignalName))
+}
+
+// replayRequestOnIdle replays a history where a request from the "waiter" workflow lands in the same workflow task as
+// the idle timer, which the test environment cannot deliver together. The request must be granted rather than
+// dropped.
+func (s *UnitTestSuite) replayRequestOnIdle(
+	workflowFunc interface{},
+	input []interface{},
+	requestSignalName string,
+	request interface{},
+	acquireSignalName string,
+) error {
+	dc := converter.GetDefaultDataConverter()
+	payloads := func(values ...interface{}) *commonpb.Payloads {
+		result, err := dc.ToPayloads(values...)
+		s.NoError(err)
+		return result
+	}
+	taskQueue := &taskqueuepb.TaskQueue{Name: "mutex"}
+	workflowTask := func(scheduledEventID int64) []*historypb.HistoryEvent {
+		return []*historypb.HistoryEvent{
+			{EventType: enumspb.EVENT_TYPE_WORKFLOW_TASK_SCHEDULED, Attributes: &historypb.HistoryEvent_WorkflowTaskScheduledEventAttributes{
+				WorkflowTaskScheduledEventAttributes: &historypb.WorkflowTaskScheduledEventAttributes{TaskQueue: taskQueue}}},
+			{EventType: enumspb.EVENT_TYPE_WORKFLOW_TASK_STARTED, Attributes: &historypb.HistoryEvent_WorkflowTaskStartedEventAttributes{
+				WorkflowTaskStartedEventAttributes: &historypb.WorkflowTaskStartedEventAttributes{ScheduledEventId: scheduledEventID}}},
+			{EventType: enumspb.EVENT_TYPE_WORKFLOW_TASK_COMPLETED, Attributes: &historypb.HistoryEvent_WorkflowTaskCompletedEventAttributes{
+				WorkflowTaskCompletedEventAttributes: &historypb.WorkflowTaskCompletedEventAttributes{
+					ScheduledEventId: scheduledEventID,
+					StartedEventId:   scheduledEventID + 1,
+				}}},
+		}
+	}
+
+	events := []*historypb.HistoryEvent{
+		{EventType: enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_STARTED, Attributes: &historypb.HistoryEvent_WorkflowExecutionStartedEventAttributes{
+			WorkflowExecutionStartedEventAttributes: &historypb.WorkflowExecutionStartedEventAttributes{
+				WorkflowType: &commonpb.WorkflowType{Name: "RequestOnIdle"},
+				TaskQueue:    taskQueue,
+				Input:        payloads(input...),
+			}}},
+	}
+	events = append(events, workflowTask(2)...)
+	events = append(events,
+		&historypb.HistoryEvent{EventType: enumspb.EVENT_TYPE_TIMER_STARTED, Attributes: &historypb.HistoryEvent_TimerStartedEventAttributes{
+			TimerStartedEventAttributes: &historypb.TimerStartedEventAttributes{
+				TimerId:                      "5",
+				StartToFireTimeout:           durationpb.New(idleTimeout),
+				WorkflowTaskCompletedEventId: 4,
+			}}},
+		// The request and the idle timer are delivered in the same workflow task
+		&historypb.HistoryEvent{EventType: enumspb.EVENT_TYPE_TIMER_FIRED, Attributes: &historypb.HistoryEvent_TimerFiredEventAttributes{
+			TimerFiredEventAttributes: &historypb.TimerFiredEventAttributes{TimerId: "5", StartedEventId: 5}}},
+		&historypb.HistoryEvent{EventType: enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_SIGNALED, Attributes: &historypb.HistoryEvent_WorkflowExecutionSignaledEventAttributes{
+			WorkflowExecutionSignaledEventAttributes: &historypb.WorkflowExecutionSignaledEventAttributes{
+				SignalName: requestSignalName,
+				Input:      payloads(request),
+			}}},
+	)
+	events = append(events, workflowTask(8)...)
+	events = append(events,
+		&historypb.HistoryEvent{EventType: enumspb.EVENT_TYPE_MARKER_RECORDED, Attributes: &historypb.HistoryEvent_MarkerRecordedEventAttributes{
+			MarkerRecordedEventAttributes: &historypb.MarkerRecordedEventAttributes{
+				MarkerName: "SideEffect",
+				Details: map[string]*commonpb.Payloads{
+					"side-effect-id": payloads(int64(1)),
+					"data":           payloads("unlock-event-waiter"),
+				},
+				WorkflowTaskCompletedEventId: 10,
+			}}},
+		&historypb.HistoryEvent{EventType: enumspb.EVENT_TYPE_SIGNAL_EXTERNAL_WORKFLOW_EXECUTION_INITIATED, Attributes: &historypb.HistoryEvent_SignalExternalWorkflowExecutionInitiatedEventAttributes{
+			SignalExternalWorkflowExecutionInitiatedEventAttributes: &historypb.SignalExternalWorkflowExecutionInitiatedEventAttributes{
+				WorkflowExecution:            &commonpb.WorkflowExecution{WorkflowId: "waiter"},
+				SignalName:                   acquireSignalName,
+				Input:                        payloads("unlock-event-waiter"),
+				Control:                      "12",
+				WorkflowTaskCompletedEventId: 10,
+			}}},
+	)
+	for i, event := range events {
+		event.EventId = int64(i + 1)
+	}
+
+	replayer := worker.NewWorkflowReplayer()
+	replayer.RegisterWorkflowWithOptions(workflowFunc, workflow.RegisterOptions{Name: "RequestOnIdle"})
+	return replayer.ReplayWorkflowHistory(nil, &historypb.History{Events: events})
+}
+
+func (s *UnitTestSuite) queryLockState() LockState {
+	encoded, err := s.env.QueryWorkflow(LockStateQueryName)
+	s.NoError(err)
+	var state LockState
+	s.NoError(encoded.Get(&state))
+	return state
+}
+
+func (s *UnitTestSuite) Test_MutexWorkflow_CrashedHolder() {
+	mockUnlockTimeout := 10 * time.Minute
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(RequestLockSignalName, "holder")
+		s.env.SignalWorkflow(RequestLockSignalName, "waiter")
+	}, time.Millisecond*0)
+	s.env.OnSignalExternalWorkflow(mock.Anything, "holder", "",
+		AcquireLockSignalName, mock.Anything).Return(nil).Once()
+	s.env.OnSignalExternalWorkflow(mock.Anything, "waiter", "",
+		AcquireLockSignalName, mock.Anything).Return(nil).Once()
+	// The holder crashed and never releases the lock, the waiter gets it once the lease expires.
+	s.env.RegisterDelayedCallback(func() {
+		state := s.queryLockState()
+		s.Equal("holder", state.Holder)
+		s.Equal([]string{"waiter"}, state.Waiters)
+	}, 5*time.Minute)
+	s.env.RegisterDelayedCallback(func() {
+		state := s.queryLockState()
+		s.Equal("waiter", state.Holder)
+		s.Empty(state.Waiters)
+		s.env.SignalWorkflow("unlock-event-waiter", "releaseLock")
+	}, 11*time.Minute)
+
+	s.env.ExecuteWorkflow(MutexWorkflow, "mockNamespace", "mockResourceID", mockUnlockTimeout, (*MutexState)(nil))
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.env.AssertExpectations(s.T())
+}
+
+func (s *UnitTestSuite) Test_MutexWorkflow_Renewal() {
+	mockUnlockTimeout := 10 * time.Minute
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(RequestLockSignalName, "holder")
+		s.env.SignalWorkflow(RequestLockSignalName, "waiter")
+	}, time.Millisecond*0)
+	s.env.OnSignalExternalWorkflow(mock.Anything, "holder", "",
+		AcquireLockSignalName, mock.Anything).Return(nil).Once()
+	s.env.OnSignalExternalWorkflow(mock.Anything, "waiter", "",
+		AcquireLockSignalName, mock.Anything).Return(nil).Once()
+	s.env.RegisterDelayedCallback(func() {
+		// Renewals from workflows that don't hold the lock are rejected.
+		s.env.SignalWorkflow(RenewLockSignalName, RenewLockRequest{WorkflowID: "waiter", Timeout: time.Hour})
+		s.env.SignalWorkflow(RenewLockSignalName, RenewLockRequest{WorkflowID: "holder"})
+	}, 8*time.Minute)
+	s.env.OnSignalExternalWorkflow(mock.Anything, "waiter", "",
+		RenewLockResultSignalName, false).Return(nil).Once()
+	s.env.OnSignalExternalWorkflow(mock.Anything, "holder", "",
+		RenewLockResultSignalName, true).Return(nil).Once()
+	s.env.RegisterDelayedCallback(func() {
+		state := s.queryLockState()
+		s.Equal("holder", state.Holder)
+		s.Equal([]string{"waiter"}, state.Waiters)
+		s.env.SignalWorkflow("unlock-event-holder", "releaseLock")
+	}, 15*time.Minute)
+	s.env.RegisterDelayedCallback(func() {
+		s.Equal("waiter", s.queryLockState().Holder)
+	}, 16*time.Minute)
+
+	s.env.ExecuteWorkflow(MutexWorkflow, "mockNamespace", "mockResourceID", mockUnlockTimeout, (*MutexState)(nil))
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.env.AssertExpectations(s.T())
+}
+
+func (s *UnitTestSuite) Test_MutexWorkflow_ContinueAsNew() {
+	mockUnlockTimeout := 10 * time.Minute
+	s.env.SetContinueAsNewSuggested(true)
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(RequestLockSignalName, "holder")
+	}, time.Millisecond*0)
+	// Both signals are received while the lock is being granted, the pending renewal is answered before continuing as
+	// new
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(RequestLockSignalName, "waiter")
+		s.env.SignalWorkflow(RenewLockSignalName, RenewLockRequest{WorkflowID: "holder"})
+	}, 30*time.Second)
+	s.env.OnSignalExternalWorkflow(mock.Anything, "holder", "",
+		AcquireLockSignalName, mock.Anything).After(time.Minute).Return(nil).Once()
+	s.env.OnSignalExternalWorkflow(mock.Anything, "holder", "",
+		RenewLockResultSignalName, true).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(MutexWorkflow, "mockNamespace", "mockResourceID", mockUnlockTimeout, (*MutexState)(nil))
+
+	s.True(s.env.IsWorkflowCompleted())
+	var canErr *workflow.ContinueAsNewError
+	s.True(errors.As(s.env.GetWorkflowError(), &canErr))
+	var namespace, resourceID string
+	var unlockTimeout time.Duration
+	var state *MutexState
+	s.NoError(converter.GetDefaultDataConverter().FromPayloads(canErr.Input, &namespace, &resourceID, &unlockTimeout, &state))
+	s.Equal("holder", state.Holder)
+	s.Equal("unlock-event-holder", state.ReleaseLockChannelName)
+	s.Equal([]string{"waiter"}, state.Waiters)
+	s.False(state.ExpiresAt.IsZero())
+	s.env.AssertExpectations(s.T())
+}
+
+func (s *UnitTestSuite) Test_MutexWorkflow_RenewalAfterExpiry() {
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(RequestLockSignalName, "holder")
+	}, time.Millisecond*0)
+	s.env.OnSignalExternalWorkflow(mock.Anything, "holder", "",
+		AcquireLockSignalName, mock.Anything).Return(nil).Once()
+	// The lease expired while the lock is free, the late renewal is rejected.
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(RenewLockSignalName, RenewLockRequest{WorkflowID: "holder"})
+	}, 11*time.Minute)
+	s.env.OnSignalExternalWorkflow(mock.Anything, "holder", "",
+		RenewLockResultSignalName, false).Return(nil).Once()
+
+	s.env.ExecuteWorkflow(MutexWorkflow, "mockNamespace", "mockResourceID", 10*time.Minute, (*MutexState)(nil))
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.env.AssertExpectations(s.T())
+}
+
+func (s *UnitTestSuite) Test_Mutex_RenewLost() {
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflowWithOptions(func(ctx workflow.Context) error {
+		err := NewMutex("workflowID", "TestUseCase").Renew(ctx, "mockResourceID", time.Minute)
+		if !errors.Is(err, ErrLockLost) {
+			return fmt.Errorf("expected ErrLockLost, got %v", err)
+		}
+		return nil
+	}, workflow.RegisterOptions{Name: "RenewLost"})
+	env.OnSignalExternalWorkflow(mock.Anything, "mutex:TestUseCase:mockResourceID", "",
+		RenewLockSignalName, RenewLockRequest{WorkflowID: "workflowID", Timeout: time.Minute}).Return(nil).Once()
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(RenewLockResultSignalName, false)
+	}, time.Second)
+
+	env.ExecuteWorkflow("RenewLost")
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	env.AssertExpectations(s.T())
+}
+
+func (s *UnitTestSuite) Test_SemaphoreWorkflow_RequestOnIdle() {
+	s.NoError(s.replayRequestOnIdle(SemaphoreWorkflow, []interface{}{2, (*SemaphoreState)(nil)},
+		RequestSemaphoreSignalName, SemaphoreRequest{WorkflowID: "waiter", Permits: 1, Timeout: time.Minute},
+		AcquireSemaphoreSignalName))
+}
+
+func (s *UnitTestSuite) querySemaphoreState() SemaphoreState {
+	encoded, err := s.env.QueryWorkflow(SemaphoreStateQueryName)
+	s.NoError(err)
+	var state SemaphoreState
+	s.NoError(encoded.Get(&state))
+	return state
+}
+
+func holderIDs(state SemaphoreState) []string {
+	var ids []string
+	for _, holder := range state.Holders {
+		ids = append(ids, holder.WorkflowID)
+	}
+	return ids
+}
+
+func waiterIDs(state SemaphoreState) []string {
+	var ids []string
+	for _, waiter := range state.Waiters {
+		ids = append(ids, waiter.WorkflowID)
+	}
+	return ids
+}
+
+func (s *UnitTestSuite) Test_SemaphoreWorkflow_Permits() {
+	s.env.RegisterDelayedCallback(func() {
+		for _, id := range []string{"first", "second", "third"} {
+			s.env.SignalWorkflow(RequestSemaphoreSignalName, SemaphoreRequest{WorkflowID: id, Permits: 1, Timeout: 10 * time.Minute})
+		}
+	}, time.Millisecond*0)
+	s.env.OnSignalExternalWorkflow(mock.Anything, mock.Anything, "",
+		AcquireSemaphoreSignalName, mock.Anything).Return(nil).Times(3)
+	s.env.RegisterDelayedCallback(func() {
+		state := s.querySemaphoreState()
+		s.Equal([]string{"first", "second"}, holderIDs(state))
+		s.Equal([]string{"third"}, waiterIDs(state))
+		s.env.SignalWorkflow("unlock-event-first", "releaseLock")
+	}, time.Minute)
+	s.env.RegisterDelayedCallback(func() {
+		state := s.querySemaphoreState()
+		s.Equal([]string{"second", "third"}, holderIDs(state))
+		s.Empty(state.Waiters)
+	}, 2*time.Minute)
+
+	s.env.ExecuteWorkflow(SemaphoreWorkflow, 2, (*SemaphoreState)(nil))
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.env.AssertExpectations(s.T())
+}
+
+func (s *UnitTestSuite) Test_SemaphoreWorkflow_ReadersAndWriter() {
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(RequestSemaphoreSignalName, SemaphoreRequest{WorkflowID: "reader1", Permits: 1, Timeout: 10 * time.Minute})
+		s.env.SignalWorkflow(RequestSemaphoreSignalName, SemaphoreRequest{WorkflowID: "writer", Permits: rwMutexCapacity, Timeout: 10 * time.Minute})
+		s.env.SignalWorkflow(RequestSemaphoreSignalName, SemaphoreRequest{WorkflowID: "reader2", Permits: 1, Timeout: 10 * time.Minute})
+	}, time.Millisecond*0)
+	s.env.OnSignalExternalWorkflow(mock.Anything, mock.Anything, "",
+		AcquireSemaphoreSignalName, mock.Anything).Return(nil).Times(3)
+	s.env.RegisterDelayedCallback(func() {
+		// The waiting writer blocks the second reader.
+		state := s.querySemaphoreState()
+		s.Equal([]string{"reader1"}, holderIDs(state))
+		s.Equal([]string{"writer", "reader2"}, waiterIDs(state))
+		s.env.SignalWorkflow("unlock-event-reader1", "releaseLock")
+	}, time.Minute)
+	s.env.RegisterDelayedCallback(func() {
+		state := s.querySemaphoreState()
+		s.Equal([]string{"writer"}, holderIDs(state))
+		s.Equal([]string{"reader2"}, waiterIDs(state))
+	}, 2*time.Minute)
+	s.env.RegisterDelayedCallback(func() {
+		// The writer crashed, reader2 gets access once its lease expires.
+		s.Equal([]string{"reader2"}, holderIDs(s.querySemaphoreState()))
+	}, 12*time.Minute)
+
+	s.env.ExecuteWorkflow(SemaphoreWorkflow, rwMutexCapacity, (*SemaphoreState)(nil))
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.env.AssertExpectations(s.T())
+}
+
+func (s *UnitTestSuite) Test_SampleWorkflowWithSemaphore() {
+	env := s.NewTestWorkflowEnvironment()
+	execution := &workflow.Execution{ID: "mockID", RunID: "mockRunID"}
+	env.OnActivity(SignalWithStartSemaphoreWorkflowActivity, mock.Anything, "semaphore:TestUseCase:mockResourceID", 2,
+		SemaphoreRequest{WorkflowID: "default-test-workflow-id", Permits: 1, Timeout: 10 * time.Minute}).
+		Return(execution, nil)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(AcquireSemaphoreSignalName, "mockReleaseLockChannelName")
+	}, time.Millisecond*0)
+	env.OnSignalExternalWorkflow(mock.Anything, "mockID", "", "mockReleaseLockChannelName", mock.Anything).Return(nil).Once()
+
+	env.ExecuteWorkflow(SampleWorkflowWithSemaphore, "mockResourceID")
+
+	s.True(env.IsWorkflowCompleted())
+	s.NoError(env.GetWorkflowError())
+	env.AssertExpectations(s.T())
+}
+
+func (s *UnitTestSuite) Test_SemaphoreWorkflow_RejectsPermitsOverCapacity() {
+	// The semaphore was started with a capacity of 2 by another caller
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(RequestSemaphoreSignalName, SemaphoreRequest{WorkflowID: "greedy", Permits: 3, Timeout: 10 * time.Minute})
+	}, time.Millisecond*0)
+	s.env.OnSignalExternalWorkflow(mock.Anything, "greedy", "",
+		RejectSemaphoreSignalName, "permits must be between 1 and 2, got 3").Return(nil).Once()
+
+	s.env.ExecuteWorkflow(SemaphoreWorkflow, 2, (*SemaphoreState)(nil))
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.env.AssertExpectations(s.T())
+}
+
+func (s *UnitTestSuite) Test_SemaphoreWorkflow_RejectsExpiredLease() {
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(RequestSemaphoreSignalName, SemaphoreRequest{WorkflowID: "hasty", Permits: 1})
+	}, time.Millisecond*0)
+	s.env.OnSignalExternalWorkflow(mock.Anything, "hasty", "",
+		RejectSemaphoreSignalName, "unlockTimeout must be positive, got 0s").Return(nil).Once()
+
+	s.env.ExecuteWorkflow(SemaphoreWorkflow, 2, (*SemaphoreState)(nil))
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.env.AssertExpectations(s.T())
+}
+
+func (s *UnitTestSuite) Test_Semaphore_Rejected() {
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflowWithOptions(func(ctx workflow.Context) error {
+		_, err := NewSemaphore("workflowID", "TestUseCase", 5).Acquire(ctx, "mockResourceID", 3, time.Minute)
+		if !errors.Is(err, ErrPermitsRejected) {
+			return fmt.Errorf("expected ErrPermitsRejected, got %v", err)
+		}
+		return err
+	}, workflow.RegisterOptions{Name: "AcquireOverCapacity"})
+	execution := &workflow.Execution{ID: "mockID", RunID: "mockRunID"}
+	env.OnActivity(SignalWithStartSemaphoreWorkflowActivity, mock.Anything, "semaphore:TestUseCase:mockResourceID", 5,
+		mock.Anything).Return(execution, nil)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(RejectSemaphoreSignalName, "permits must be between 1 and 2, got 3")
+	}, time.Millisecond*0)
+
+	env.ExecuteWorkflow("AcquireOverCapacity")
+
+	s.True(env.IsWorkflowCompleted())
+	s.ErrorContains(env.GetWorkflowError(), "permits rejected by semaphore:TestUseCase:mockResourceID: permits must be between 1 and 2, got 3")
+}
+
+func (s *UnitTestSuite) Test_Semaphore_InvalidPermits() {
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflowWithOptions(func(ctx workflow.Context) error {
+		_, err := NewSemaphore("workflowID", "TestUseCase", 2).Acquire(ctx, "mockResourceID", 3, time.Minute)
+		return err
+	}, workflow.RegisterOptions{Name: "AcquireTooMany"})
+
+	env.ExecuteWorkflow("AcquireTooMany")
+
+	s.True(env.IsWorkflowCompleted())
+	s.ErrorContains(env.GetWorkflowError(), "permits must be between 1 and 2, got 3")
+}
+
+func (s *UnitTestSuite) Test_RWMutex_InvalidTimeout() {
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflowWithOptions(func(ctx workflow.Context) error {
+		_, err := NewRWMutex("workflowID", "TestUseCase").RLock(ctx, "mockResourceID", -time.Minute)
+		return err
+	}, workflow.RegisterOptions{Name: "RLockExpired"})
+
+	env.ExecuteWorkflow("RLockExpired")
+
+	s.True(env.IsWorkflowCompleted())
+	s.ErrorContains(env.GetWorkflowError(), "unlockTimeout must be positive, got -1m0s")
+}
@@ -0,0 +1,392 @@
+package mutex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+const (
+	// AcquireSemaphoreSignalName signal channel name for permit acquisition
+	AcquireSemaphoreSignalName = "acquire-semaphore-event"
+	// RequestSemaphoreSignalName channel name for request permits
+	RequestSemaphoreSignalName = "request-semaphore-event"
+	// RejectSemaphoreSignalName signal channel name for a rejected request, with the reason as payload
+	RejectSemaphoreSignalName = "reject-semaphore-event"
+	// SemaphoreStateQueryName query type name for the semaphore state
+	SemaphoreStateQueryName = "semaphore-state"
+
+	// rwMutexCapacity is the number of permits of the semaphore behind RWMutex. A reader takes one permit, a writer
+	// takes all of them.
+	rwMutexCapacity = 1 << 20
+)
+
+// ErrPermitsRejected is returned by Semaphore.Acquire when the SemaphoreWorkflow rejects the request, for example
+// because it was started with a smaller capacity than the one of the Semaphore.
+var ErrPermitsRejected = errors.New("permits rejected")
+
+type (
+	// Semaphore limits the number of workflows that use a resource at the same time to a number of permits
+	Semaphore struct {
+		currentWorkflowID string
+		lockNamespace     string
+		capacity          int
+	}
+
+	// RWMutex allows any number of readers or a single writer to use a resource at the same time. A waiting writer
+	// blocks new readers, so writers are not starved.
+	RWMutex struct {
+		semaphore *Semaphore
+	}
+
+	// SemaphoreRequest is the payload of RequestSemaphoreSignalName
+	SemaphoreRequest struct {
+		WorkflowID string
+		Permits    int
+		Timeout    time.Duration
+	}
+
+	// SemaphoreHolder is a workflow that holds permits of a SemaphoreWorkflow
+	SemaphoreHolder struct {
+		WorkflowID             string
+		Permits                int
+		ReleaseLockChannelName string
+		ExpiresAt              time.Time
+	}
+
+	// SemaphoreState is the state of SemaphoreWorkflow that is carried over continue-as-new. It is also returned by
+	// SemaphoreStateQueryName. Waiters are granted their permits in the order of their requests.
+	SemaphoreState struct {
+		Holders []SemaphoreHolder
+		Waiters []SemaphoreRequest
+	}
+)
+
+// NewSemaphore initializes a semaphore with capacity permits
+func NewSemaphore(currentWorkflowID string, lockNamespace string, capacity int) *Semaphore {
+	return &Semaphore{
+		currentWorkflowID: currentWorkflowID,
+		lockNamespace:     lockNamespace,
+		capacity:          capacity,
+	}
+}
+
+// Acquire blocks until permits are granted to the current workflow. The permits are released by the returned
+// UnlockFunc or once unlockTimeout expires. The capacity of the first Semaphore that acquires permits of resourceID is
+// used until its SemaphoreWorkflow completes, a request for more permits than that capacity fails with
+// ErrPermitsRejected.
+func (s *Semaphore) Acquire(ctx workflow.Context,
+	resourceID string, permits int, unlockTimeout time.Duration) (UnlockFunc, error) {
+	return acquire(ctx, semaphoreWorkflowID("semaphore", s.lockNamespace, resourceID), s.capacity,
+		SemaphoreRequest{WorkflowID: s.currentWorkflowID, Permits: permits, Timeout: unlockTimeout})
+}
+
+// NewRWMutex initializes a read/write mutex
+func NewRWMutex(currentWorkflowID string, lockNamespace string) *RWMutex {
+	return &RWMutex{semaphore: NewSemaphore(currentWorkflowID, lockNamespace, rwMutexCapacity)}
+}
+
+// RLock blocks until the current workflow may read resourceID
+func (m *RWMutex) RLock(ctx workflow.Context,
+	resourceID string, unlockTimeout time.Duration) (UnlockFunc, error) {
+	return m.lock(ctx, resourceID, 1, unlockTimeout)
+}
+
+// Lock blocks until the current workflow may write resourceID
+func (m *RWMutex) Lock(ctx workflow.Context,
+	resourceID string, unlockTimeout time.Duration) (UnlockFunc, error) {
+	return m.lock(ctx, resourceID, rwMutexCapacity, unlockTimeout)
+}
+
+func (m *RWMutex) lock(ctx workflow.Context,
+	resourceID string, permits int, unlockTimeout time.Duration) (UnlockFunc, error) {
+	s := m.semaphore
+	return acquire(ctx, semaphoreWorkflowID("rwmutex", s.lockNamespace, resourceID), s.capacity,
+		SemaphoreRequest{WorkflowID: s.currentWorkflowID, Permits: permits, Timeout: unlockTimeout})
+}
+
+// validate returns why SemaphoreWorkflow rejects the request, or an empty string if it is valid
+func validate(request SemaphoreRequest, capacity int) string {
+	if request.Permits <= 0 || request.Permits > capacity {
+		return fmt.Sprintf("permits must be between 1 and %d, got %d", capacity, request.Permits)
+	}
+	if request.Timeout <= 0 {
+		return fmt.Sprintf("unlockTimeout must be positive, got %v", request.Timeout)
+	}
+	return ""
+}
+
+func acquire(ctx workflow.Context,
+	workflowID string, capacity int, request SemaphoreRequest) (UnlockFunc, error) {
+	if rejection := validate(request, capacity); rejection != "" {
+		return nil, errors.New(rejection)
+	}
+
+	activityCtx := workflow.WithLocalActivityOptions(ctx, workflow.LocalActivityOptions{
+		ScheduleToCloseTimeout: time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    5,
+		},
+	})
+
+	var releaseLockChannelName, rejection string
+	var execution workflow.Execution
+	err := workflow.ExecuteLocalActivity(activityCtx,
+		SignalWithStartSemaphoreWorkflowActivity, workflowID, capacity, request).Get(ctx, &execution)
+	if err != nil {
+		return nil, err
+	}
+	workflow.NewSelector(ctx).
+		AddReceive(workflow.GetSignalChannel(ctx, AcquireSemaphoreSignalName), func(c workflow.ReceiveChannel, more bool) {
+			c.Receive(ctx, &releaseLockChannelName)
+		}).
+		AddReceive(workflow.GetSignalChannel(ctx, RejectSemaphoreSignalName), func(c workflow.ReceiveChannel, more bool) {
+			c.Receive(ctx, &rejection)
+		}).
+		Select(ctx)
+	if rejection != "" {
+		return nil, fmt.Errorf("%w by %s: %s", ErrPermitsRejected, workflowID, rejection)
+	}
+
+	unlockFunc := func() error {
+		return workflow.SignalExternalWorkflow(ctx, execution.ID, "",
+			releaseLockChannelName, "releaseLock").Get(ctx, nil)
+	}
+	return unlockFunc, nil
+}
+
+// SemaphoreWorkflow used for limiting the concurrent use of a resource to capacity permits. Requests are granted in
+// the order they are received, a request that does not fit blocks the requests behind it. It completes once no permits
+// have been held for idleTimeout.
+func SemaphoreWorkflow(
+	ctx workflow.Context,
+	capacity int,
+	state *SemaphoreState,
+) error {
+	currentWorkflowID := workflow.GetInfo(ctx).WorkflowExecution.ID
+	if currentWorkflowID == "default-test-workflow-id" {
+		// unit testing hack, see https://github.com/uber-go/cadence-client/issues/663
+		_ = workflow.Sleep(ctx, 10*time.Millisecond)
+	}
+	logger := workflow.GetLogger(ctx)
+	logger.Info("started", "currentWorkflowID", currentWorkflowID, "capacity", capacity)
+	if state == nil {
+		state = &SemaphoreState{}
+	}
+	err := workflow.SetQueryHandler(ctx, SemaphoreStateQueryName, func() (SemaphoreState, error) {
+		return SemaphoreState{
+			Holders: append([]SemaphoreHolder(nil), state.Holders...),
+			Waiters: append([]SemaphoreRequest(nil), state.Waiters...),
+		}, nil
+	})
+	if err != nil {
+		logger.Info("SetQueryHandler failed.", "Error", err)
+		return err
+	}
+
+	requestCh := workflow.GetSignalChannel(ctx, RequestSemaphoreSignalName)
+	enqueue := func(request SemaphoreRequest) {
+		for _, holder := range state.Holders {
+			if holder.WorkflowID == request.WorkflowID {
+				return
+			}
+		}
+		for _, waiter := range state.Waiters {
+			if waiter.WorkflowID == request.WorkflowID {
+				return
+			}
+		}
+		if rejection := validate(request, capacity); rejection != "" {
+			// The requester would wait forever or get an expired lease, it is told why instead
+			logger.Info("rejected invalid request", "WorkflowID", request.WorkflowID, "Rejection", rejection)
+			err := workflow.SignalExternalWorkflow(ctx, request.WorkflowID, "", RejectSemaphoreSignalName,
+				rejection).Get(ctx, nil)
+			if err != nil {
+				logger.Info("SignalExternalWorkflow error", "Error", err)
+			}
+			return
+		}
+		state.Waiters = append(state.Waiters, request)
+	}
+	drainRequests := func() {
+		var request SemaphoreRequest
+		for requestCh.ReceiveAsync(&request) {
+			enqueue(request)
+			request = SemaphoreRequest{}
+		}
+	}
+	release := func(workflowID string) {
+		for i, holder := range state.Holders {
+			if holder.WorkflowID == workflowID {
+				state.Holders = append(state.Holders[:i], state.Holders[i+1:]...)
+				return
+			}
+		}
+	}
+
+	for {
+		drainRequests()
+		used := 0
+		for _, holder := range state.Holders {
+			used += holder.Permits
+		}
+		for len(state.Waiters) > 0 && state.Waiters[0].Permits <= capacity-used {
+			request := state.Waiters[0]
+			state.Waiters = state.Waiters[1:]
+			var releaseLockChannelName string
+			_ = workflow.SideEffect(ctx, func(ctx workflow.Context) interface{} {
+				return generateUnlockChannelName(request.WorkflowID)
+			}).Get(&releaseLockChannelName)
+			err := workflow.SignalExternalWorkflow(ctx, request.WorkflowID, "",
+				AcquireSemaphoreSignalName, releaseLockChannelName).Get(ctx, nil)
+			if err != nil {
+				// The requesting workflow is closed, its permits are not granted.
+				logger.Info("SignalExternalWorkflow error", "Error", err)
+				continue
+			}
+			logger.Info("permits granted", "WorkflowID", request.WorkflowID, "Permits", request.Permits)
+			used += request.Permits
+			state.Holders = append(state.Holders, SemaphoreHolder{
+				WorkflowID:             request.WorkflowID,
+				Permits:                request.Permits,
+				ReleaseLockChannelName: releaseLockChannelName,
+				ExpiresAt:              workflow.Now(ctx).Add(request.Timeout),
+			})
+		}
+
+		if len(state.Holders) == 0 {
+			var request SemaphoreRequest
+			// A request may land in the same workflow task as the timeout, serve it instead of dropping it
+			if ok, _ := requestCh.ReceiveWithTimeout(ctx, idleTimeout, &request); !ok && !requestCh.ReceiveAsync(&request) {
+				logger.Info("no more signals")
+				return nil
+			}
+			enqueue(request)
+			continue
+		}
+
+		// Wait until a holder releases its permits or its lease expires. New requests are queued meanwhile.
+		expiresAt := state.Holders[0].ExpiresAt
+		for _, holder := range state.Holders {
+			if holder.ExpiresAt.Before(expiresAt) {
+				expiresAt = holder.ExpiresAt
+			}
+		}
+		leaseLeft := expiresAt.Sub(workflow.Now(ctx))
+		if leaseLeft < 0 {
+			leaseLeft = 0
+		}
+		timerCtx, cancelTimer := workflow.WithCancel(ctx)
+		selector := workflow.NewSelector(ctx)
+		selector.AddFuture(workflow.NewTimer(timerCtx, leaseLeft), func(f workflow.Future) {
+			now := workflow.Now(ctx)
+			for _, holder := range append([]SemaphoreHolder(nil), state.Holders...) {
+				if !holder.ExpiresAt.After(now) {
+					logger.Info("unlockTimeout exceeded", "holder", holder.WorkflowID)
+					release(holder.WorkflowID)
+				}
+			}
+		})
+		for _, holder := range state.Holders {
+			workflowID := holder.WorkflowID
+			selector.AddReceive(workflow.GetSignalChannel(ctx, holder.ReleaseLockChannelName), func(c workflow.ReceiveChannel, more bool) {
+				var ack string
+				c.Receive(ctx, &ack)
+				logger.Info("release signal received", "holder", workflowID)
+				release(workflowID)
+			})
+		}
+		selector.AddReceive(requestCh, func(c workflow.ReceiveChannel, more bool) {
+			var request SemaphoreRequest
+			c.Receive(ctx, &request)
+			enqueue(request)
+		})
+		selector.Select(ctx)
+		cancelTimer()
+
+		if workflow.GetInfo(ctx).GetContinueAsNewSuggested() {
+			drainRequests()
+			for _, holder := range append([]SemaphoreHolder(nil), state.Holders...) {
+				var ack string
+				if workflow.GetSignalChannel(ctx, holder.ReleaseLockChannelName).ReceiveAsync(&ack) {
+					release(holder.WorkflowID)
+				}
+			}
+			logger.Info("continuing as new", "holders", len(state.Holders), "waiters", len(state.Waiters))
+			return workflow.NewContinueAsNewError(ctx, SemaphoreWorkflow, capacity, state)
+		}
+	}
+}
+
+// SignalWithStartSemaphoreWorkflowActivity ...
+func SignalWithStartSemaphoreWorkflowActivity(
+	ctx context.Context,
+	workflowID string,
+	capacity int,
+	request SemaphoreRequest,
+) (*workflow.Execution, error) {
+
+	c := ctx.Value(ClientContextKey).(client.Client)
+	workflowOptions := client.StartWorkflowOptions{
+		ID:        workflowID,
+		TaskQueue: "mutex",
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    5,
+		},
+	}
+	wr, err := c.SignalWithStartWorkflow(
+		ctx, workflowID, RequestSemaphoreSignalName, request,
+		workflowOptions, SemaphoreWorkflow, capacity, nil)
+	if err != nil {
+		activity.GetLogger(ctx).Error("Unable to signal with start workflow", "Error", err)
+		return nil, err
+	}
+	activity.GetLogger(ctx).Info("Signaled and started Workflow", "WorkflowID", wr.GetID(), "RunID", wr.GetRunID())
+
+	return &workflow.Execution{
+		ID:    wr.GetID(),
+		RunID: wr.GetRunID(),
+	}, nil
+}
+
+// semaphoreWorkflowID returns the ID of the semaphore workflow of the given kind that guards resourceID
+func semaphoreWorkflowID(kind string, namespace string, resourceID string) string {
+	return fmt.Sprintf("%s:%s:%s", kind, namespace, resourceID)
+}
+
+// SampleWorkflowWithSemaphore calls a rate-limited downstream API while at most two workflows do so at the same time
+func SampleWorkflowWithSemaphore(
+	ctx workflow.Context,
+	resourceID string,
+) error {
+	currentWorkflowID := workflow.GetInfo(ctx).WorkflowExecution.ID
+	logger := workflow.GetLogger(ctx)
+	logger.Info("started", "currentWorkflowID", currentWorkflowID, "resourceID", resourceID)
+
+	semaphore := NewSemaphore(currentWorkflowID, "TestUseCase", 2)
+	unlockFunc, err := semaphore.Acquire(ctx, resourceID, 1, 10*time.Minute)
+	if err != nil {
+		return err
+	}
+	logger.Info("permit acquired")
+
+	// emulate a call to the rate-limited API
+	_ = workflow.Sleep(ctx, 10*time.Second)
+
+	_ = unlockFunc()
+
+	logger.Info("finished")
+	return nil
+}
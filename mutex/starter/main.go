@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 
 	"github.com/pborman/uuid"
@@ -45,4 +46,18 @@ func main() {
 	} else {
 		log.Println("Started workflow2", "WorkflowID", we.GetID(), "RunID", we.GetRunID())
 	}
+
+	// Three workflows share a semaphore with two permits, so the third one waits for one of the others.
+	for i := 1; i <= 3; i++ {
+		semaphoreWorkflowOptions := client.StartWorkflowOptions{
+			ID:        fmt.Sprintf("SampleWorkflow%dWithSemaphore_%s", i, uuid.New()),
+			TaskQueue: "mutex",
+		}
+		we, err = c.ExecuteWorkflow(context.Background(), semaphoreWorkflowOptions, mutex.SampleWorkflowWithSemaphore, resourceID)
+		if err != nil {
+			log.Fatalln("Unable to execute semaphore workflow", err)
+		} else {
+			log.Println("Started semaphore workflow", "WorkflowID", we.GetID(), "RunID", we.GetRunID())
+		}
+	}
 }
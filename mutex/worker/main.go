@@ -7,17 +7,19 @@ import (
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/worker"
 
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 	"github.com/temporalio/samples-go/mutex"
 )
 
 func main() {
 	// The client and worker are heavyweight objects that should be created once per process.
-	c, err := client.Dial(client.Options{
+	c, shutdown, err := bootstrap.Dial(client.Options{
 		HostPort: client.DefaultHostPort,
 	})
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
 	w := worker.New(c, "mutex", worker.Options{
@@ -25,8 +27,11 @@ func main() {
 	})
 
 	w.RegisterActivity(mutex.SignalWithStartMutexWorkflowActivity)
+	w.RegisterActivity(mutex.SignalWithStartSemaphoreWorkflowActivity)
 	w.RegisterWorkflow(mutex.MutexWorkflow)
+	w.RegisterWorkflow(mutex.SemaphoreWorkflow)
 	w.RegisterWorkflow(mutex.SampleWorkflowWithMutex)
+	w.RegisterWorkflow(mutex.SampleWorkflowWithSemaphore)
 
 	err = w.Run(worker.InterruptCh())
 	if err != nil {
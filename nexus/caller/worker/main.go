@@ -5,10 +5,10 @@ import (
 	"log"
 	"os"
 
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 	"github.com/temporalio/samples-go/nexus/caller"
 	"github.com/temporalio/samples-go/nexus/options"
 
-	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/worker"
 )
 
@@ -18,10 +18,11 @@ func main() {
 	if err != nil {
 		log.Fatalf("Invalid arguments: %v", err)
 	}
-	c, err := client.Dial(clientOptions)
+	c, shutdown, err := bootstrap.Dial(clientOptions)
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
 	w := worker.New(c, caller.TaskQueue, worker.Options{})
@@ -34,4 +35,5 @@ func main() {
 		log.Fatalln("Unable to start worker", err)
 	}
 }
-// @@@SNIPEND
\ No newline at end of file
+
+// @@@SNIPEND
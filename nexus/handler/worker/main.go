@@ -5,10 +5,10 @@ import (
 	"log"
 	"os"
 
-	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/worker"
 
 	"github.com/nexus-rpc/sdk-go/nexus"
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 	"github.com/temporalio/samples-go/nexus/handler"
 	"github.com/temporalio/samples-go/nexus/options"
 	"github.com/temporalio/samples-go/nexus/service"
@@ -24,10 +24,11 @@ func main() {
 	if err != nil {
 		log.Fatalf("Invalid arguments: %v", err)
 	}
-	c, err := client.Dial(clientOptions)
+	c, shutdown, err := bootstrap.Dial(clientOptions)
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
 	w := worker.New(c, taskQueue, worker.Options{})
@@ -44,4 +45,5 @@ func main() {
 		log.Fatalln("Unable to start worker", err)
 	}
 }
-// @@@SNIPEND
\ No newline at end of file
+
+// @@@SNIPEND
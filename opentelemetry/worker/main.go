@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 	otelworkflow "github.com/temporalio/samples-go/opentelemetry"
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/contrib/opentelemetry"
@@ -19,7 +20,7 @@ func main() {
 	if err != nil {
 		log.Fatalln("Unable to create a global trace provider", err)
 	}
-	
+
 	defer func() {
 		if err := tp.Shutdown(ctx); err != nil {
 			log.Println("Error shutting down trace provider:", err)
@@ -36,10 +37,11 @@ func main() {
 	}
 
 	// The client and worker are heavyweight objects that should be created once per process.
-	c, err := client.Dial(options)
+	c, shutdown, err := bootstrap.Dial(options)
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
 	w := worker.New(c, "otel", worker.Options{})
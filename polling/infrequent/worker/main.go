@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 	"github.com/temporalio/samples-go/polling"
 	"github.com/temporalio/samples-go/polling/infrequent"
 
@@ -12,12 +13,13 @@ import (
 
 func main() {
 	// The client and worker are heavyweight objects that should be created once per process.
-	c, err := client.Dial(client.Options{
+	c, shutdown, err := bootstrap.Dial(client.Options{
 		HostPort: client.DefaultHostPort,
 	})
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
 	w := worker.New(c, infrequent.TaskQueueName, worker.Options{})
@@ -1,33 +1,54 @@
 package cache
 
-import "time"
+import (
+	"context"
+	"time"
 
-// A Cache is a generalized interface to a cache.  See cache.LRU for a specific
+	"go.temporal.io/sdk/client"
+)
+
+// A Cache is a generalized interface to a cache of values of type V by keys of type K. See cache.LRU for a specific
 // implementation (bounded cache with LRU eviction)
-type Cache interface {
-	// Get retrieves an element based on a key, returning nil if the element
+type Cache[K comparable, V any] interface {
+	// Get retrieves an element based on a key, returning false if the element
 	// does not exist
-	Get(key string) interface{}
+	Get(key K) (V, bool)
+
+	// GetOrLoad retrieves an element based on a key, loading it with the
+	// Loader of the Options if it does not exist. Concurrent loads of the same
+	// key are shared.
+	GetOrLoad(ctx context.Context, key K) (V, error)
 
 	// Put adds an element to the cache, returning the previous element
-	Put(key string, value interface{}) interface{}
+	Put(key K, value V) V
 
 	// PutIfNotExist puts a value associated with a given key if it does not exist
-	PutIfNotExist(key string, value interface{}) (interface{}, error)
+	PutIfNotExist(key K, value V) (V, error)
 
 	// Delete deletes an element in the cache
-	Delete(key string)
+	Delete(key K)
 
 	// Release decrements the ref count of a pinned element. If the ref count
 	// drops to 0, the element can be evicted from the cache.
-	Release(key string)
+	Release(key K)
 
 	// Size returns the number of entries currently stored in the Cache
 	Size() int
+
+	// Bytes returns the size of the entries currently stored in the Cache, as
+	// computed by the Sizer of the Options
+	Bytes() int64
 }
 
+// Names of the metrics emitted by a cache
+const (
+	HitsCounterName      = "cache_hits"
+	MissesCounterName    = "cache_misses"
+	EvictionsCounterName = "cache_evictions"
+)
+
 // Options control the behavior of the cache
-type Options struct {
+type Options[K comparable, V any] struct {
 	// TTL controls the time-to-live for a given cache entry.  Cache entries that
 	// are older than the TTL will not be returned
 	TTL time.Duration
@@ -40,11 +61,28 @@ type Options struct {
 
 	// RemovedFunc is an optional function called when an element
 	// is scheduled for deletion
-	RemovedFunc RemovedFunc
+	RemovedFunc RemovedFunc[V]
+
+	// Sizer is an optional function returning the size in bytes of an element.
+	// Sizes are only accounted when it is set.
+	Sizer func(V) int64
+
+	// MaxBytes evicts elements once the total size of the cache exceeds it.
+	// Unlimited when 0.
+	MaxBytes int64
+
+	// Loader is an optional function loading the elements missing in
+	// GetOrLoad
+	Loader func(ctx context.Context, key K) (V, error)
+
+	// MetricsHandler receives the hit, miss and eviction counters of the
+	// cache. Tags may be added with MetricsHandler.WithTags to tell caches
+	// apart. Defaults to client.MetricsNopHandler.
+	MetricsHandler client.MetricsHandler
 }
 
 // RemovedFunc is a type for notifying applications when an item is
 // scheduled for removal from the Cache. If f is a function with the
-// appropriate signature and i is the interface{} scheduled for
-// deletion, Cache calls go f(i)
-type RemovedFunc func(interface{})
+// appropriate signature and v is the value scheduled for
+// deletion, Cache calls go f(v)
+type RemovedFunc[V any] func(V)
@@ -2,156 +2,267 @@ package cache
 
 import (
 	"container/list"
+	"context"
 	"errors"
 	"sync"
 	"time"
+
+	"go.temporal.io/sdk/client"
 )
 
 var (
 	// ErrCacheFull is returned if Put fails due to cache being filled with pinned elements
 	ErrCacheFull = errors.New("Cache capacity is fully occupied with pinned elements")
+	// ErrValueTooLarge is returned if Put fails due to the value being larger than the maximum size of the cache
+	ErrValueTooLarge = errors.New("Value is larger than the cache capacity")
+	// ErrNoLoader is returned by GetOrLoad if the cache has no Loader
+	ErrNoLoader = errors.New("Cache has no loader")
 )
 
 // lru is a concurrent fixed size cache that evicts elements in lru order
-type lru struct {
-	mut      sync.Mutex
-	byAccess *list.List
-	byKey    map[string]*list.Element
-	maxSize  int
-	ttl      time.Duration
-	pin      bool
-	rmFunc   RemovedFunc
-}
-
-// New creates a new cache with the given options
-func New(maxSize int, opts *Options) Cache {
+type lru[K comparable, V any] struct {
+	mut       sync.Mutex
+	byAccess  *list.List
+	byKey     map[K]*list.Element
+	loads     map[K]*load[V]
+	maxSize   int
+	maxBytes  int64
+	bytes     int64
+	ttl       time.Duration
+	pin       bool
+	rmFunc    RemovedFunc[V]
+	sizer     func(V) int64
+	loader    func(ctx context.Context, key K) (V, error)
+	hits      client.MetricsCounter
+	misses    client.MetricsCounter
+	evictions client.MetricsCounter
+}
+
+// load is a call of the loader shared by concurrent GetOrLoad of a key
+type load[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// New creates a new cache with the given options. A maxSize of 0 does not
+// limit the number of entries.
+func New[K comparable, V any](maxSize int, opts *Options[K, V]) Cache[K, V] {
 	if opts == nil {
-		opts = &Options{}
+		opts = &Options[K, V]{}
+	}
+	metricsHandler := opts.MetricsHandler
+	if metricsHandler == nil {
+		metricsHandler = client.MetricsNopHandler
 	}
 
-	return &lru{
-		byAccess: list.New(),
-		byKey:    make(map[string]*list.Element, opts.InitialCapacity),
-		ttl:      opts.TTL,
-		maxSize:  maxSize,
-		pin:      opts.Pin,
-		rmFunc:   opts.RemovedFunc,
+	return &lru[K, V]{
+		byAccess:  list.New(),
+		byKey:     make(map[K]*list.Element, opts.InitialCapacity),
+		loads:     make(map[K]*load[V]),
+		ttl:       opts.TTL,
+		maxSize:   maxSize,
+		maxBytes:  opts.MaxBytes,
+		pin:       opts.Pin,
+		rmFunc:    opts.RemovedFunc,
+		sizer:     opts.Sizer,
+		loader:    opts.Loader,
+		hits:      metricsHandler.Counter(HitsCounterName),
+		misses:    metricsHandler.Counter(MissesCounterName),
+		evictions: metricsHandler.Counter(EvictionsCounterName),
 	}
 }
 
 // NewLRU creates a new LRU cache of the given size, setting initial capacity
 // to the max size
-func NewLRU(maxSize int) Cache {
-	return New(maxSize, nil)
+func NewLRU[K comparable, V any](maxSize int) Cache[K, V] {
+	return New[K, V](maxSize, nil)
 }
 
 // NewLRUWithInitialCapacity creates a new LRU cache with an initial capacity
 // and a max size
-func NewLRUWithInitialCapacity(initialCapacity, maxSize int) Cache {
-	return New(maxSize, &Options{
+func NewLRUWithInitialCapacity[K comparable, V any](initialCapacity, maxSize int) Cache[K, V] {
+	return New(maxSize, &Options[K, V]{
 		InitialCapacity: initialCapacity,
 	})
 }
 
 // Get retrieves the value stored under the given key
-func (c *lru) Get(key string) interface{} {
+func (c *lru[K, V]) Get(key K) (V, bool) {
 	c.mut.Lock()
 	defer c.mut.Unlock()
 
-	elt := c.byKey[key]
-	if elt == nil {
-		return nil
+	value, ok := c.getInternal(key)
+	if ok {
+		c.hits.Inc(1)
+	} else {
+		c.misses.Inc(1)
 	}
+	return value, ok
+}
 
-	cacheEntry := elt.Value.(*cacheEntry)
-
-	if c.pin {
-		cacheEntry.refCount++
+// GetOrLoad retrieves the value stored under the given key, loading it if it
+// does not exist. Callers waiting for the load of another caller share its
+// result.
+func (c *lru[K, V]) GetOrLoad(ctx context.Context, key K) (V, error) {
+	var zero V
+	if c.loader == nil {
+		return zero, ErrNoLoader
 	}
 
-	if cacheEntry.refCount == 0 && !cacheEntry.expiration.IsZero() && time.Now().After(cacheEntry.expiration) {
-		// Entry has expired
-		if c.rmFunc != nil {
-			go c.rmFunc(cacheEntry.value)
+	c.mut.Lock()
+	if value, ok := c.getInternal(key); ok {
+		c.mut.Unlock()
+		c.hits.Inc(1)
+		return value, nil
+	}
+	c.misses.Inc(1)
+
+	if l, ok := c.loads[key]; ok {
+		// Another caller is loading the key, wait for its result
+		c.mut.Unlock()
+		select {
+		case <-l.done:
+		case <-ctx.Done():
+			return zero, ctx.Err()
 		}
-		c.byAccess.Remove(elt)
-		delete(c.byKey, cacheEntry.key)
-		return nil
+		if l.err == nil && c.pin {
+			c.mut.Lock()
+			if elt := c.byKey[key]; elt != nil {
+				elt.Value.(*cacheEntry[K, V]).refCount++
+			}
+			c.mut.Unlock()
+		}
+		return l.value, l.err
 	}
 
-	c.byAccess.MoveToFront(elt)
-	return cacheEntry.value
+	l := &load[V]{done: make(chan struct{})}
+	c.loads[key] = l
+	c.mut.Unlock()
+
+	l.value, l.err = c.loader(ctx, key)
+
+	c.mut.Lock()
+	delete(c.loads, key)
+	if l.err == nil {
+		// The loaded value is returned even if the cache is full with pinned
+		// elements, but a value put in the meantime takes precedence
+		if existing, ok, err := c.putInternal(key, l.value, false); err == nil && ok {
+			l.value = existing
+		}
+	}
+	c.mut.Unlock()
+	close(l.done)
+	return l.value, l.err
 }
 
 // Put puts a new value associated with a given key, returning the existing value (if present)
-func (c *lru) Put(key string, value interface{}) interface{} {
+func (c *lru[K, V]) Put(key K, value V) V {
 	if c.pin {
 		panic("Cannot use Put API in Pin mode. Use Delete and PutIfNotExist if necessary")
 	}
-	val, _ := c.putInternal(key, value, true)
-	return val
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	existing, _, _ := c.putInternal(key, value, true)
+	return existing
 }
 
 // PutIfNotExist puts a value associated with a given key if it does not exist
-func (c *lru) PutIfNotExist(key string, value interface{}) (interface{}, error) {
-	existing, err := c.putInternal(key, value, false)
+func (c *lru[K, V]) PutIfNotExist(key K, value V) (V, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	existing, ok, err := c.putInternal(key, value, false)
 	if err != nil {
-		return nil, err
+		var zero V
+		return zero, err
 	}
 
-	if existing == nil {
+	if !ok {
 		// This is a new value
-		return value, err
+		return value, nil
 	}
 
-	return existing, err
+	return existing, nil
 }
 
 // Delete deletes a key, value pair associated with a key
-func (c *lru) Delete(key string) {
+func (c *lru[K, V]) Delete(key K) {
 	c.mut.Lock()
 	defer c.mut.Unlock()
 
 	elt := c.byKey[key]
 	if elt != nil {
-		entry := c.byAccess.Remove(elt).(*cacheEntry)
-		if c.rmFunc != nil {
-			go c.rmFunc(entry.value)
-		}
-		delete(c.byKey, key)
+		c.remove(elt)
 	}
 }
 
 // Release decrements the ref count of a pinned element.
-func (c *lru) Release(key string) {
+func (c *lru[K, V]) Release(key K) {
 	c.mut.Lock()
 	defer c.mut.Unlock()
 
 	elt := c.byKey[key]
-	cacheEntry := elt.Value.(*cacheEntry)
+	if elt == nil {
+		return
+	}
+	cacheEntry := elt.Value.(*cacheEntry[K, V])
 	cacheEntry.refCount--
 }
 
 // Size returns the number of entries currently in the lru, useful if cache is not full
-func (c *lru) Size() int {
+func (c *lru[K, V]) Size() int {
 	c.mut.Lock()
 	defer c.mut.Unlock()
 
 	return len(c.byKey)
 }
 
-// Put puts a new value associated with a given key, returning the existing value (if present)
-// allowUpdate flag is used to control overwrite behavior if the value exists
-func (c *lru) putInternal(key string, value interface{}, allowUpdate bool) (interface{}, error) {
+// Bytes returns the size of the entries currently in the lru
+func (c *lru[K, V]) Bytes() int64 {
 	c.mut.Lock()
 	defer c.mut.Unlock()
 
+	return c.bytes
+}
+
+// getInternal retrieves the value stored under the given key, the caller holds the lock
+func (c *lru[K, V]) getInternal(key K) (V, bool) {
+	var zero V
+	elt := c.byKey[key]
+	if elt == nil {
+		return zero, false
+	}
+
+	cacheEntry := elt.Value.(*cacheEntry[K, V])
+
+	if c.pin {
+		cacheEntry.refCount++
+	}
+
+	if cacheEntry.refCount == 0 && !cacheEntry.expiration.IsZero() && time.Now().After(cacheEntry.expiration) {
+		// Entry has expired
+		c.evict(elt)
+		return zero, false
+	}
+
+	c.byAccess.MoveToFront(elt)
+	return cacheEntry.value, true
+}
+
+// putInternal puts a new value associated with a given key, returning the existing value (if present)
+// allowUpdate flag is used to control overwrite behavior if the value exists. The caller holds the lock.
+func (c *lru[K, V]) putInternal(key K, value V, allowUpdate bool) (V, bool, error) {
 	elt := c.byKey[key]
 	if elt != nil {
-		entry := elt.Value.(*cacheEntry)
+		entry := elt.Value.(*cacheEntry[K, V])
 		existing := entry.value
 		if allowUpdate {
+			size := c.size(value)
+			c.bytes += size - entry.size
 			entry.value = value
+			entry.size = size
 		}
 		if c.ttl != 0 {
 			entry.expiration = time.Now().Add(c.ttl)
@@ -160,12 +271,21 @@ func (c *lru) putInternal(key string, value interface{}, allowUpdate bool) (inte
 		if c.pin {
 			entry.refCount++
 		}
-		return existing, nil
+		if allowUpdate && (c.tooLarge(entry.size) || !c.evictOldest(elt)) {
+			// The new value does not fit
+			c.evict(elt)
+		}
+		return existing, true, nil
 	}
 
-	entry := &cacheEntry{
+	entry := &cacheEntry[K, V]{
 		key:   key,
 		value: value,
+		size:  c.size(value),
+	}
+	if c.tooLarge(entry.size) {
+		var zero V
+		return zero, false, ErrValueTooLarge
 	}
 
 	if c.pin {
@@ -176,31 +296,70 @@ func (c *lru) putInternal(key string, value interface{}, allowUpdate bool) (inte
 		entry.expiration = time.Now().Add(c.ttl)
 	}
 
-	c.byKey[key] = c.byAccess.PushFront(entry)
-	if len(c.byKey) == c.maxSize {
-		oldest := c.byAccess.Back().Value.(*cacheEntry)
+	elt = c.byAccess.PushFront(entry)
+	c.byKey[key] = elt
+	c.bytes += entry.size
+	if !c.evictOldest(elt) {
+		// Cache is full with pinned elements
+		// revert the insert and return
+		c.byAccess.Remove(elt)
+		delete(c.byKey, key)
+		c.bytes -= entry.size
+		var zero V
+		return zero, false, ErrCacheFull
+	}
 
-		if oldest.refCount > 0 {
-			// Cache is full with pinned elements
-			// revert the insert and return
-			c.byAccess.Remove(c.byAccess.Front())
-			delete(c.byKey, key)
-			return nil, ErrCacheFull
-		}
+	var zero V
+	return zero, false, nil
+}
 
-		c.byAccess.Remove(c.byAccess.Back())
-		if c.rmFunc != nil {
-			go c.rmFunc(oldest.value)
+// evictOldest evicts the least recently used elements which are not pinned,
+// except keep, until the cache fits in its limits. It returns false if the
+// cache is still too large.
+func (c *lru[K, V]) evictOldest(keep *list.Element) bool {
+	for elt := c.byAccess.Back(); elt != nil && c.overflows(); {
+		prev := elt.Prev()
+		if elt != keep && elt.Value.(*cacheEntry[K, V]).refCount == 0 {
+			c.evict(elt)
 		}
-		delete(c.byKey, oldest.key)
+		elt = prev
 	}
+	return !c.overflows()
+}
+
+func (c *lru[K, V]) overflows() bool {
+	return (c.maxSize > 0 && len(c.byKey) > c.maxSize) || (c.maxBytes > 0 && c.bytes > c.maxBytes)
+}
 
-	return nil, nil
+func (c *lru[K, V]) tooLarge(size int64) bool {
+	return c.maxBytes > 0 && size > c.maxBytes
+}
+
+func (c *lru[K, V]) evict(elt *list.Element) {
+	c.remove(elt)
+	c.evictions.Inc(1)
+}
+
+func (c *lru[K, V]) remove(elt *list.Element) {
+	entry := c.byAccess.Remove(elt).(*cacheEntry[K, V])
+	delete(c.byKey, entry.key)
+	c.bytes -= entry.size
+	if c.rmFunc != nil {
+		go c.rmFunc(entry.value)
+	}
+}
+
+func (c *lru[K, V]) size(value V) int64 {
+	if c.sizer == nil {
+		return 0
+	}
+	return c.sizer(value)
 }
 
-type cacheEntry struct {
-	key        string
+type cacheEntry[K comparable, V any] struct {
+	key        K
 	expiration time.Time
-	value      interface{}
+	value      V
+	size       int64
 	refCount   int
 }
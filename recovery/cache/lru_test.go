@@ -0,0 +1,260 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/client"
+)
+
+// testMetricsHandler captures the counters of a cache.
+type testMetricsHandler struct {
+	client.MetricsHandler
+	mut      sync.Mutex
+	counters map[string]*atomic.Int64
+}
+
+func newTestMetricsHandler() *testMetricsHandler {
+	return &testMetricsHandler{MetricsHandler: client.MetricsNopHandler, counters: make(map[string]*atomic.Int64)}
+}
+
+func (h *testMetricsHandler) Counter(name string) client.MetricsCounter {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	if h.counters[name] == nil {
+		h.counters[name] = &atomic.Int64{}
+	}
+	return counterFunc(h.counters[name].Add)
+}
+
+func (h *testMetricsHandler) value(name string) int64 {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	if h.counters[name] == nil {
+		return 0
+	}
+	return h.counters[name].Load()
+}
+
+type counterFunc func(int64) int64
+
+func (f counterFunc) Inc(d int64) { f(d) }
+
+func Test_LRU(t *testing.T) {
+	metrics := newTestMetricsHandler()
+	removed := make(chan int, 10)
+	cache := New(3, &Options[string, int]{
+		MetricsHandler: metrics,
+		RemovedFunc:    func(v int) { removed <- v },
+	})
+
+	for i := 1; i <= 3; i++ {
+		require.Zero(t, cache.Put(strconv.Itoa(i), i))
+	}
+	require.Equal(t, 3, cache.Size())
+	// "1" becomes the most recently used, so "2" is evicted by "4"
+	value, ok := cache.Get("1")
+	require.True(t, ok)
+	require.Equal(t, 1, value)
+	cache.Put("4", 4)
+	require.Equal(t, 2, <-removed)
+	_, ok = cache.Get("2")
+	require.False(t, ok)
+	require.Equal(t, 1, cache.Put("1", 10))
+	value, _ = cache.Get("1")
+	require.Equal(t, 10, value)
+
+	cache.Delete("3")
+	require.Equal(t, 3, <-removed)
+	require.Equal(t, 2, cache.Size())
+
+	require.Equal(t, int64(2), metrics.value(HitsCounterName))
+	require.Equal(t, int64(1), metrics.value(MissesCounterName))
+	require.Equal(t, int64(1), metrics.value(EvictionsCounterName))
+}
+
+func Test_LRU_Bytes(t *testing.T) {
+	metrics := newTestMetricsHandler()
+	cache := New(0, &Options[int, string]{
+		Sizer:          func(v string) int64 { return int64(len(v)) },
+		MaxBytes:       10,
+		MetricsHandler: metrics,
+	})
+
+	cache.Put(1, "aaaa")
+	cache.Put(2, "bbbb")
+	require.Equal(t, int64(8), cache.Bytes())
+	cache.Put(3, "cccc")
+	require.Equal(t, 2, cache.Size())
+	require.Equal(t, int64(8), cache.Bytes())
+	_, ok := cache.Get(1)
+	require.False(t, ok)
+
+	// Growing a value evicts the others
+	cache.Put(3, "cccccccc")
+	require.Equal(t, 1, cache.Size())
+	require.Equal(t, int64(8), cache.Bytes())
+
+	_, err := cache.PutIfNotExist(4, "ddddddddddd")
+	require.ErrorIs(t, err, ErrValueTooLarge)
+	cache.Put(3, "ccccccccccc")
+	require.Zero(t, cache.Size())
+	require.Zero(t, cache.Bytes())
+	require.Equal(t, int64(3), metrics.value(EvictionsCounterName))
+}
+
+func Test_LRU_TTL(t *testing.T) {
+	cache := New(10, &Options[string, string]{TTL: 10 * time.Millisecond})
+	cache.Put("a", "a")
+	_, ok := cache.Get("a")
+	require.True(t, ok)
+	time.Sleep(20 * time.Millisecond)
+	_, ok = cache.Get("a")
+	require.False(t, ok)
+	require.Zero(t, cache.Size())
+}
+
+func Test_LRU_Pin(t *testing.T) {
+	cache := New(2, &Options[string, int]{Pin: true})
+	for _, key := range []string{"a", "b"} {
+		_, err := cache.PutIfNotExist(key, 1)
+		require.NoError(t, err)
+	}
+	_, err := cache.PutIfNotExist("c", 1)
+	require.ErrorIs(t, err, ErrCacheFull)
+
+	cache.Release("a")
+	value, err := cache.PutIfNotExist("c", 3)
+	require.NoError(t, err)
+	require.Equal(t, 3, value)
+	_, ok := cache.Get("a")
+	require.False(t, ok)
+	require.Panics(t, func() { cache.Put("d", 4) })
+}
+
+func Test_LRU_GetOrLoad(t *testing.T) {
+	metrics := newTestMetricsHandler()
+	var loads atomic.Int32
+	loading, release := make(chan struct{}, 1), make(chan struct{})
+	cache := New(10, &Options[string, string]{
+		MetricsHandler: metrics,
+		Loader: func(ctx context.Context, key string) (string, error) {
+			loads.Add(1)
+			select {
+			case loading <- struct{}{}:
+			default:
+			}
+			<-release
+			if key == "invalid" {
+				return "", errors.New("invalid key")
+			}
+			return "value of " + key, nil
+		},
+	})
+
+	// Concurrent loads of a key call the loader once
+	var wg sync.WaitGroup
+	values, errs := make([]string, 10), make([]error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values[i], errs[i] = cache.GetOrLoad(context.Background(), "a")
+		}(i)
+	}
+	<-loading
+	close(release)
+	wg.Wait()
+	for i := range values {
+		require.NoError(t, errs[i])
+		require.Equal(t, "value of a", values[i])
+	}
+	require.Equal(t, int32(1), loads.Load())
+	require.Equal(t, int64(10), metrics.value(HitsCounterName)+metrics.value(MissesCounterName))
+
+	value, ok := cache.Get("a")
+	require.True(t, ok)
+	require.Equal(t, "value of a", value)
+
+	// Errors are not cached
+	for i := 0; i < 2; i++ {
+		_, err := cache.GetOrLoad(context.Background(), "invalid")
+		require.EqualError(t, err, "invalid key")
+	}
+	require.Equal(t, int32(3), loads.Load())
+	require.Equal(t, 1, cache.Size())
+
+	_, err := NewLRU[string, string](10).GetOrLoad(context.Background(), "a")
+	require.ErrorIs(t, err, ErrNoLoader)
+}
+
+func Test_LRU_GetOrLoad_Cancelled(t *testing.T) {
+	loading, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	cache := New(10, &Options[string, string]{
+		Loader: func(ctx context.Context, key string) (string, error) {
+			close(loading)
+			<-release
+			return key, nil
+		},
+	})
+	go func() { _, _ = cache.GetOrLoad(context.Background(), "a") }()
+	<-loading
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := cache.GetOrLoad(ctx, "a")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+const benchmarkKeys = 1 << 12
+
+func newBenchmarkCache(loader func(ctx context.Context, key int) (int, error)) Cache[int, int] {
+	return New(benchmarkKeys/2, &Options[int, int]{
+		Sizer:          func(int) int64 { return 8 },
+		MetricsHandler: newTestMetricsHandler(),
+		Loader:         loader,
+	})
+}
+
+func BenchmarkLRU_Get(b *testing.B) {
+	cache := newBenchmarkCache(nil)
+	for i := 0; i < benchmarkKeys; i++ {
+		cache.Put(i, i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			cache.Get(i % benchmarkKeys)
+		}
+	})
+}
+
+func BenchmarkLRU_Put(b *testing.B) {
+	cache := newBenchmarkCache(nil)
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			cache.Put(i%benchmarkKeys, i)
+		}
+	})
+}
+
+func BenchmarkLRU_GetOrLoad(b *testing.B) {
+	cache := newBenchmarkCache(func(ctx context.Context, key int) (int, error) {
+		return key, nil
+	})
+	ctx := context.Background()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			if _, err := cache.GetOrLoad(ctx, i%benchmarkKeys); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
@@ -3,48 +3,95 @@ package recovery
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
-	"github.com/pborman/uuid"
-	commonpb "go.temporal.io/api/common/v1"
 	enumspb "go.temporal.io/api/enums/v1"
-	filterpb "go.temporal.io/api/filter/v1"
 	historypb "go.temporal.io/api/history/v1"
 	"go.temporal.io/api/workflowservice/v1"
 	"go.temporal.io/sdk/activity"
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
-	"google.golang.org/protobuf/types/known/timestamppb"
-
-	"github.com/temporalio/samples-go/recovery/cache"
+	"golang.org/x/time/rate"
 )
 
 type (
-	// Params is the input parameters to RecoveryWorkflow
+	// Params is the input parameters to RecoverWorkflow
 	Params struct {
-		ID          string
-		Type        string
+		// Query is the visibility query of the executions to recover.
+		// When empty, the running executions of Type are recovered.
+		Query string
+		Type  string
+		// Concurrency is the number of parallel RecoverExecutions activities per page.
 		Concurrency int
-	}
-
-	// ListOpenExecutionsResult is the result returned from listOpenExecutions activity
-	ListOpenExecutionsResult struct {
-		ID     string
-		Count  int
-		HostID string
-	}
-
-	// RestartParams are parameters extracted from StartWorkflowExecution history event
-	RestartParams struct {
-		Options client.StartWorkflowOptions
-		State   UserState
-	}
-
-	// SignalParams are the parameters extracted from SignalWorkflowExecution history event
-	SignalParams struct {
-		Name string
-		Data TripEvent
+		// DryRun reports the executions which would be restarted, without terminating or starting any.
+		DryRun bool
+		// RecoveriesPerSecond limits the rate of recovered executions across the parallel activities.
+		// Unlimited when 0.
+		RecoveriesPerSecond float64
+		// PageSize is the number of executions listed per page, 100 by default.
+		PageSize int
+		// PagesPerRun is the number of pages recovered before continuing as new, 10 by default.
+		PagesPerRun int
+
+		// Cursor is the page token of the next page to recover, carried across continue-as-new.
+		Cursor []byte
+		// StartedBefore excludes the executions started after the recovery, including the restarted ones.
+		// Set by the first run.
+		StartedBefore time.Time
+		// Result accumulates the results of the previous runs.
+		Result RecoveryResult
+	}
+
+	// RecoveryResult is the result returned from RecoverWorkflow and RecoverExecutions activity.
+	// In DryRun mode, Restarted counts the executions which would be restarted.
+	RecoveryResult struct {
+		Restarted int
+		Skipped   int
+		Failed    int
+		// Failures are the first maxFailures failed executions.
+		Failures []RecoveryFailure
+	}
+
+	// RecoveryFailure is an execution which failed to be recovered
+	RecoveryFailure struct {
+		WorkflowID string
+		RunID      string
+		Error      string
+	}
+
+	// Execution is a workflow execution to recover
+	Execution struct {
+		WorkflowID string
+		RunID      string
+		Type       string
+	}
+
+	// ListExecutionsInput is the input to ListExecutions activity
+	ListExecutionsInput struct {
+		Query         string
+		PageSize      int
+		NextPageToken []byte
+	}
+
+	// ListExecutionsResult is the result returned from ListExecutions activity
+	ListExecutionsResult struct {
+		Executions    []Execution
+		NextPageToken []byte
+	}
+
+	// RecoverExecutionsInput is the input to RecoverExecutions activity
+	RecoverExecutionsInput struct {
+		Executions          []Execution
+		DryRun              bool
+		RecoveriesPerSecond float64
+	}
+
+	// recoveryProgress is recorded in heartbeats of RecoverExecutions activity
+	recoveryProgress struct {
+		Next   int
+		Result RecoveryResult
 	}
 )
 
@@ -54,326 +101,343 @@ type ClientKey int
 const (
 	// TemporalClientKey for retrieving client from context
 	TemporalClientKey ClientKey = iota
-	// WorkflowExecutionCacheKey for retrieving executions cache from context
-	WorkflowExecutionCacheKey
+	// RegistryKey for retrieving the Registry of recoverers from context
+	RegistryKey
 )
 
-// HostID - Use a new uuid just for demo so we can run 2 host specific activity workers on same machine.
-// In real world case, you would use a hostname or ip address as HostID.
-var HostID = "recovery_" + uuid.New()
+const maxFailures = 100
 
 var (
 	// ErrClientNotFound when client is not found on context
 	ErrClientNotFound = errors.New("failed to retrieve client from context")
-	// ErrExecutionCacheNotFound when executions cache is not found on context
-	ErrExecutionCacheNotFound = errors.New("failed to retrieve cache from context")
+	// ErrRegistryNotFound when registry is not found on context
+	ErrRegistryNotFound = errors.New("failed to retrieve registry from context")
 )
 
-// RecoverWorkflow is the workflow implementation to recover TripWorkflow executions
-func RecoverWorkflow(ctx workflow.Context, params Params) error {
+// RecoverWorkflow is the workflow implementation to recover the executions matching a visibility query.
+// Every execution is terminated and restarted by the Recoverer registered for its workflow type, which also extracts
+// the signals replayed to the new run. The workflow pages through the executions and continues as new every
+// PagesPerRun pages, with the cursor of the next page.
+func RecoverWorkflow(ctx workflow.Context, params Params) (RecoveryResult, error) {
 	logger := workflow.GetLogger(ctx)
-	logger.Info("Recover workflow started.")
+	logger.Info("Recover workflow started.", "Restarted", params.Result.Restarted, "Skipped", params.Result.Skipped,
+		"Failed", params.Result.Failed)
 
-	ao := workflow.ActivityOptions{
-		StartToCloseTimeout: 10 * time.Minute,
-		HeartbeatTimeout:    30 * time.Second,
+	if params.StartedBefore.IsZero() {
+		params.StartedBefore = workflow.GetInfo(ctx).WorkflowStartTime
 	}
-	ctx = workflow.WithActivityOptions(ctx, ao)
-
-	var result ListOpenExecutionsResult
-	err := workflow.ExecuteActivity(ctx, ListOpenExecutions, params.Type).Get(ctx, &result)
+	query, err := params.query()
 	if err != nil {
-		logger.Error("Failed to list open workflow executions.", "Error", err)
-		return err
+		return params.Result, temporal.NewNonRetryableApplicationError(err.Error(), "InvalidParams", nil)
 	}
 
+	pageSize := 100
+	if params.PageSize > 0 {
+		pageSize = params.PageSize
+	}
+	pagesPerRun := 10
+	if params.PagesPerRun > 0 {
+		pagesPerRun = params.PagesPerRun
+	}
 	concurrency := 1
 	if params.Concurrency > 0 {
 		concurrency = params.Concurrency
 	}
 
-	if result.Count < concurrency {
-		concurrency = result.Count
-	}
-
-	batchSize := result.Count / concurrency
-	if result.Count%concurrency != 0 {
-		batchSize++
-	}
-
+	listCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+	})
 	// Setup retry policy for recovery activity
-	info := workflow.GetInfo(ctx)
-	expiration := info.WorkflowExecutionTimeout
 	retryPolicy := &temporal.RetryPolicy{
 		InitialInterval:    time.Second,
 		BackoffCoefficient: 2,
 		MaximumInterval:    10 * time.Second,
 		MaximumAttempts:    100,
 	}
-	ao = workflow.ActivityOptions{
-		StartToCloseTimeout: expiration,
+	recoverCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Hour,
 		HeartbeatTimeout:    30 * time.Second,
 		RetryPolicy:         retryPolicy,
-	}
-	ctx = workflow.WithActivityOptions(ctx, ao)
-
-	doneCh := workflow.NewChannel(ctx)
-	for i := 0; i < concurrency; i++ {
-		startIndex := i * batchSize
+	})
+
+	for page := 1; ; page++ {
+		var list ListExecutionsResult
+		input := ListExecutionsInput{Query: query, PageSize: pageSize, NextPageToken: params.Cursor}
+		if err := workflow.ExecuteActivity(listCtx, ListExecutions, input).Get(ctx, &list); err != nil {
+			logger.Error("Failed to list workflow executions.", "Error", err)
+			return params.Result, err
+		}
 
-		workflow.Go(ctx, func(ctx workflow.Context) {
-			err = workflow.ExecuteActivity(ctx, RecoverExecutions, result.ID, startIndex, batchSize).Get(ctx, nil)
-			if err != nil {
-				logger.Error("Recover executions failed.", "StartIndex", startIndex, "Error", err)
-			} else {
-				logger.Info("Recover executions completed.", "StartIndex", startIndex)
+		// Split the page into batches recovered in parallel
+		batches := splitExecutions(list.Executions, concurrency)
+		var futures []workflow.Future
+		for _, batch := range batches {
+			futures = append(futures, workflow.ExecuteActivity(recoverCtx, RecoverExecutions, RecoverExecutionsInput{
+				Executions:          batch,
+				DryRun:              params.DryRun,
+				RecoveriesPerSecond: params.RecoveriesPerSecond / float64(len(batches)),
+			}))
+		}
+		for _, future := range futures {
+			var result RecoveryResult
+			if err := future.Get(ctx, &result); err != nil {
+				logger.Error("Recover executions failed.", "Error", err)
+				return params.Result, err
 			}
+			params.Result.add(result)
+		}
 
-			doneCh.Send(ctx, "done")
-		})
+		params.Cursor = list.NextPageToken
+		logger.Info("Recovered page of executions.", "Executions", len(list.Executions),
+			"Restarted", params.Result.Restarted, "Skipped", params.Result.Skipped, "Failed", params.Result.Failed)
+		if len(params.Cursor) == 0 {
+			break
+		}
+		if page >= pagesPerRun || workflow.GetInfo(ctx).GetContinueAsNewSuggested() {
+			logger.Info("Starting a new run.")
+			return params.Result, workflow.NewContinueAsNewError(ctx, RecoverWorkflow, params)
+		}
 	}
 
-	for i := 0; i < concurrency; i++ {
-		doneCh.Receive(ctx, nil)
+	logger.Info("Workflow completed.", "Restarted", params.Result.Restarted, "Skipped", params.Result.Skipped,
+		"Failed", params.Result.Failed, "DryRun", params.DryRun)
+	return params.Result, nil
+}
+
+// query returns the visibility query of the executions, restricted to executions started before the recovery
+func (p Params) query() (string, error) {
+	query := p.Query
+	if query == "" {
+		if p.Type == "" {
+			return "", errors.New("either Query or Type is required")
+		}
+		query = fmt.Sprintf("WorkflowType = '%s' AND ExecutionStatus = 'Running'", p.Type)
 	}
+	return fmt.Sprintf("(%s) AND StartTime < '%s'", query, p.StartedBefore.UTC().Format(time.RFC3339Nano)), nil
+}
 
-	logger.Info("Workflow completed.", "Result", result.Count)
+func (r *RecoveryResult) add(other RecoveryResult) {
+	r.Restarted += other.Restarted
+	r.Skipped += other.Skipped
+	r.Failed += other.Failed
+	for _, failure := range other.Failures {
+		if len(r.Failures) < maxFailures {
+			r.Failures = append(r.Failures, failure)
+		}
+	}
+}
 
-	return nil
+// splitExecutions splits the executions into at most n batches of about the same size
+func splitExecutions(executions []Execution, n int) [][]Execution {
+	if len(executions) < n {
+		n = len(executions)
+	}
+	var batches [][]Execution
+	for i := 0; i < n; i++ {
+		batches = append(batches, executions[i*len(executions)/n:(i+1)*len(executions)/n])
+	}
+	return batches
 }
 
-func ListOpenExecutions(ctx context.Context, workflowType string) (*ListOpenExecutionsResult, error) {
-	key := uuid.New()
+// ListExecutions activity returns a page of the executions matching the query
+func ListExecutions(ctx context.Context, input ListExecutionsInput) (*ListExecutionsResult, error) {
 	logger := activity.GetLogger(ctx)
-	logger.Info("List all open executions of type.",
-		"WorkflowType", workflowType,
-		"HostID", HostID)
+	logger.Info("List executions.", "Query", input.Query)
 
 	c, err := getClientFromContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	executionsCache := ctx.Value(WorkflowExecutionCacheKey).(cache.Cache)
-	if executionsCache == nil {
-		logger.Error("Could not retrieve cache from context.")
-		return nil, ErrExecutionCacheNotFound
-	}
-
-	openExecutions, err := getAllExecutionsOfType(ctx, c, workflowType)
+	resp, err := c.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+		Namespace:     client.DefaultNamespace,
+		PageSize:      int32(input.PageSize),
+		NextPageToken: input.NextPageToken,
+		Query:         input.Query,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	executionsCache.Put(key, openExecutions)
-	return &ListOpenExecutionsResult{
-		ID:     key,
-		Count:  len(openExecutions),
-		HostID: HostID,
-	}, nil
+	result := &ListExecutionsResult{NextPageToken: resp.NextPageToken}
+	for _, info := range resp.Executions {
+		result.Executions = append(result.Executions, Execution{
+			WorkflowID: info.GetExecution().GetWorkflowId(),
+			RunID:      info.GetExecution().GetRunId(),
+			Type:       info.GetType().GetName(),
+		})
+	}
+	return result, nil
 }
 
-func RecoverExecutions(ctx context.Context, key string, startIndex, batchSize int) error {
+// RecoverExecutions activity recovers a batch of executions. Executions which fail to be recovered are counted in the
+// result instead of failing the activity.
+func RecoverExecutions(ctx context.Context, input RecoverExecutionsInput) (*RecoveryResult, error) {
 	logger := activity.GetLogger(ctx)
 	logger.Info("Starting execution recovery.",
-		"HostID", HostID,
-		"Key", key,
-		"StartIndex", startIndex,
-		"BatchSize", batchSize)
+		"Executions", len(input.Executions),
+		"DryRun", input.DryRun)
 
-	executionsCache := ctx.Value(WorkflowExecutionCacheKey).(cache.Cache)
-	if executionsCache == nil {
-		logger.Error("Could not retrieve cache from context.")
-		return ErrExecutionCacheNotFound
+	c, err := getClientFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	registry, err := getRegistryFromContext(ctx)
+	if err != nil {
+		return nil, err
 	}
-
-	openExecutions := executionsCache.Get(key).([]*commonpb.WorkflowExecution)
-	endIndex := startIndex + batchSize
 
 	// Check if this activity has previous heartbeat to retrieve progress from it
+	var progress recoveryProgress
 	if activity.HasHeartbeatDetails(ctx) {
-		var finishedIndex int
-		if err := activity.GetHeartbeatDetails(ctx, &finishedIndex); err == nil {
-			// we have finished progress
-			startIndex = finishedIndex + 1 // start from next one.
+		if err := activity.GetHeartbeatDetails(ctx, &progress); err != nil {
+			progress = recoveryProgress{}
 		}
 	}
 
-	for index := startIndex; index < endIndex && index < len(openExecutions); index++ {
-		execution := openExecutions[index]
-		if err := recoverSingleExecution(ctx, execution.GetWorkflowId()); err != nil {
+	limiter := rate.NewLimiter(rate.Inf, 1)
+	if input.RecoveriesPerSecond > 0 {
+		limiter = rate.NewLimiter(rate.Limit(input.RecoveriesPerSecond), 1)
+	}
+
+	for progress.Next < len(input.Executions) {
+		if err := limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		execution := input.Executions[progress.Next]
+		restarted, err := recoverSingleExecution(ctx, c, registry, execution, input.DryRun)
+		switch {
+		case err != nil:
 			logger.Error("Failed to recover execution.",
-				"WorkflowID", execution.GetWorkflowId(),
+				"WorkflowID", execution.WorkflowID,
+				"RunID", execution.RunID,
 				"Error", err)
-			return err
+			progress.Result.Failed++
+			if len(progress.Result.Failures) < maxFailures {
+				progress.Result.Failures = append(progress.Result.Failures, RecoveryFailure{
+					WorkflowID: execution.WorkflowID,
+					RunID:      execution.RunID,
+					Error:      err.Error(),
+				})
+			}
+		case restarted:
+			progress.Result.Restarted++
+		default:
+			progress.Result.Skipped++
 		}
 
 		// Record a heartbeat after each recovery of execution
-		activity.RecordHeartbeat(ctx, index)
+		progress.Next++
+		activity.RecordHeartbeat(ctx, progress)
 	}
 
-	return nil
+	return &progress.Result, nil
 }
 
-func recoverSingleExecution(ctx context.Context, workflowID string) error {
+// recoverSingleExecution restarts an execution and replays its signals to the new run. It returns false if the
+// execution is skipped.
+func recoverSingleExecution(ctx context.Context, c client.Client, registry *Registry, execution Execution, dryRun bool) (bool, error) {
 	logger := activity.GetLogger(ctx)
-	c, err := getClientFromContext(ctx)
-	if err != nil {
-		return err
+	recoverer, ok := registry.Get(execution.Type)
+	if !ok {
+		logger.Warn("No recoverer registered, skipping execution.",
+			"WorkflowID", execution.WorkflowID,
+			"WorkflowType", execution.Type)
+		return false, nil
 	}
 
-	execution := &commonpb.WorkflowExecution{
-		WorkflowId: workflowID,
-	}
-	history, err := getHistory(ctx, execution)
+	history, err := getHistory(ctx, c, execution)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	if len(history) == 0 {
 		// Nothing to recover
-		return nil
+		return false, nil
 	}
 
 	firstEvent := history[0]
 	lastEvent := history[len(history)-1]
 
 	// Extract information from StartWorkflowExecution parameters so we can start a new run
-	params, err := extractStateFromEvent(workflowID, firstEvent)
+	attr := firstEvent.GetWorkflowExecutionStartedEventAttributes()
+	if attr == nil {
+		return false, fmt.Errorf("unexpected first event type %v", firstEvent.GetEventType())
+	}
+	args, err := recoverer.StartArgs(attr)
+	if errors.Is(err, ErrSkipExecution) {
+		return false, nil
+	}
 	if err != nil {
-		return err
+		return false, fmt.Errorf("failed to extract start args: %w", err)
 	}
 
 	// Parse the entire history and extract all signals so they can be replayed back to new run
-	signals, err := extractSignals(history)
-	if err != nil {
-		return err
+	var signals []Signal
+	if recoverer.Signals != nil {
+		if signals, err = recoverer.Signals(history); err != nil {
+			return false, fmt.Errorf("failed to extract signals: %w", err)
+		}
+	}
+
+	if dryRun {
+		logger.Info("Dry run, would restart workflow.",
+			"WorkflowID", execution.WorkflowID,
+			"RunID", execution.RunID,
+			"Signals", len(signals))
+		return true, nil
 	}
 
 	// First terminate existing run if already running
 	if !isExecutionCompleted(lastEvent) {
-		err := c.TerminateWorkflow(ctx, execution.GetWorkflowId(), execution.GetRunId(), "Recover", nil)
+		err := c.TerminateWorkflow(ctx, execution.WorkflowID, execution.RunID, "Recover", nil)
 		if err != nil {
-			return err
+			return false, err
 		}
 	}
 
 	// Start new execution run
-	newRun, err := c.ExecuteWorkflow(ctx, params.Options, "TripWorkflow", params.State)
+	options := client.StartWorkflowOptions{
+		ID:                       execution.WorkflowID,
+		TaskQueue:                attr.GetTaskQueue().GetName(),
+		WorkflowExecutionTimeout: attr.GetWorkflowExecutionTimeout().AsDuration(),
+		WorkflowRunTimeout:       attr.GetWorkflowRunTimeout().AsDuration(),
+		WorkflowTaskTimeout:      attr.GetWorkflowTaskTimeout().AsDuration(),
+	}
+	newRun, err := c.ExecuteWorkflow(ctx, options, execution.Type, args...)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	// re-inject all signals to new run
 	for _, s := range signals {
-		_ = c.SignalWorkflow(ctx, execution.GetWorkflowId(), newRun.GetRunID(), s.Name, s.Data)
-	}
-
-	logger.Info("Successfully restarted workflow.",
-		"WorkflowID", execution.GetWorkflowId(),
-		"NewRunID", newRun.GetRunID())
-
-	return nil
-}
-
-func extractStateFromEvent(workflowID string, event *historypb.HistoryEvent) (*RestartParams, error) {
-	switch event.GetEventType() {
-	case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_STARTED:
-		attr := event.GetWorkflowExecutionStartedEventAttributes()
-		state, err := deserializeUserState(attr.GetInput())
-		if err != nil {
-			// Corrupted Workflow Execution State
-			return nil, err
+		if err := c.SignalWorkflow(ctx, execution.WorkflowID, newRun.GetRunID(), s.Name, s.Arg); err != nil {
+			return false, fmt.Errorf("failed to replay signal %s to new run %s: %w", s.Name, newRun.GetRunID(), err)
 		}
-		return &RestartParams{
-			Options: client.StartWorkflowOptions{
-				ID:                  workflowID,
-				TaskQueue:           attr.TaskQueue.GetName(),
-				WorkflowTaskTimeout: attr.GetWorkflowTaskTimeout().AsDuration(),
-				// RetryPolicy: attr.RetryPolicy,
-			},
-			State: state,
-		}, nil
-	default:
-		return nil, errors.New("unknown event type")
 	}
-}
 
-func extractSignals(events []*historypb.HistoryEvent) ([]*SignalParams, error) {
-	var signals []*SignalParams
-	for _, event := range events {
-		if event.GetEventType() == enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_SIGNALED {
-			attr := event.GetWorkflowExecutionSignaledEventAttributes()
-			if attr.GetSignalName() == TripSignalName && attr.GetInput() != nil {
-				signalData, err := deserializeTripEvent(attr.GetInput())
-				if err != nil {
-					// Corrupted Signal Payload
-					return nil, err
-				}
-
-				signal := &SignalParams{
-					Name: attr.GetSignalName(),
-					Data: signalData,
-				}
-				signals = append(signals, signal)
-			}
-		}
-	}
+	logger.Info("Successfully restarted workflow.",
+		"WorkflowID", execution.WorkflowID,
+		"NewRunID", newRun.GetRunID(),
+		"Signals", len(signals))
 
-	return signals, nil
+	return true, nil
 }
 
 func isExecutionCompleted(event *historypb.HistoryEvent) bool {
 	switch event.GetEventType() {
 	case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED, enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_TERMINATED,
 		enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_CANCELED, enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_FAILED,
-		enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_TIMED_OUT:
+		enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_TIMED_OUT, enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_CONTINUED_AS_NEW:
 		return true
 	default:
 		return false
 	}
 }
 
-func getAllExecutionsOfType(ctx context.Context, c client.Client, workflowType string) ([]*commonpb.WorkflowExecution, error) {
-	var openExecutions []*commonpb.WorkflowExecution
-	var nextPageToken []byte
-	for hasMore := true; hasMore; hasMore = len(nextPageToken) > 0 {
-		zeroTime := time.Time{}
-		now := time.Now()
-		resp, err := c.ListOpenWorkflow(ctx, &workflowservice.ListOpenWorkflowExecutionsRequest{
-			Namespace:       client.DefaultNamespace,
-			MaximumPageSize: 10,
-			NextPageToken:   nextPageToken,
-			StartTimeFilter: &filterpb.StartTimeFilter{
-				EarliestTime: timestamppb.New(zeroTime),
-				LatestTime:   timestamppb.New(now),
-			},
-			Filters: &workflowservice.ListOpenWorkflowExecutionsRequest_TypeFilter{TypeFilter: &filterpb.WorkflowTypeFilter{
-				Name: workflowType,
-			}},
-		})
-		if err != nil {
-			return nil, err
-		}
-
-		for _, r := range resp.Executions {
-			openExecutions = append(openExecutions, r.Execution)
-		}
-
-		nextPageToken = resp.NextPageToken
-		activity.RecordHeartbeat(ctx, nextPageToken)
-	}
-
-	return openExecutions, nil
-}
-
-func getHistory(ctx context.Context, execution *commonpb.WorkflowExecution) ([]*historypb.HistoryEvent, error) {
-	c, err := getClientFromContext(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	iter := c.GetWorkflowHistory(ctx, execution.GetWorkflowId(), execution.GetRunId(), false, enumspb.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+func getHistory(ctx context.Context, c client.Client, execution Execution) ([]*historypb.HistoryEvent, error) {
+	iter := c.GetWorkflowHistory(ctx, execution.WorkflowID, execution.RunID, false, enumspb.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
 	var events []*historypb.HistoryEvent
 	for iter.HasNext() {
 		event, err := iter.Next()
@@ -389,7 +453,7 @@ func getHistory(ctx context.Context, execution *commonpb.WorkflowExecution) ([]*
 
 func getClientFromContext(ctx context.Context) (client.Client, error) {
 	logger := activity.GetLogger(ctx)
-	temporalClient := ctx.Value(TemporalClientKey).(client.Client)
+	temporalClient, _ := ctx.Value(TemporalClientKey).(client.Client)
 	if temporalClient == nil {
 		logger.Error("Could not retrieve temporal client from context.")
 		return nil, ErrClientNotFound
@@ -397,3 +461,14 @@ func getClientFromContext(ctx context.Context) (client.Client, error) {
 
 	return temporalClient, nil
 }
+
+func getRegistryFromContext(ctx context.Context) (*Registry, error) {
+	logger := activity.GetLogger(ctx)
+	registry, _ := ctx.Value(RegistryKey).(*Registry)
+	if registry == nil {
+		logger.Error("Could not retrieve registry from context.")
+		return nil, ErrRegistryNotFound
+	}
+
+	return registry, nil
+}
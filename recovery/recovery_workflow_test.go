@@ -0,0 +1,285 @@
+package recovery
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	enumspb "go.temporal.io/api/enums/v1"
+	historypb "go.temporal.io/api/history/v1"
+	taskqueuepb "go.temporal.io/api/taskqueue/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/mocks"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+// historyIterator iterates over the events of a history.
+type historyIterator struct {
+	events []*historypb.HistoryEvent
+}
+
+func (i *historyIterator) HasNext() bool { return len(i.events) > 0 }
+
+func (i *historyIterator) Next() (*historypb.HistoryEvent, error) {
+	event := i.events[0]
+	i.events = i.events[1:]
+	return event, nil
+}
+
+// tripHistory returns the history of a running TripWorkflow started with state and signaled with trips.
+func tripHistory(t *testing.T, state UserState, trips ...TripEvent) *historyIterator {
+	input, err := converter.GetDefaultDataConverter().ToPayloads(state)
+	require.NoError(t, err)
+	events := []*historypb.HistoryEvent{{
+		EventType: enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_STARTED,
+		Attributes: &historypb.HistoryEvent_WorkflowExecutionStartedEventAttributes{
+			WorkflowExecutionStartedEventAttributes: &historypb.WorkflowExecutionStartedEventAttributes{
+				Input:     input,
+				TaskQueue: &taskqueuepb.TaskQueue{Name: "recovery"},
+			},
+		},
+	}}
+	for _, trip := range trips {
+		input, err := converter.GetDefaultDataConverter().ToPayloads(trip)
+		require.NoError(t, err)
+		events = append(events, &historypb.HistoryEvent{
+			EventType: enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_SIGNALED,
+			Attributes: &historypb.HistoryEvent_WorkflowExecutionSignaledEventAttributes{
+				WorkflowExecutionSignaledEventAttributes: &historypb.WorkflowExecutionSignaledEventAttributes{
+					SignalName: TripSignalName,
+					Input:      input,
+				},
+			},
+		})
+	}
+	return &historyIterator{events: events}
+}
+
+// newActivityEnvironment returns an environment whose activities get the client and the registry from their context.
+func newActivityEnvironment(c client.Client, registry *Registry) *testsuite.TestActivityEnvironment {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	ctx := context.WithValue(context.Background(), TemporalClientKey, c)
+	ctx = context.WithValue(ctx, RegistryKey, registry)
+	env.SetWorkerOptions(worker.Options{BackgroundActivityContext: ctx})
+	env.RegisterActivity(RecoverExecutions)
+	return env
+}
+
+func newTripRegistry() *Registry {
+	registry := NewRegistry()
+	registry.Register("TripWorkflow", TripWorkflowRecoverer)
+	return registry
+}
+
+func recoverExecutions(t *testing.T, env *testsuite.TestActivityEnvironment, input RecoverExecutionsInput) RecoveryResult {
+	value, err := env.ExecuteActivity(RecoverExecutions, input)
+	require.NoError(t, err)
+	var result RecoveryResult
+	require.NoError(t, value.Get(&result))
+	return result
+}
+
+func Test_RecoverExecutions(t *testing.T) {
+	c := &mocks.Client{}
+	trips := []TripEvent{{ID: "trip-1", Total: 10}, {ID: "trip-2", Total: 20}}
+	c.On("GetWorkflowHistory", mock.Anything, "user-1", "run-1", false, enumspb.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT).
+		Return(tripHistory(t, UserState{TripCounter: 3}, trips...)).Once()
+	c.On("TerminateWorkflow", mock.Anything, "user-1", "run-1", "Recover", nil).Return(nil).Once()
+	newRun := &mocks.WorkflowRun{}
+	newRun.On("GetRunID").Return("new-run-1")
+	c.On("ExecuteWorkflow", mock.Anything, client.StartWorkflowOptions{ID: "user-1", TaskQueue: "recovery"},
+		"TripWorkflow", UserState{TripCounter: 3}).Return(newRun, nil).Once()
+	for _, trip := range trips {
+		c.On("SignalWorkflow", mock.Anything, "user-1", "new-run-1", TripSignalName, trip).Return(nil).Once()
+	}
+	c.On("GetWorkflowHistory", mock.Anything, "user-2", "run-2", false, enumspb.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT).
+		Return(tripHistory(t, UserState{})).Once()
+	c.On("TerminateWorkflow", mock.Anything, "user-2", "run-2", "Recover", nil).Return(errors.New("terminate failed")).Once()
+	env := newActivityEnvironment(c, newTripRegistry())
+
+	// Executions of unregistered types are skipped without reading their history
+	result := recoverExecutions(t, env, RecoverExecutionsInput{Executions: []Execution{
+		{WorkflowID: "user-1", RunID: "run-1", Type: "TripWorkflow"},
+		{WorkflowID: "other", RunID: "run-3", Type: "OtherWorkflow"},
+		{WorkflowID: "user-2", RunID: "run-2", Type: "TripWorkflow"},
+	}})
+
+	require.Equal(t, RecoveryResult{
+		Restarted: 1,
+		Skipped:   1,
+		Failed:    1,
+		Failures:  []RecoveryFailure{{WorkflowID: "user-2", RunID: "run-2", Error: "terminate failed"}},
+	}, result)
+	c.AssertExpectations(t)
+}
+
+func Test_RecoverExecutions_DryRun(t *testing.T) {
+	// Terminating or starting a workflow fails the test, as the calls are not mocked
+	c := &mocks.Client{}
+	c.On("GetWorkflowHistory", mock.Anything, "user-1", "run-1", false, enumspb.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT).
+		Return(tripHistory(t, UserState{TripCounter: 3}, TripEvent{ID: "trip-1"})).Once()
+	env := newActivityEnvironment(c, newTripRegistry())
+
+	result := recoverExecutions(t, env, RecoverExecutionsInput{
+		Executions: []Execution{{WorkflowID: "user-1", RunID: "run-1", Type: "TripWorkflow"}},
+		DryRun:     true,
+	})
+
+	require.Equal(t, RecoveryResult{Restarted: 1}, result)
+	c.AssertExpectations(t)
+}
+
+func Test_RecoverExecutions_SkipExecution(t *testing.T) {
+	c := &mocks.Client{}
+	c.On("GetWorkflowHistory", mock.Anything, "user-1", "run-1", false, enumspb.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT).
+		Return(tripHistory(t, UserState{TripCounter: 3})).Once()
+	registry := NewRegistry()
+	registry.Register("TripWorkflow", Recoverer{
+		StartArgs: func(*historypb.WorkflowExecutionStartedEventAttributes) ([]interface{}, error) {
+			return nil, ErrSkipExecution
+		},
+	})
+	env := newActivityEnvironment(c, registry)
+
+	result := recoverExecutions(t, env, RecoverExecutionsInput{
+		Executions: []Execution{{WorkflowID: "user-1", RunID: "run-1", Type: "TripWorkflow"}},
+	})
+
+	require.Equal(t, RecoveryResult{Skipped: 1}, result)
+	c.AssertExpectations(t)
+}
+
+func Test_RecoverExecutions_ResumeFromHeartbeat(t *testing.T) {
+	// The first execution was recovered by a previous attempt
+	c := &mocks.Client{}
+	c.On("GetWorkflowHistory", mock.Anything, "user-2", "run-2", false, enumspb.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT).
+		Return(tripHistory(t, UserState{})).Once()
+	env := newActivityEnvironment(c, newTripRegistry())
+	env.SetHeartbeatDetails(recoveryProgress{Next: 1, Result: RecoveryResult{Restarted: 1}})
+
+	result := recoverExecutions(t, env, RecoverExecutionsInput{
+		Executions: []Execution{
+			{WorkflowID: "user-1", RunID: "run-1", Type: "TripWorkflow"},
+			{WorkflowID: "user-2", RunID: "run-2", Type: "TripWorkflow"},
+		},
+		DryRun: true,
+	})
+
+	require.Equal(t, RecoveryResult{Restarted: 2}, result)
+	c.AssertExpectations(t)
+}
+
+// recoverWorkflowTest runs RecoverWorkflow against pages of executions keyed by their page token.
+type recoverWorkflowTest struct {
+	env   *testsuite.TestWorkflowEnvironment
+	mut   sync.Mutex
+	lists []ListExecutionsInput
+	// recovers are the inputs of RecoverExecutions, each returns every execution as restarted.
+	recovers []RecoverExecutionsInput
+}
+
+func newRecoverWorkflowTest(pages map[string]ListExecutionsResult) *recoverWorkflowTest {
+	var suite testsuite.WorkflowTestSuite
+	s := &recoverWorkflowTest{env: suite.NewTestWorkflowEnvironment()}
+	s.env.RegisterActivity(ListExecutions)
+	s.env.RegisterActivity(RecoverExecutions)
+	s.env.OnActivity(ListExecutions, mock.Anything, mock.Anything).Return(
+		func(ctx context.Context, input ListExecutionsInput) (*ListExecutionsResult, error) {
+			s.mut.Lock()
+			defer s.mut.Unlock()
+			s.lists = append(s.lists, input)
+			page := pages[string(input.NextPageToken)]
+			return &page, nil
+		})
+	s.env.OnActivity(RecoverExecutions, mock.Anything, mock.Anything).Return(
+		func(ctx context.Context, input RecoverExecutionsInput) (*RecoveryResult, error) {
+			s.mut.Lock()
+			defer s.mut.Unlock()
+			s.recovers = append(s.recovers, input)
+			return &RecoveryResult{Restarted: len(input.Executions)}, nil
+		})
+	return s
+}
+
+func executions(ids ...string) []Execution {
+	var result []Execution
+	for _, id := range ids {
+		result = append(result, Execution{WorkflowID: id, RunID: "run", Type: "TripWorkflow"})
+	}
+	return result
+}
+
+func Test_RecoverWorkflow_ContinueAsNew(t *testing.T) {
+	pages := map[string]ListExecutionsResult{
+		"":       {Executions: executions("user-1", "user-2", "user-3"), NextPageToken: []byte("page-2")},
+		"page-2": {Executions: executions("user-4", "user-5"), NextPageToken: []byte("page-3")},
+		"page-3": {Executions: executions("user-6")},
+	}
+	s := newRecoverWorkflowTest(pages)
+
+	s.env.ExecuteWorkflow(RecoverWorkflow, Params{
+		Type:                "TripWorkflow",
+		Concurrency:         2,
+		DryRun:              true,
+		RecoveriesPerSecond: 10,
+		PageSize:            3,
+		PagesPerRun:         2,
+	})
+
+	// The run continues as new after two pages, with the cursor of the third one
+	require.True(t, s.env.IsWorkflowCompleted())
+	var continueAsNewErr *workflow.ContinueAsNewError
+	require.ErrorAs(t, s.env.GetWorkflowError(), &continueAsNewErr)
+	var next Params
+	require.NoError(t, converter.GetDefaultDataConverter().FromPayloads(continueAsNewErr.Input, &next))
+	require.Equal(t, []byte("page-3"), next.Cursor)
+	require.Equal(t, RecoveryResult{Restarted: 5}, next.Result)
+	require.False(t, next.StartedBefore.IsZero())
+
+	// Restarted executions are excluded by their start time
+	require.Len(t, s.lists, 2)
+	require.Contains(t, s.lists[0].Query, "(WorkflowType = 'TripWorkflow' AND ExecutionStatus = 'Running') AND StartTime < ")
+	require.Equal(t, s.lists[0].Query, s.lists[1].Query)
+	require.Equal(t, 3, s.lists[0].PageSize)
+
+	// Each page is split across the concurrent activities, which share the rate limit
+	require.Len(t, s.recovers, 4)
+	var recovered []string
+	for _, input := range s.recovers {
+		require.True(t, input.DryRun)
+		require.Equal(t, 5.0, input.RecoveriesPerSecond)
+		require.NotEmpty(t, input.Executions)
+		for _, execution := range input.Executions {
+			recovered = append(recovered, execution.WorkflowID)
+		}
+	}
+	require.ElementsMatch(t, []string{"user-1", "user-2", "user-3", "user-4", "user-5"}, recovered)
+
+	// The next run recovers the last page with the query of the first run
+	last := newRecoverWorkflowTest(pages)
+	last.env.ExecuteWorkflow(RecoverWorkflow, next)
+
+	require.True(t, last.env.IsWorkflowCompleted())
+	require.NoError(t, last.env.GetWorkflowError())
+	var result RecoveryResult
+	require.NoError(t, last.env.GetWorkflowResult(&result))
+	require.Equal(t, RecoveryResult{Restarted: 6}, result)
+	require.Equal(t, []ListExecutionsInput{{Query: s.lists[0].Query, PageSize: 3, NextPageToken: []byte("page-3")}}, last.lists)
+}
+
+func Test_RecoverWorkflow_InvalidParams(t *testing.T) {
+	s := newRecoverWorkflowTest(nil)
+
+	s.env.ExecuteWorkflow(RecoverWorkflow, Params{})
+
+	require.True(t, s.env.IsWorkflowCompleted())
+	require.ErrorContains(t, s.env.GetWorkflowError(), "either Query or Type is required")
+	require.Empty(t, s.lists)
+}
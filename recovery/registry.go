@@ -0,0 +1,97 @@
+package recovery
+
+import (
+	"errors"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+type (
+	// Recoverer knows how to restart the executions of a workflow type from their history
+	Recoverer struct {
+		// StartArgs extracts the arguments of the new run from the started event of the execution.
+		// It may return ErrSkipExecution to leave the execution as it is.
+		StartArgs func(attr *historypb.WorkflowExecutionStartedEventAttributes) ([]interface{}, error)
+
+		// Signals extracts the signals to replay to the new run from the history of the execution, in order.
+		// No signals are replayed when nil.
+		Signals func(events []*historypb.HistoryEvent) ([]Signal, error)
+	}
+
+	// Signal is a signal replayed to a restarted execution
+	Signal struct {
+		Name string
+		Arg  interface{}
+	}
+
+	// Registry maps workflow types to their Recoverer. Types are registered before the worker starts.
+	Registry struct {
+		recoverers map[string]Recoverer
+	}
+)
+
+// ErrSkipExecution is returned by Recoverer.StartArgs for executions which should not be restarted
+var ErrSkipExecution = errors.New("skip execution")
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{recoverers: make(map[string]Recoverer)}
+}
+
+// Register sets the Recoverer of a workflow type
+func (r *Registry) Register(workflowType string, recoverer Recoverer) {
+	r.recoverers[workflowType] = recoverer
+}
+
+// Get returns the Recoverer of a workflow type, if any
+func (r *Registry) Get(workflowType string) (Recoverer, bool) {
+	recoverer, ok := r.recoverers[workflowType]
+	return recoverer, ok
+}
+
+// StartArg returns a Recoverer.StartArgs function for workflows with a single argument of type T
+func StartArg[T any]() func(attr *historypb.WorkflowExecutionStartedEventAttributes) ([]interface{}, error) {
+	return func(attr *historypb.WorkflowExecutionStartedEventAttributes) ([]interface{}, error) {
+		var arg T
+		if err := converter.GetDefaultDataConverter().FromPayloads(attr.GetInput(), &arg); err != nil {
+			// Corrupted Workflow Execution State
+			return nil, err
+		}
+		return []interface{}{arg}, nil
+	}
+}
+
+// ReplaySignals returns a Recoverer.Signals function replaying the signals with the given names, whose single
+// argument is of type T. Signals without argument are dropped.
+func ReplaySignals[T any](names ...string) func(events []*historypb.HistoryEvent) ([]Signal, error) {
+	return func(events []*historypb.HistoryEvent) ([]Signal, error) {
+		var signals []Signal
+		for _, event := range events {
+			if event.GetEventType() != enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_SIGNALED {
+				continue
+			}
+			attr := event.GetWorkflowExecutionSignaledEventAttributes()
+			if !contains(names, attr.GetSignalName()) || attr.GetInput() == nil {
+				continue
+			}
+			var arg T
+			if err := converter.GetDefaultDataConverter().FromPayloads(attr.GetInput(), &arg); err != nil {
+				// Corrupted Signal Payload
+				return nil, err
+			}
+			signals = append(signals, Signal{Name: attr.GetSignalName(), Arg: arg})
+		}
+		return signals, nil
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
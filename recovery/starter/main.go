@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"flag"
 	"log"
-	"time"
 
 	"go.temporal.io/sdk/client"
 
@@ -48,9 +47,8 @@ func main() {
 		}
 
 		workflowOptions := client.StartWorkflowOptions{
-			ID:                       workflowID,
-			TaskQueue:                "recovery",
-			WorkflowExecutionTimeout: 1 * time.Minute,
+			ID:        workflowID,
+			TaskQueue: "recovery",
 		}
 		we, weError = c.ExecuteWorkflow(context.Background(), workflowOptions, recovery.RecoverWorkflow, params)
 	default:
@@ -1,8 +1,6 @@
 package recovery
 
 import (
-	commonpb "go.temporal.io/api/common/v1"
-	"go.temporal.io/sdk/converter"
 	"go.temporal.io/sdk/workflow"
 )
 
@@ -60,20 +58,8 @@ func TripWorkflow(ctx workflow.Context, state UserState) error {
 	return workflow.NewContinueAsNewError(ctx, "TripWorkflow", state)
 }
 
-func deserializeUserState(data *commonpb.Payloads) (UserState, error) {
-	var state UserState
-	if err := converter.GetDefaultDataConverter().FromPayloads(data, &state); err != nil {
-		return UserState{}, err
-	}
-
-	return state, nil
-}
-
-func deserializeTripEvent(data *commonpb.Payloads) (TripEvent, error) {
-	var trip TripEvent
-	if err := converter.GetDefaultDataConverter().FromPayloads(data, &trip); err != nil {
-		return TripEvent{}, err
-	}
-
-	return trip, nil
+// TripWorkflowRecoverer restarts TripWorkflow executions with their UserState and replays their TripEvent signals
+var TripWorkflowRecoverer = Recoverer{
+	StartArgs: StartArg[UserState](),
+	Signals:   ReplaySignals[TripEvent](TripSignalName),
 }
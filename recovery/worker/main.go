@@ -8,22 +8,27 @@ import (
 	"go.temporal.io/sdk/worker"
 	"go.temporal.io/sdk/workflow"
 
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 	"github.com/temporalio/samples-go/recovery"
-	"github.com/temporalio/samples-go/recovery/cache"
 )
 
 func main() {
 	// The client and worker are heavyweight objects that should be created once per process.
-	c, err := client.Dial(client.Options{
+	c, shutdown, err := bootstrap.Dial(client.Options{
 		HostPort: client.DefaultHostPort,
 	})
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
 	ctx := context.WithValue(context.Background(), recovery.TemporalClientKey, c)
-	ctx = context.WithValue(ctx, recovery.WorkflowExecutionCacheKey, cache.NewLRU(10))
+
+	// Register how to recover each workflow type
+	registry := recovery.NewRegistry()
+	registry.Register("TripWorkflow", recovery.TripWorkflowRecoverer)
+	ctx = context.WithValue(ctx, recovery.RegistryKey, registry)
 
 	w := worker.New(c, "recovery", worker.Options{
 		BackgroundActivityContext: ctx,
@@ -31,7 +36,7 @@ func main() {
 
 	w.RegisterWorkflowWithOptions(recovery.RecoverWorkflow, workflow.RegisterOptions{Name: "RecoverWorkflow"})
 	w.RegisterWorkflowWithOptions(recovery.TripWorkflow, workflow.RegisterOptions{Name: "TripWorkflow"})
-	w.RegisterActivity(recovery.ListOpenExecutions)
+	w.RegisterActivity(recovery.ListExecutions)
 	w.RegisterActivity(recovery.RecoverExecutions)
 
 	err = w.Run(worker.InterruptCh())
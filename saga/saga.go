@@ -0,0 +1,134 @@
+package saga
+
+import (
+	"go.uber.org/multierr"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+// Options configure how a Saga compensates.
+type Options struct {
+	// ParallelCompensation runs all compensations concurrently instead of in the reverse order they were added.
+	ParallelCompensation bool
+	// ContinueWithError runs the remaining compensations after a compensation fails. Parallel compensations always
+	// all run.
+	ContinueWithError bool
+	// DisconnectedContext runs the compensations on a context disconnected from the workflow context, so they still
+	// run after the workflow is cancelled.
+	DisconnectedContext bool
+}
+
+// StepStatus is the status of a saga step.
+type StepStatus string
+
+const (
+	// StepExecuted steps completed and are not compensated.
+	StepExecuted StepStatus = "executed"
+	// StepFailed steps failed, they have no compensation.
+	StepFailed StepStatus = "failed"
+	// StepCompensated steps completed and were undone by their compensation.
+	StepCompensated StepStatus = "compensated"
+	// StepCompensationFailed steps completed but their compensation failed.
+	StepCompensationFailed StepStatus = "compensation-failed"
+)
+
+// Step is an entry of the ledger of a Saga.
+type Step struct {
+	Name   string
+	Status StepStatus
+	// Error is the error of the step or of its compensation.
+	Error string `json:",omitempty"`
+}
+
+// Saga is a stack of compensations, which undo the steps of a workflow that completed before a later step failed.
+// Add the compensation of each step after the step succeeds, and call Compensate when a later step fails.
+//
+// The steps recorded with RecordStep form the ledger of the Saga, which shows what was done and undone.
+type Saga struct {
+	options       Options
+	compensations []compensation
+	ledger        []Step
+}
+
+type compensation struct {
+	activity interface{}
+	args     []interface{}
+	// step is the index of the compensated step in the ledger, or -1.
+	step int
+}
+
+// New creates an empty Saga.
+func New(options Options) *Saga {
+	return &Saga{options: options}
+}
+
+// RecordStep adds a step to the ledger, it is executed when err is nil and failed otherwise.
+func (s *Saga) RecordStep(name string, err error) {
+	step := Step{Name: name, Status: StepExecuted}
+	if err != nil {
+		step.Status = StepFailed
+		step.Error = err.Error()
+	}
+	s.ledger = append(s.ledger, step)
+}
+
+// AddCompensation adds a compensation, which executes the activity with args. The activity is a function or the name
+// of a registered activity, as accepted by workflow.ExecuteActivity. The compensation undoes the last step recorded
+// with RecordStep, if any.
+func (s *Saga) AddCompensation(activity interface{}, args ...interface{}) {
+	s.compensations = append(s.compensations, compensation{activity: activity, args: args, step: len(s.ledger) - 1})
+}
+
+// Ledger returns a copy of the recorded steps in the order they were recorded.
+func (s *Saga) Ledger() []Step {
+	return append([]Step(nil), s.ledger...)
+}
+
+// compensated updates the ledger with the result of a compensation.
+func (s *Saga) compensated(c compensation, err error) {
+	if c.step < 0 {
+		return
+	}
+	step := &s.ledger[c.step]
+	step.Status = StepCompensated
+	step.Error = ""
+	if err != nil {
+		step.Status = StepCompensationFailed
+		step.Error = err.Error()
+	}
+}
+
+// Compensate executes the compensations with the activity options of ctx and returns their combined errors.
+func (s *Saga) Compensate(ctx workflow.Context) error {
+	if s.options.DisconnectedContext {
+		ctx, _ = workflow.NewDisconnectedContext(ctx)
+	}
+
+	if s.options.ParallelCompensation {
+		futures := make([]workflow.Future, len(s.compensations))
+		for i, c := range s.compensations {
+			futures[i] = workflow.ExecuteActivity(ctx, c.activity, c.args...)
+		}
+		var err error
+		for i, future := range futures {
+			errCompensation := future.Get(ctx, nil)
+			s.compensated(s.compensations[i], errCompensation)
+			err = multierr.Append(err, errCompensation)
+		}
+		return err
+	}
+
+	var err error
+	for i := len(s.compensations) - 1; i >= 0; i-- {
+		c := s.compensations[i]
+		errCompensation := workflow.ExecuteActivity(ctx, c.activity, c.args...).Get(ctx, nil)
+		s.compensated(c, errCompensation)
+		if errCompensation != nil {
+			err = multierr.Append(err, errCompensation)
+			if !s.options.ContinueWithError {
+				return err
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+// sagaTest runs a workflow that adds the compensations of steps and compensates after a failed step.
+type sagaTest struct {
+	env *testsuite.TestWorkflowEnvironment
+	// lock guards compensated, parallel compensations run concurrently.
+	lock sync.Mutex
+	// compensated are the compensated steps in the order they ran.
+	compensated []string
+}
+
+func newSagaTest(failingCompensations ...string) *sagaTest {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	s := &sagaTest{env: testSuite.NewTestWorkflowEnvironment()}
+	s.env.RegisterActivityWithOptions(func(ctx context.Context, step string) error {
+		s.lock.Lock()
+		s.compensated = append(s.compensated, step)
+		s.lock.Unlock()
+		for _, failing := range failingCompensations {
+			if step == failing {
+				return temporal.NewNonRetryableApplicationError("compensation failed", "", nil)
+			}
+		}
+		return nil
+	}, activity.RegisterOptions{Name: "Compensate"})
+	return s
+}
+
+func (s *sagaTest) run(options Options, steps ...string) error {
+	s.env.ExecuteWorkflow(func(ctx workflow.Context) error {
+		ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{StartToCloseTimeout: time.Minute})
+		saga := New(options)
+		for _, step := range steps {
+			saga.AddCompensation("Compensate", step)
+		}
+		return saga.Compensate(ctx)
+	})
+	return s.env.GetWorkflowError()
+}
+
+func Test_Saga_ReverseOrder(t *testing.T) {
+	s := newSagaTest()
+	require.NoError(t, s.run(Options{}, "a", "b", "c"))
+	require.Equal(t, []string{"c", "b", "a"}, s.compensated)
+}
+
+func Test_Saga_StopOnError(t *testing.T) {
+	s := newSagaTest("b")
+	err := s.run(Options{}, "a", "b", "c")
+	require.ErrorContains(t, err, "compensation failed")
+	require.Equal(t, []string{"c", "b"}, s.compensated)
+}
+
+func Test_Saga_ContinueWithError(t *testing.T) {
+	s := newSagaTest("b", "c")
+	err := s.run(Options{ContinueWithError: true}, "a", "b", "c")
+	require.ErrorContains(t, err, "compensation failed")
+	require.Equal(t, []string{"c", "b", "a"}, s.compensated)
+}
+
+func Test_Saga_Parallel(t *testing.T) {
+	s := newSagaTest("a")
+	err := s.run(Options{ParallelCompensation: true}, "a", "b", "c")
+	require.ErrorContains(t, err, "compensation failed")
+	sort.Strings(s.compensated)
+	require.Equal(t, []string{"a", "b", "c"}, s.compensated)
+}
+
+func Test_Saga_Cancelled(t *testing.T) {
+	for _, disconnected := range []bool{false, true} {
+		s := newSagaTest()
+		s.env.RegisterDelayedCallback(s.env.CancelWorkflow, time.Minute)
+		s.env.ExecuteWorkflow(func(ctx workflow.Context) error {
+			ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{StartToCloseTimeout: time.Minute})
+			saga := New(Options{DisconnectedContext: disconnected})
+			saga.AddCompensation("Compensate", "a")
+			if err := workflow.Sleep(ctx, time.Hour); err != nil {
+				return errors.Join(err, saga.Compensate(ctx))
+			}
+			return nil
+		})
+
+		var canceledErr *temporal.CanceledError
+		require.ErrorAs(t, s.env.GetWorkflowError(), &canceledErr)
+		if disconnected {
+			require.Equal(t, []string{"a"}, s.compensated)
+		} else {
+			// Activities are not executed on the cancelled context.
+			require.Empty(t, s.compensated)
+		}
+	}
+}
@@ -8,3 +8,10 @@ type TransferDetails struct {
 	ToAccount   string
 	ReferenceID string
 }
+
+// LedgerQuery is the query type returning the []Step ledger of a TransferMoney workflow.
+const LedgerQuery = "ledger"
+
+// TransferFailedErrorType is the type of the application error of failed TransferMoney workflows, its details are the
+// []Step ledger.
+const TransferFailedErrorType = "TransferFailed"
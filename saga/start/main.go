@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 
 	"github.com/google/uuid"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
 
 	"github.com/temporalio/samples-go/saga"
 )
@@ -32,6 +34,24 @@ func main() {
 		log.Fatalln("error starting TransferMoney workflow", err)
 	}
 	printResults(transferDetails, we.GetID(), we.GetRunID())
+
+	// The ledger of a failed transfer shows which steps moved money and which were undone. While the workflow runs,
+	// the ledger is also available with the "ledger" query.
+	err = we.Get(context.Background(), nil)
+	var applicationErr *temporal.ApplicationError
+	if errors.As(err, &applicationErr) && applicationErr.Type() == saga.TransferFailedErrorType {
+		var ledger []saga.Step
+		if err := applicationErr.Details(&ledger); err != nil {
+			log.Fatalln("unable to decode ledger", err)
+		}
+		for _, step := range ledger {
+			log.Printf("Step %s: %s %s\n", step.Name, step.Status, step.Error)
+		}
+	}
+	if err != nil {
+		log.Fatalln("transfer failed", err)
+	}
+	log.Println("Transfer completed")
 }
 
 func printResults(transferDetails saga.TransferDetails, workflowID, runID string) {
@@ -6,15 +6,17 @@ import (
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/worker"
 
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 	"github.com/temporalio/samples-go/saga"
 )
 
 func main() {
 	// Create the client object just once per process
-	c, err := client.Dial(client.Options{})
+	c, shutdown, err := bootstrap.Dial(client.Options{})
 	if err != nil {
 		log.Fatalln("unable to create Temporal client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 	// This worker hosts both Workflow and Activity functions
 	w := worker.New(c, saga.TransferMoneyTaskQueue, worker.Options{})
@@ -27,34 +27,52 @@ func TransferMoney(ctx workflow.Context, transferDetails TransferDetails) (err e
 
 	ctx = workflow.WithActivityOptions(ctx, options)
 
-	err = workflow.ExecuteActivity(ctx, Withdraw, transferDetails).Get(ctx, nil)
+	// Compensations run in the reverse order of the steps when a later step fails, all of them even if one fails. They
+	// run on a disconnected context, so they also run when the workflow is cancelled.
+	s := New(Options{ContinueWithError: true, DisconnectedContext: true})
+	err = workflow.SetQueryHandler(ctx, LedgerQuery, func() ([]Step, error) {
+		return s.Ledger(), nil
+	})
 	if err != nil {
 		return err
 	}
-
 	defer func() {
-		if err != nil {
-			errCompensation := workflow.ExecuteActivity(ctx, WithdrawCompensation, transferDetails).Get(ctx, nil)
-			err = multierr.Append(err, errCompensation)
+		if err == nil {
+			return
+		}
+		// uncomment to have time to shut down worker to simulate worker rolling update and ensure that compensation sequence preserves after restart
+		// workflow.Sleep(ctx, 10*time.Second)
+		errCompensation := s.Compensate(ctx)
+		// The ledger shows which steps moved money and which were undone.
+		if temporal.IsCanceledError(err) {
+			// A canceled error has no cause, the compensation error follows the ledger in its details.
+			details := []interface{}{s.Ledger()}
+			if errCompensation != nil {
+				workflow.GetLogger(ctx).Error("Compensation of the cancelled transfer failed.", "Error", errCompensation)
+				details = append(details, errCompensation.Error())
+			}
+			err = temporal.NewCanceledError(details...)
+			return
 		}
+		err = temporal.NewApplicationErrorWithCause("transfer failed", TransferFailedErrorType, multierr.Append(err, errCompensation), s.Ledger())
 	}()
 
-	err = workflow.ExecuteActivity(ctx, Deposit, transferDetails).Get(ctx, nil)
+	err = workflow.ExecuteActivity(ctx, Withdraw, transferDetails).Get(ctx, nil)
+	s.RecordStep("Withdraw", err)
 	if err != nil {
 		return err
 	}
+	s.AddCompensation(WithdrawCompensation, transferDetails)
 
-	defer func() {
-		if err != nil {
-			errCompensation := workflow.ExecuteActivity(ctx, DepositCompensation, transferDetails).Get(ctx, nil)
-			err = multierr.Append(err, errCompensation)
-		}
-
-		// uncomment to have time to shut down worker to simulate worker rolling update and ensure that compensation sequence preserves after restart
-		// workflow.Sleep(ctx, 10*time.Second)
-	}()
+	err = workflow.ExecuteActivity(ctx, Deposit, transferDetails).Get(ctx, nil)
+	s.RecordStep("Deposit", err)
+	if err != nil {
+		return err
+	}
+	s.AddCompensation(DepositCompensation, transferDetails)
 
 	err = workflow.ExecuteActivity(ctx, StepWithError, transferDetails).Get(ctx, nil)
+	s.RecordStep("StepWithError", err)
 	if err != nil {
 		return err
 	}
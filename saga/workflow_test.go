@@ -3,10 +3,12 @@ package saga
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/testsuite"
 )
 
@@ -28,4 +30,53 @@ func Test_Workflow(t *testing.T) {
 	env.ExecuteWorkflow(TransferMoney, testDetails)
 	require.True(t, env.IsWorkflowCompleted())
 	require.Error(t, env.GetWorkflowError())
+
+	var applicationErr *temporal.ApplicationError
+	require.ErrorAs(t, env.GetWorkflowError(), &applicationErr)
+	require.Equal(t, TransferFailedErrorType, applicationErr.Type())
+	var ledger []Step
+	require.NoError(t, applicationErr.Details(&ledger))
+	require.Len(t, ledger, 3)
+	require.Equal(t, Step{Name: "Withdraw", Status: StepCompensated}, ledger[0])
+	require.Equal(t, Step{Name: "Deposit", Status: StepCompensated}, ledger[1])
+	require.Equal(t, "StepWithError", ledger[2].Name)
+	require.Equal(t, StepFailed, ledger[2].Status)
+	require.Contains(t, ledger[2].Error, "some error")
+
+	result, err := env.QueryWorkflow(LedgerQuery)
+	require.NoError(t, err)
+	require.NoError(t, result.Get(&ledger))
+	require.Equal(t, StepCompensated, ledger[0].Status)
+}
+
+func Test_Workflow_Cancelled(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	testDetails := TransferDetails{
+		Amount:      1.00,
+		FromAccount: "001-001",
+		ToAccount:   "002-002",
+		ReferenceID: "1234",
+	}
+	env.OnActivity(Withdraw, mock.Anything, testDetails).Return(nil)
+	env.OnActivity(WithdrawCompensation, mock.Anything, testDetails).Return(nil)
+	env.OnActivity(Deposit, mock.Anything, testDetails).Return(nil)
+	env.OnActivity(DepositCompensation, mock.Anything, testDetails).Return(errors.New("account closed"))
+	env.OnActivity(StepWithError, mock.Anything, testDetails).After(time.Hour).Return(nil)
+	// Cancel the workflow while the last step runs, after money moved.
+	env.RegisterDelayedCallback(env.CancelWorkflow, time.Minute)
+	env.ExecuteWorkflow(TransferMoney, testDetails)
+	require.True(t, env.IsWorkflowCompleted())
+
+	var canceledErr *temporal.CanceledError
+	require.ErrorAs(t, env.GetWorkflowError(), &canceledErr)
+	var ledger []Step
+	var errCompensation string
+	require.NoError(t, canceledErr.Details(&ledger, &errCompensation))
+	require.Contains(t, errCompensation, "account closed")
+	require.Equal(t, StepCompensated, ledger[0].Status)
+	require.Equal(t, StepCompensationFailed, ledger[1].Status)
+	require.Contains(t, ledger[1].Error, "account closed")
+	require.Equal(t, StepFailed, ledger[2].Status)
+	env.AssertExpectations(t)
 }
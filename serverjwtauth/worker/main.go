@@ -7,6 +7,7 @@ import (
 	"go.temporal.io/sdk/worker"
 
 	"github.com/temporalio/samples-go/helloworld"
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 	"github.com/temporalio/samples-go/serverjwtauth"
 )
 
@@ -16,7 +17,7 @@ func main() {
 		log.Fatalln(err)
 	}
 	// The client and worker are heavyweight objects that should be created once per process.
-	c, err := client.Dial(client.Options{
+	c, shutdown, err := bootstrap.Dial(client.Options{
 		HeadersProvider: &serverjwtauth.JWTHeadersProvider{
 			Config: serverjwtauth.JWTConfig{
 				Key:   key,
@@ -31,6 +32,7 @@ func main() {
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
 	w := worker.New(c, "server-jwt-auth", worker.Options{})
@@ -6,6 +6,7 @@ import (
 
 	"log/slog"
 
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 	"github.com/temporalio/samples-go/slogadapter"
 	"go.temporal.io/sdk/client"
 	tlog "go.temporal.io/sdk/log"
@@ -13,7 +14,7 @@ import (
 )
 
 func main() {
-	c, err := client.Dial(client.Options{
+	c, shutdown, err := bootstrap.Dial(client.Options{
 		Logger: tlog.NewStructuredLogger(
 			slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 				AddSource: true,
@@ -23,6 +24,7 @@ func main() {
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
 	w := worker.New(c, "slog-logger", worker.Options{})
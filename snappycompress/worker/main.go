@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 	"github.com/temporalio/samples-go/snappycompress"
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/worker"
@@ -10,7 +11,7 @@ import (
 
 func main() {
 	// The client and worker are heavyweight objects that should be created once per process.
-	c, err := client.Dial(client.Options{
+	c, shutdown, err := bootstrap.Dial(client.Options{
 		// Set DataConverter here so that workflow and activity inputs/results will
 		// be compressed as required.
 		DataConverter: snappycompress.AlwaysCompressDataConverter,
@@ -18,6 +19,7 @@ func main() {
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
 	w := worker.New(c, "snappycompress", worker.Options{})
@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 	rainbowstatuses "github.com/temporalio/samples-go/temporal-fixtures/rainbow-statuses"
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/worker"
@@ -10,12 +11,13 @@ import (
 
 func main() {
 	// The client and worker are heavyweight objects that should be created once per process.
-	c, err := client.Dial(client.Options{
+	c, shutdown, err := bootstrap.Dial(client.Options{
 		HostPort: client.DefaultHostPort,
 	})
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 
 	w := worker.New(c, "rainbow-statuses", worker.Options{})
@@ -4,6 +4,7 @@ import (
 	"log"
 	"sync"
 
+	"github.com/temporalio/samples-go/metrics/bootstrap"
 	worker_specific_task_queues "github.com/temporalio/samples-go/worker-specific-task-queues"
 
 	"go.temporal.io/sdk/activity"
@@ -16,10 +17,11 @@ import (
 func main() {
 	// The client and worker are heavyweight objects that should generally be created once per process.
 	// In this case, we create a single client but two workers since we need to handle Activities on multiple task queues.
-	c, err := client.Dial(client.Options{})
+	c, shutdown, err := bootstrap.Dial(client.Options{})
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
+	defer shutdown()
 	defer c.Close()
 	uniqueTaskQueue := worker_specific_task_queues.WorkerSpecificTaskQueue{
 		TaskQueue: uuid.New().String(),